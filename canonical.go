@@ -0,0 +1,538 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"bufio"
+	"cmp"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// customStylePrefix starts the names of the cell styles generated for cells
+// created with [MakeStyledCell].
+const customStylePrefix = "CUSTOM_STYLE_"
+
+// styleReferences are the attributes naming a style defined elsewhere in a
+// document, by their qualified names, besides those ending in style-name,
+// see isStyleReference.
+var styleReferences = map[string]bool{
+	"style:master-page-name": true,
+	"style:page-layout-name": true,
+}
+
+// isStyleReference reports whether the attribute of a name names a style: one
+// of styleReferences, or any attribute ending in style-name, such as
+// table:style-name, style:data-style-name, or the draw:text-style-name of
+// cell comments, so that references of elements not written here, as by
+// LibreOffice, keep their styles.
+func isStyleReference(name xml.Name) bool {
+	return strings.HasSuffix(name.Local, "style-name") || styleReferences[qualifiedName(name)]
+}
+
+// MakeCanonicalFlatOds serializes the spreadsheet as a flat OpenDocument XML
+// document (.fods) in a canonical form meant for version control, where a
+// change to one cell changes one line. Implemented as [WriteCanonicalFlatOds]
+// into a string.
+func MakeCanonicalFlatOds(spreadsheet Spreadsheet) (string, error) {
+	var b strings.Builder
+	if err := WriteCanonicalFlatOds(&b, spreadsheet); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// WriteCanonicalFlatOds writes the spreadsheet as a flat OpenDocument XML
+// document (.fods) to w, holding the same content as [WriteFlatOds] writes
+// in a canonical form:
+//
+//   - generated cell styles are named by a short hash of their content, such
+//     as CUSTOM_STYLE_1a2b3c4d, rather than numbered in the order of their
+//     first use, so that styling one cell does not rename the styles of
+//     others, and styles are sorted by kind and name
+//   - styles no cell refers to, such as the number formats of value types
+//     the spreadsheet does not hold, are left out
+//   - attributes are sorted by name, namespace declarations first
+//   - each cell is written on a line of its own, with its paragraph, and
+//     line breaks in its text are written as character references
+//
+// Unlike WriteFlatOds, WriteCanonicalFlatOds holds the document in memory
+// as a whole before writing it.
+func WriteCanonicalFlatOds(w io.Writer, spreadsheet Spreadsheet) error {
+	r, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(WriteFlatOds(pw, spreadsheet))
+	}()
+	root, err := parseXMLTree(r)
+	// Unblocks WriteFlatOds if the document was not read to its end.
+	r.Close()
+	if err != nil {
+		return err
+	}
+	canonicalizeStyles(root, generatedStyles)
+	if err := root.writeDocument(w); err != nil {
+		return fmt.Errorf("writing flat ods document: %w", err)
+	}
+	return nil
+}
+
+// xmlNode is an element of a document, with its namespace prefixes as they
+// are written, or, if it has no name, a text node.
+type xmlNode struct {
+	name     xml.Name
+	attrs    []xml.Attr
+	children []*xmlNode
+	text     string
+}
+
+// parseXMLTree reads a document into a tree of nodes and returns its root
+// element. Comments, processing instructions, and the whitespace indenting
+// elements are dropped; the text of paragraphs, and of elements holding
+// nothing but text, is kept as it is.
+func parseXMLTree(r io.Reader) (*xmlNode, error) {
+	d := xml.NewDecoder(r)
+	document := &xmlNode{}
+	stack := []*xmlNode{document}
+	// paragraphs counts the paragraphs the current element is in, whose
+	// whitespace is part of their text.
+	paragraphs := 0
+	for {
+		tok, err := d.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		top := stack[len(stack)-1]
+		switch t := tok.(type) {
+		case xml.StartElement:
+			n := &xmlNode{name: t.Name, attrs: slices.Clone(t.Attr)}
+			top.children = append(top.children, n)
+			stack = append(stack, n)
+			if n.isParagraph() {
+				paragraphs++
+			}
+		case xml.EndElement:
+			if len(stack) == 1 || t.Name != top.name {
+				return nil, fmt.Errorf("unexpected end element </%s>", qualifiedName(t.Name))
+			}
+			if paragraphs == 0 {
+				top.trimSpace()
+			}
+			if top.isParagraph() {
+				paragraphs--
+			}
+			stack = stack[:len(stack)-1]
+		case xml.CharData:
+			if len(stack) == 1 {
+				continue
+			}
+			if last := len(top.children) - 1; last >= 0 && top.children[last].isText() {
+				top.children[last].text += string(t)
+				continue
+			}
+			top.children = append(top.children, &xmlNode{text: string(t)})
+		}
+	}
+	if len(stack) > 1 {
+		return nil, fmt.Errorf("unexpected end of document in <%s>", qualifiedName(stack[len(stack)-1].name))
+	}
+	for _, n := range document.children {
+		if !n.isText() {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("no root element")
+}
+
+func (n *xmlNode) isText() bool {
+	return n.name.Local == ""
+}
+
+func (n *xmlNode) isParagraph() bool {
+	return n.name.Space == "text" && (n.name.Local == "p" || n.name.Local == "h")
+}
+
+// trimSpace drops the text nodes of n that are nothing but whitespace, if
+// n holds elements, or is an element of the structure of a document rather
+// than of its text.
+func (n *xmlNode) trimSpace() {
+	structural := n.name.Space == "office" || n.name.Space == "table" || n.name.Space == "style"
+	if !structural && !slices.ContainsFunc(n.children, func(c *xmlNode) bool { return !c.isText() }) {
+		return
+	}
+	n.children = slices.DeleteFunc(n.children, func(c *xmlNode) bool {
+		return c.isText() && strings.TrimSpace(c.text) == ""
+	})
+}
+
+// attr returns the value of the attribute of n with the qualified name, or
+// "".
+func (n *xmlNode) attr(name string) string {
+	for _, a := range n.attrs {
+		if qualifiedName(a.Name) == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// child returns the first child element of n with the qualified name, or
+// nil.
+func (n *xmlNode) child(name string) *xmlNode {
+	for _, c := range n.children {
+		if qualifiedName(c.name) == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// walk calls f for n and each element below it.
+func (n *xmlNode) walk(f func(*xmlNode)) {
+	if n.isText() {
+		return
+	}
+	f(n)
+	for _, c := range n.children {
+		c.walk(f)
+	}
+}
+
+func qualifiedName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+	return name.Space + ":" + name.Local
+}
+
+// styleNaming chooses the automatic styles canonicalizeStyles renames, and
+// their new names.
+type styleNaming struct {
+	// selected reports whether the style of a name is renamed.
+	selected func(name string) bool
+	// prefix returns the start of the new name of a style, which a short
+	// hash of its content follows.
+	prefix func(style *xmlNode) string
+	// known maps the contents of styles to the names they are given instead
+	// of hashes.
+	known map[string]string
+}
+
+// generatedStyles renames the cell styles generated for cells created with
+// [MakeStyledCell].
+var generatedStyles = styleNaming{
+	selected: func(name string) bool { return strings.HasPrefix(name, customStylePrefix) },
+	prefix:   func(*xmlNode) string { return customStylePrefix },
+}
+
+// canonicalizeStyles drops the automatic styles of a document nothing
+// refers to, and renames the styles naming selects by a hash of their
+// content, merging those of equal content. The automatic styles are then
+// sorted: number styles before other styles, styles by family, styles
+// after those of their kind they refer to, and else by name.
+func canonicalizeStyles(root *xmlNode, naming styleNaming) {
+	automatic := root.child("office:automatic-styles")
+	if automatic == nil {
+		return
+	}
+	definitions := map[string]*xmlNode{}
+	for _, n := range automatic.children {
+		if name := n.attr("style:name"); name != "" {
+			definitions[name] = n
+		}
+	}
+
+	used := map[string]bool{}
+	var refer func(n *xmlNode)
+	refer = func(n *xmlNode) {
+		n.walk(func(e *xmlNode) {
+			for _, a := range e.attrs {
+				if !isStyleReference(a.Name) || used[a.Value] {
+					continue
+				}
+				used[a.Value] = true
+				if definition, ok := definitions[a.Value]; ok {
+					refer(definition)
+				}
+			}
+		})
+	}
+	for _, n := range root.children {
+		if n != automatic {
+			refer(n)
+		}
+	}
+	automatic.children = slices.DeleteFunc(automatic.children, func(n *xmlNode) bool {
+		name := n.attr("style:name")
+		return name != "" && !used[name]
+	})
+
+	renames := renameStyles(automatic.children, naming)
+	root.walk(func(e *xmlNode) {
+		for i, a := range e.attrs {
+			if isStyleReference(a.Name) {
+				if name, ok := renames[a.Value]; ok {
+					e.attrs[i].Value = name
+				}
+			}
+		}
+	})
+
+	// Styles renamed to the name of another are duplicates of it.
+	defined := map[string]*xmlNode{}
+	automatic.children = slices.DeleteFunc(automatic.children, func(n *xmlNode) bool {
+		name := n.attr("style:name")
+		if renamed, ok := renames[name]; ok {
+			name = renamed
+			n.setAttr("style:name", name)
+		}
+		if _, ok := defined[name]; ok && name != "" {
+			return true
+		}
+		defined[name] = n
+		return false
+	})
+
+	// depths counts the styles of the same kind each style refers to in a
+	// chain, as some consumers only resolve references to styles already
+	// read, such as those of style:map.
+	depths := map[*xmlNode]int{}
+	var depth func(n *xmlNode) int
+	depth = func(n *xmlNode) int {
+		if d, ok := depths[n]; ok {
+			return d
+		}
+		depths[n] = 0
+		d := 0
+		n.walk(func(e *xmlNode) {
+			for _, a := range e.attrs {
+				referred, ok := defined[a.Value]
+				if ok && referred != n && isStyleReference(a.Name) && styleKind(referred) == styleKind(n) {
+					d = max(d, depth(referred)+1)
+				}
+			}
+		})
+		depths[n] = d
+		return d
+	}
+	slices.SortStableFunc(automatic.children, func(a, b *xmlNode) int {
+		return cmp.Or(
+			cmp.Compare(styleKind(a), styleKind(b)),
+			cmp.Compare(a.attr("style:family"), b.attr("style:family")),
+			cmp.Compare(depth(a), depth(b)),
+			cmp.Compare(a.attr("style:name"), b.attr("style:name")),
+		)
+	})
+}
+
+// styleKind orders number styles before other styles, and those before
+// page layouts.
+func styleKind(n *xmlNode) int {
+	switch {
+	case n.name.Space == "number":
+		return 0
+	case qualifiedName(n.name) == "style:style":
+		return 1
+	}
+	return 2
+}
+
+// renameStyles returns new names for the styles among definitions that
+// naming selects: a known name, or a prefix followed by a short hash of
+// their content, with their references to other renamed styles renamed
+// first. Styles of equal content get the same name.
+func renameStyles(definitions []*xmlNode, naming styleNaming) map[string]string {
+	renames := map[string]string{}
+	// owners maps the new names to the content they were given for, so that
+	// distinct contents whose hashes share a prefix get longer ones.
+	owners := map[string]string{}
+	var pending []*xmlNode
+	selected := map[string]bool{}
+	for _, n := range definitions {
+		if name := n.attr("style:name"); name != "" && naming.selected(name) {
+			pending = append(pending, n)
+			selected[name] = true
+		}
+	}
+	for len(pending) > 0 {
+		var waiting []*xmlNode
+		for _, n := range pending {
+			if n.refersToPending(selected, renames) {
+				waiting = append(waiting, n)
+				continue
+			}
+			content := n.styleContent(renames)
+			if name, ok := naming.known[content]; ok {
+				renames[n.attr("style:name")] = name
+				continue
+			}
+			sum := sha256.Sum256([]byte(content))
+			hash := hex.EncodeToString(sum[:])
+			prefix := naming.prefix(n)
+			name := prefix + hash[:8]
+			for length := 9; owners[name] != "" && owners[name] != content; length++ {
+				name = prefix + hash[:length]
+			}
+			owners[name] = content
+			renames[n.attr("style:name")] = name
+		}
+		if len(waiting) == len(pending) {
+			// The styles refer to each other in a circle. Breaking it
+			// anywhere gives the rest the names they have.
+			renames[waiting[0].attr("style:name")] = waiting[0].attr("style:name")
+			waiting = waiting[1:]
+		}
+		pending = waiting
+	}
+	return renames
+}
+
+// refersToPending reports whether n refers to a selected style other than
+// itself that has not been renamed yet. References to styles defined
+// elsewhere, such as common styles, are not renamed.
+func (n *xmlNode) refersToPending(selected map[string]bool, renames map[string]string) bool {
+	self := n.attr("style:name")
+	pending := false
+	n.walk(func(e *xmlNode) {
+		for _, a := range e.attrs {
+			if isStyleReference(a.Name) && a.Value != self && selected[a.Value] {
+				if _, ok := renames[a.Value]; !ok {
+					pending = true
+				}
+			}
+		}
+	})
+	return pending
+}
+
+// styleContent returns the definition of a style as written, without its
+// name and with its references renamed, as the content its name is hashed
+// from.
+func (n *xmlNode) styleContent(renames map[string]string) string {
+	style := n.clone()
+	style.attrs = slices.DeleteFunc(style.attrs, func(a xml.Attr) bool { return qualifiedName(a.Name) == "style:name" })
+	style.walk(func(e *xmlNode) {
+		for i, a := range e.attrs {
+			if name, ok := renames[a.Value]; ok && isStyleReference(a.Name) {
+				e.attrs[i].Value = name
+			}
+		}
+	})
+	var b strings.Builder
+	w := bufio.NewWriter(&b)
+	style.writeInline(w)
+	w.Flush()
+	return b.String()
+}
+
+func (n *xmlNode) clone() *xmlNode {
+	c := &xmlNode{name: n.name, attrs: slices.Clone(n.attrs), text: n.text}
+	for _, child := range n.children {
+		c.children = append(c.children, child.clone())
+	}
+	return c
+}
+
+func (n *xmlNode) setAttr(name, value string) {
+	for i, a := range n.attrs {
+		if qualifiedName(a.Name) == name {
+			n.attrs[i].Value = value
+			return
+		}
+	}
+}
+
+// writeDocument writes the document of root element n, indenting elements
+// by two spaces. Cells, and elements holding text, are written on one line.
+func (n *xmlNode) writeDocument(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	bw.WriteString(xml.Header)
+	n.write(bw, 0)
+	return bw.Flush()
+}
+
+func (n *xmlNode) write(w *bufio.Writer, depth int) {
+	w.WriteString(strings.Repeat("  ", depth))
+	if n.isInline() {
+		n.writeInline(w)
+		w.WriteByte('\n')
+		return
+	}
+	n.writeStart(w)
+	w.WriteByte('\n')
+	for _, c := range n.children {
+		c.write(w, depth+1)
+	}
+	w.WriteString(strings.Repeat("  ", depth))
+	n.writeEnd(w)
+	w.WriteByte('\n')
+}
+
+// isInline reports whether n is written on one line: cells, so that a
+// change to one changes one line, empty elements, and elements holding
+// text, whose whitespace is part of it.
+func (n *xmlNode) isInline() bool {
+	switch qualifiedName(n.name) {
+	case "table:table-cell", "table:covered-table-cell":
+		return true
+	}
+	return len(n.children) == 0 || slices.ContainsFunc(n.children, (*xmlNode).isText)
+}
+
+func (n *xmlNode) writeInline(w *bufio.Writer) {
+	if n.isText() {
+		xml.EscapeText(w, []byte(n.text))
+		return
+	}
+	n.writeStart(w)
+	for _, c := range n.children {
+		c.writeInline(w)
+	}
+	n.writeEnd(w)
+}
+
+// writeStart writes the start tag of n with its attributes sorted, or the
+// whole of n if it is empty.
+func (n *xmlNode) writeStart(w *bufio.Writer) {
+	attrs := slices.Clone(n.attrs)
+	slices.SortStableFunc(attrs, func(a, b xml.Attr) int {
+		return cmp.Or(
+			cmp.Compare(attrGroup(a), attrGroup(b)),
+			cmp.Compare(qualifiedName(a.Name), qualifiedName(b.Name)),
+		)
+	})
+	w.WriteString("<" + qualifiedName(n.name))
+	for _, a := range attrs {
+		w.WriteString(" " + qualifiedName(a.Name) + `="`)
+		xml.EscapeText(w, []byte(a.Value))
+		w.WriteByte('"')
+	}
+	if len(n.children) == 0 {
+		w.WriteString("/>")
+		return
+	}
+	w.WriteByte('>')
+}
+
+func (n *xmlNode) writeEnd(w *bufio.Writer) {
+	if len(n.children) > 0 {
+		w.WriteString("</" + qualifiedName(n.name) + ">")
+	}
+}
+
+// attrGroup sorts namespace declarations before other attributes.
+func attrGroup(a xml.Attr) int {
+	if a.Name.Space == "xmlns" || (a.Name.Space == "" && a.Name.Local == "xmlns") {
+		return 0
+	}
+	return 1
+}
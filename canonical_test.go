@@ -0,0 +1,140 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func makeCanonical(t *testing.T, cells [][]Cell) string {
+	t.Helper()
+
+	spreadsheet, err := MakeSpreadsheet(cells)
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	document, err := MakeCanonicalFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeCanonicalFlatOds: %v", err)
+	}
+	return document
+}
+
+// changedLines returns the lines of b that a does not have at the same
+// position, and the other way around, for documents of the same length.
+func changedLines(a, b string) []string {
+	linesA, linesB := strings.Split(a, "\n"), strings.Split(b, "\n")
+	var changed []string
+	for i := range min(len(linesA), len(linesB)) {
+		if linesA[i] != linesB[i] {
+			changed = append(changed, linesA[i], linesB[i])
+		}
+	}
+	return changed
+}
+
+func TestUnitCanonicalFlatOdsOneCellPerLine(t *testing.T) {
+	cells := [][]Cell{
+		{MakeCell("multi\nline", "string"), MakeCell("1.5", "float"), MakeCell("B1*2", "formula")},
+		{MakeCell("2025-01-01", "date"), {}, MakeStyledCell("x", "string", CellStyle{Italic: true})},
+	}
+	actual := makeCanonical(t, cells)
+
+	cellLines := 0
+	for _, line := range strings.Split(actual, "\n") {
+		count := strings.Count(line, "<table:table-cell")
+		assert(t, count <= 1, fmt.Sprintf("expected at most one cell per line, got %q", line))
+		cellLines += count
+	}
+	assert(t, cellLines == 6, fmt.Sprintf("expected 6 lines with a cell, got %d", cellLines))
+	assert(t, strings.Contains(actual, "\n          <table:table-cell office:value-type=\"string\"><text:p>multi&#xA;line</text:p></table:table-cell>\n"), "expected a cell with its paragraph on one line, indented by its depth")
+	assert(t, strings.Contains(actual, `<table:table-cell office:value="1.5" office:value-type="float" table:style-name="FLOAT_STYLE"/>`), "expected sorted attributes and an empty element closed in its start tag")
+
+	changed := changedLines(actual, makeCanonical(t, [][]Cell{
+		{MakeCell("multi\nline", "string"), MakeCell("2.5", "float"), MakeCell("B1*2", "formula")},
+		cells[1],
+	}))
+	assert(t, len(changed) == 4, fmt.Sprintf("expected the changed cell and the formula referring to it to change, got %q", changed))
+}
+
+func TestUnitCanonicalFlatOdsStyleNames(t *testing.T) {
+	bold := MakeStyledCell("Total", "string", CellStyle{Bold: true})
+	before := makeCanonical(t, [][]Cell{
+		{MakeCell("Item", "string")},
+		{bold},
+	})
+	after := makeCanonical(t, [][]Cell{
+		{MakeStyledCell("Item", "string", CellStyle{BackgroundColor: ColorNavy})},
+		{bold},
+	})
+
+	name := regexp.MustCompile(`table:style-name="(CUSTOM_STYLE_[0-9a-f]{8})"><text:p>Total`)
+	matchBefore, matchAfter := name.FindStringSubmatch(before), name.FindStringSubmatch(after)
+	assert(t, matchBefore != nil && matchAfter != nil, "expected a generated style named by a hash")
+	assert(t, matchBefore[1] == matchAfter[1], "expected styling an earlier cell to keep the name of the style of a later one")
+
+	// Styling a cell adds its style and changes its line, and nothing else.
+	linesBefore, linesAfter := strings.Split(before, "\n"), strings.Split(after, "\n")
+	var removed, added []string
+	for _, line := range linesBefore {
+		if !slices.Contains(linesAfter, line) {
+			removed = append(removed, line)
+		}
+	}
+	for _, line := range linesAfter {
+		if !slices.Contains(linesBefore, line) {
+			added = append(added, line)
+		}
+	}
+	assert(t, len(removed) == 1 && strings.Contains(removed[0], "Item"), fmt.Sprintf("expected only the styled cell to change, got %q", removed))
+	// The end tag of the style is the same as that of the other one.
+	assert(t, len(added) == 3, fmt.Sprintf("expected the styled cell and its style to be added, got %q", added))
+}
+
+func TestUnitCanonicalFlatOdsDropsUnusedStyles(t *testing.T) {
+	actual := makeCanonical(t, [][]Cell{{MakeCell("ABBA", "string"), MakeCell("12.50", "currency-eur")}})
+
+	assert(t, !strings.Contains(actual, "FLOAT_STYLE") && !strings.Contains(actual, "FLOAT_DATA_STYLE"), "expected no float styles without float cells")
+	assert(t, !strings.Contains(actual, "USD_DATA_STYLE"), "expected no dollar styles without dollar cells")
+	assert(t, strings.Contains(actual, `style:name="EUR_STYLE"`) && strings.Contains(actual, `style:name="EUR_DATA_STYLE"`), "expected the styles of euro cells")
+	assert(t, strings.Contains(actual, `style:name="EUR_DATA_STYLE_POSITIVE"`), "expected the style the euro style maps positive values to")
+	assert(t, strings.Contains(actual, `style:name="TABLE_STYLE"`) && strings.Contains(actual, `style:name="PAGE_LAYOUT"`), "expected the table style and the page layout")
+}
+
+func TestUnitCanonicalFlatOdsRoundTrip(t *testing.T) {
+	for name, spreadsheet := range roundTripSpreadsheets(t) {
+		t.Run(name, func(t *testing.T) {
+			expected, err := MakeCanonicalFlatOds(spreadsheet)
+			if err != nil {
+				t.Fatalf("MakeCanonicalFlatOds: %v", err)
+			}
+			read, err := ReadFlatOds(strings.NewReader(expected))
+			if err != nil {
+				t.Fatalf("ReadFlatOds: %v", err)
+			}
+			actual, err := MakeCanonicalFlatOds(read)
+			if err != nil {
+				t.Fatalf("MakeCanonicalFlatOds: %v", err)
+			}
+			assert(t, actual == expected, fmt.Sprintf("expected a canonical document to be written back unchanged, changed lines: %q", changedLines(expected, actual)))
+		})
+	}
+}
+
+func TestCanonicalFlatOdsMatchesOdfSchema(t *testing.T) {
+	for name, spreadsheet := range roundTripSpreadsheets(t) {
+		t.Run(name, func(t *testing.T) {
+			document, err := MakeCanonicalFlatOds(spreadsheet)
+			if err != nil {
+				t.Fatalf("MakeCanonicalFlatOds: %v", err)
+			}
+			validateAgainstSchema(t, "canonical.fods", document)
+		})
+	}
+}
@@ -33,6 +33,7 @@ func main() {
 		"styles":      mustSpreadsheet("styles", stylesDocument()),
 		"auto-filter": autoFilterDocument(),
 		"table":       tableDocument(),
+		"workbook":    workbookDocument(),
 	}
 
 	for name, spreadsheet := range documents {
@@ -49,12 +50,15 @@ func main() {
 		}
 		fmt.Println("wrote", odsPath)
 
-		fods, err := rb.MakeFlatOds(spreadsheet)
+		fodsPath := filepath.Join(*out, name+".fods")
+		fodsFile, err := os.Create(fodsPath)
 		if err != nil {
+			log.Fatal(err)
+		}
+		if err := rb.WriteFlatOds(fodsFile, spreadsheet); err != nil {
 			log.Fatalf("%s: %v", name, err)
 		}
-		fodsPath := filepath.Join(*out, name+".fods")
-		if err := os.WriteFile(fodsPath, []byte(fods), 0o644); err != nil {
+		if err := fodsFile.Close(); err != nil {
 			log.Fatal(err)
 		}
 		fmt.Println("wrote", fodsPath)
@@ -198,3 +202,34 @@ func tableDocument() rb.Spreadsheet {
 	}
 	return spreadsheet
 }
+
+// workbookDocument shows MakeWorkbook: a summary sheet whose formulas refer
+// to the columns of a table on a second sheet.
+func workbookDocument() rb.Spreadsheet {
+	summary := [][]rb.Cell{
+		{rb.MakeCell("Revenue", "string"), rb.MakeCell("SUMPRODUCT(Quantity;Price)", "formula")},
+		{rb.MakeCell("Items sold", "string"), rb.MakeCell("SUM(Quantity)", "formula")},
+	}
+	orders := [][]rb.Cell{
+		{rb.MakeCell("Product", "string"), rb.MakeCell("Quantity", "string"), rb.MakeCell("Price", "string")},
+		{rb.MakeCell("Laptop", "string"), rb.MakeCell("3", "float"), rb.MakeCell("999.00", "currency")},
+		{rb.MakeCell("Mouse", "string"), rb.MakeCell("10", "float"), rb.MakeCell("19.99", "currency")},
+		{rb.MakeCell("Desk", "string"), rb.MakeCell("2", "float"), rb.MakeCell("189.00", "currency")},
+	}
+
+	spreadsheet, err := rb.MakeWorkbook(
+		rb.MakeSheet("Summary", summary),
+		rb.MakeTableSheet("Orders", orders, rb.TableOptions{
+			Name:           "Orders",
+			Header:         true,
+			AutoFilter:     true,
+			BandedRows:     true,
+			StructuredRefs: true,
+			Style:          rb.TableStyleGreen,
+		}),
+	)
+	if err != nil {
+		log.Fatalf("workbook: %v", err)
+	}
+	return spreadsheet
+}
@@ -0,0 +1,369 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"cmp"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// CSVOptions configures how [ReadCSV] and [ImportCSV] read a CSV file.
+type CSVOptions struct {
+	// Comma is the field delimiter. Defaults to ',', or to ';' if
+	// DecimalComma is set, as in the CSV files of German locales.
+	Comma rune
+	// DecimalComma reads numbers with a decimal comma and "." or spaces
+	// between groups of thousands, as in "-1.234,56", rather than with a
+	// decimal point and "," between groups, as in "-1,234.56".
+	DecimalComma bool
+	// Header keeps the first record as a header row of string cells, which
+	// takes no part in inferring the types of the columns.
+	Header bool
+	// HintHeader implies Header, and reads a value type of [MakeCell] from
+	// header fields of the form "Amount:currency-eur", which are shown
+	// without it. Fields whose suffix is no value type are kept as they are.
+	HintHeader bool
+	// Types gives the value types of [MakeCell] for the columns, by index,
+	// overriding hints in the header. The types of columns beyond len(Types),
+	// and of those with an empty entry, are inferred.
+	Types []string
+	// SheetName names the sheet made by [ImportCSV]. Defaults to "Sheet1".
+	SheetName string
+	// Table, if set, makes [ImportCSV] format the sheet as a table, as
+	// [MakeTable] does with these options. Header is set if the CSV has one.
+	Table *TableOptions
+}
+
+var (
+	pointNumberFormat = regexp.MustCompile(`^([-+−]?)(\d{1,3}(?:,\d{3})+|\d+)(?:\.(\d+))?$`)
+	commaNumberFormat = regexp.MustCompile(`^([-+−]?)(\d{1,3}(?:[. \x{a0}]\d{3})+|\d+)(?:,(\d+))?$`)
+	// leadingZero matches numbers that are identifiers rather than amounts,
+	// such as postal codes and account numbers, whose zeros a number
+	// would lose.
+	leadingZero = regexp.MustCompile(`^[-+−]?0\d`)
+)
+
+// currencySymbols maps the symbols and codes recognized around the amounts
+// of currency fields to their value types.
+var currencySymbols = []struct{ symbol, valueType string }{
+	{"€", "currency-eur"},
+	{"EUR", "currency-eur"},
+	{"$", "currency-usd"},
+	{"USD", "currency-usd"},
+	{"£", "currency-gbp"},
+	{"GBP", "currency-gbp"},
+}
+
+// inferredTypes are the value types ReadCSV infers, in the order they are
+// tried.
+var inferredTypes = []string{"float", "percentage", "currency-eur", "currency-usd", "currency-gbp", "date", "time"}
+
+// ReadCSV reads a CSV file into rows of cells, ready for [MakeSpreadsheet]
+// or [MakeTable]. The value type of each column is given by opts.Types or
+// the header of the file, or else inferred from its fields, as the first of
+// these that all non-empty fields of the column are:
+//
+//   - "float": numbers such as "-1,234.56", or "-1.234,56" with
+//     DecimalComma; numbers with leading zeros, such as "01067", are taken to
+//     be identifiers and stay strings
+//   - "percentage": numbers followed by a percent sign, such as "42 %", which
+//     is stored as the fraction 0.42
+//   - "currency-eur", "currency-usd", or "currency-gbp": numbers preceded or
+//     followed by the same currency symbol or code, such as "€ 12.50",
+//     "-12,50 €", or "1,200.00 USD"
+//   - "date": ISO, German, or US dates, as accepted by [MakeCell]
+//   - "time": times of HH:MM or HH:MM:SS
+//
+// Columns that are none of these, or that have no values at all, hold
+// strings. Empty fields become empty cells, and rows may be of different
+// lengths. Numbers are read in the dialect of opts, also in columns whose
+// type is given, where percent signs and currency symbols are optional. A
+// number without a percent sign in a percentage column is the fraction, so
+// that "0.42" and "42 %" are the same value.
+//
+// ReadCSV reports malformed CSV and fields that do not fit the value type
+// given for their column as a single joined error, with the row and column
+// of each field.
+func ReadCSV(r io.Reader, opts CSVOptions) ([][]Cell, error) {
+	reader := csv.NewReader(r)
+	reader.Comma = opts.Comma
+	if reader.Comma == 0 {
+		reader.Comma = ','
+		if opts.DecimalComma {
+			reader.Comma = ';'
+		}
+	}
+	reader.FieldsPerRecord = -1
+
+	var records [][]string
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+	if len(records) > 0 && len(records[0]) > 0 {
+		records[0][0] = strings.TrimPrefix(records[0][0], "\ufeff")
+	}
+
+	var errs []error
+	for i, valueType := range opts.Types {
+		if _, ok := valueKind(valueType); valueType != "" && !ok {
+			errs = append(errs, fmt.Errorf("column %d: unknown value type %q", i+1, valueType))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	var cells [][]Cell
+	body := records
+	types := opts.Types
+	if opts.Header || opts.HintHeader {
+		if len(records) == 0 {
+			return nil, nil
+		}
+		header := make([]Cell, len(records[0]))
+		hints := make([]string, len(records[0]))
+		for i, field := range records[0] {
+			if name, hint, ok := strings.Cut(field, ":"); opts.HintHeader && ok {
+				if _, known := valueKind(hint); known {
+					field, hints[i] = name, hint
+				}
+			}
+			header[i] = MakeCell(field, "string")
+		}
+		for i, valueType := range types {
+			if valueType != "" && i < len(hints) {
+				hints[i] = valueType
+			}
+		}
+		if len(types) > len(hints) {
+			hints = append(hints, types[len(hints):]...)
+		}
+		cells, body, types = append(cells, header), records[1:], hints
+	}
+
+	columns := 0
+	for _, record := range body {
+		columns = max(columns, len(record))
+	}
+	columnTypes := make([]string, columns)
+	for c := range columnTypes {
+		if c < len(types) && types[c] != "" {
+			columnTypes[c] = types[c]
+			continue
+		}
+		columnTypes[c] = inferColumnType(body, c, opts.DecimalComma)
+	}
+
+	for _, record := range body {
+		row := make([]Cell, len(record))
+		for c, field := range record {
+			row[c] = csvCell(field, columnTypes[c], opts.DecimalComma)
+			if row[c].err != nil {
+				errs = append(errs, fmt.Errorf("row %d, column %d: %w", len(cells)+1, c+1, row[c].err))
+			}
+		}
+		cells = append(cells, row)
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cells, nil
+}
+
+// ImportCSV reads a CSV file as [ReadCSV] does and arranges its rows into a
+// spreadsheet with a single sheet, formatted as a table if opts.Table is set.
+func ImportCSV(r io.Reader, opts CSVOptions) (Spreadsheet, error) {
+	cells, err := ReadCSV(r, opts)
+	if err != nil {
+		return Spreadsheet{}, err
+	}
+	name := cmp.Or(opts.SheetName, defaultTableName)
+	if opts.Table == nil {
+		return MakeSpreadsheetWithName(name, cells)
+	}
+	tableOpts := *opts.Table
+	tableOpts.Header = tableOpts.Header || opts.Header || opts.HintHeader
+	return makeSingleSheet(MakeTableSheet(name, cells, tableOpts))
+}
+
+// inferColumnType returns the first of inferredTypes that all non-empty
+// fields of column c of records are, or "string".
+func inferColumnType(records [][]string, c int, decimalComma bool) string {
+	candidates := slices.Clone(inferredTypes)
+	values := false
+	for _, record := range records {
+		if c >= len(record) || strings.TrimSpace(record[c]) == "" {
+			continue
+		}
+		values = true
+		candidates = slices.DeleteFunc(candidates, func(valueType string) bool {
+			return !isCSVValue(strings.TrimSpace(record[c]), valueType, decimalComma)
+		})
+		if len(candidates) == 0 {
+			return "string"
+		}
+	}
+	if !values {
+		return "string"
+	}
+	return candidates[0]
+}
+
+// isCSVValue reports whether field is a value of valueType, in the strict
+// sense of inference: percentages need their sign and currencies their
+// symbol.
+func isCSVValue(field, valueType string, decimalComma bool) bool {
+	switch valueType {
+	case "float":
+		_, ok := csvNumber(field, decimalComma)
+		return ok && !leadingZero.MatchString(field)
+	case "percentage":
+		number, ok := strings.CutSuffix(field, "%")
+		if !ok {
+			return false
+		}
+		_, ok = csvNumber(trimSpace(number), decimalComma)
+		return ok
+	case "currency-eur", "currency-usd", "currency-gbp":
+		_, currency, ok := csvCurrency(field, decimalComma)
+		return ok && currency == valueType
+	case "date":
+		date, err := dateString(field)
+		if err != nil {
+			return false
+		}
+		_, err = time.Parse("2006-01-02", date)
+		return err == nil
+	case "time":
+		return timeFormat.MatchString(field)
+	}
+	return false
+}
+
+// csvCell makes the cell of a field of a column of valueType. Numbers are
+// converted from the dialect of the file to the form [MakeCell] expects;
+// fields that are no numbers are passed on as they are, for MakeCell to
+// report.
+func csvCell(field, valueType string, decimalComma bool) Cell {
+	if valueType != "string" {
+		field = strings.TrimSpace(field)
+	}
+	if field == "" {
+		return Cell{}
+	}
+	switch valueType {
+	case "float":
+		if number, ok := csvNumber(field, decimalComma); ok {
+			field = number
+		}
+	case "percentage":
+		if number, ok := strings.CutSuffix(field, "%"); ok {
+			if number, ok := csvNumber(trimSpace(number), decimalComma); ok {
+				field = percentFraction(number)
+			}
+		} else if number, ok := csvNumber(field, decimalComma); ok {
+			field = number
+		}
+	case "currency", "currency-eur", "currency-usd", "currency-gbp":
+		if number, _, ok := csvCurrency(field, decimalComma); ok {
+			field = number
+		} else if number, ok := csvNumber(field, decimalComma); ok {
+			field = number
+		}
+	}
+	return MakeCell(field, valueType)
+}
+
+// csvNumber converts a number in the dialect of a CSV file to the form
+// [MakeCell] expects, without groups of thousands and with a decimal point.
+func csvNumber(field string, decimalComma bool) (string, bool) {
+	format := pointNumberFormat
+	if decimalComma {
+		format = commaNumberFormat
+	}
+	matches := format.FindStringSubmatch(field)
+	if matches == nil {
+		return "", false
+	}
+	number := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, matches[2])
+	if matches[1] == "-" || matches[1] == "−" {
+		number = "-" + number
+	}
+	if matches[3] != "" {
+		number += "." + matches[3]
+	}
+	return number, true
+}
+
+// csvCurrency converts an amount preceded or followed by a currency symbol
+// or code, and possibly a sign before both, to the number and value type of
+// a currency cell.
+func csvCurrency(field string, decimalComma bool) (string, string, bool) {
+	sign := ""
+	for _, s := range []string{"-", "+", "−"} {
+		if rest, ok := strings.CutPrefix(field, s); ok {
+			sign, field = s, rest
+			break
+		}
+	}
+	for _, c := range currencySymbols {
+		amount, ok := strings.CutPrefix(field, c.symbol)
+		if !ok {
+			amount, ok = strings.CutSuffix(field, c.symbol)
+		}
+		if !ok {
+			continue
+		}
+		number, ok := csvNumber(sign+trimSpace(amount), decimalComma)
+		return number, c.valueType, ok
+	}
+	return "", "", false
+}
+
+// percentFraction divides a number of the form csvNumber returns by 100,
+// moving its decimal point rather than computing with floats, which would
+// turn "12.34" into 0.12340000000000001.
+func percentFraction(number string) string {
+	sign := ""
+	if rest, ok := strings.CutPrefix(number, "-"); ok {
+		sign, number = "-", rest
+	}
+	whole, fraction, _ := strings.Cut(number, ".")
+	whole = strings.Repeat("0", max(3-len(whole), 0)) + whole
+	fraction = strings.TrimRight(whole[len(whole)-2:]+fraction, "0")
+	whole = strings.TrimLeft(whole[:len(whole)-2], "0")
+	if whole == "" {
+		whole = "0"
+	}
+	if fraction == "" {
+		return sign + whole
+	}
+	return sign + whole + "." + fraction
+}
+
+// trimSpace trims spaces, including the no-break spaces locales put between
+// numbers and their units.
+func trimSpace(s string) string {
+	return strings.Trim(s, " \t\u00a0\u202f")
+}
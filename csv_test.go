@@ -0,0 +1,143 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const germanCSV = "\ufeffDatum;Betrag;Anteil;Konto;PLZ;Beginn;Notiz\n" +
+	"01.03.2026;-1.234,56;12,5 %;€ 100,00;01067;08:15;Miete\n" +
+	"05.03.2026;300;7%;-12,50 €;80331;17:30:00;\n" +
+	"06.03.2026;;;;;;\"mit; Semikolon\"\n"
+
+func TestUnitReadCSV(t *testing.T) {
+	cells, err := ReadCSV(strings.NewReader(germanCSV), CSVOptions{DecimalComma: true, Header: true})
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+
+	assert(t, len(cells) == 4, fmt.Sprintf("expected a header and three rows, got %d rows", len(cells)))
+	assert(t, cells[0][0] == MakeCell("Datum", "string"), fmt.Sprintf("expected the byte order mark to be dropped, got %q", cells[0][0].Text))
+	expected := [][]Cell{
+		{MakeCell("2026-03-01", "date"), MakeCell("-1234.56", "float"), MakeCell("0.125", "percentage"), MakeCell("100.00", "currency-eur"), MakeCell("01067", "string"), MakeCell("08:15", "time"), MakeCell("Miete", "string")},
+		{MakeCell("2026-03-05", "date"), MakeCell("300", "float"), MakeCell("0.07", "percentage"), MakeCell("-12.50", "currency-eur"), MakeCell("80331", "string"), MakeCell("17:30:00", "time"), {}},
+		{MakeCell("2026-03-06", "date"), {}, {}, {}, {}, {}, MakeCell("mit; Semikolon", "string")},
+	}
+	for r, row := range expected {
+		for c, e := range row {
+			assert(t, cells[r+1][c] == e, fmt.Sprintf("row %d, column %d: expected %+v, got %+v", r+2, c+1, e, cells[r+1][c]))
+		}
+	}
+
+	// The column of amounts mixes a currency with plain numbers and is read
+	// as numbers, dropping the symbol, once its type is given by a hint.
+	cells, err = ReadCSV(strings.NewReader("Betrag:currency-eur,Tag:date,Zeit:x\n\"1,200.50 €\",3/1/2026,a\n-3,,b\n"), CSVOptions{HintHeader: true})
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	assert(t, cells[0][0].Text == "Betrag" && cells[0][2].Text == "Zeit:x", fmt.Sprintf("unexpected header %+v", cells[0]))
+	assert(t, cells[1][0] == MakeCell("1200.50", "currency-eur") && cells[2][0] == MakeCell("-3", "currency-eur"), fmt.Sprintf("unexpected amounts %+v, %+v", cells[1][0], cells[2][0]))
+	assert(t, cells[1][1].DateValue == "2026-03-01", "expected the US date to be read, got "+cells[1][1].DateValue)
+
+	// Types override the header, and ragged rows are kept as they are.
+	cells, err = ReadCSV(strings.NewReader("1,2\n3\n"), CSVOptions{Types: []string{"string", "percentage"}})
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	assert(t, cells[0][0] == MakeCell("1", "string") && cells[0][1] == MakeCell("2", "percentage") && len(cells[1]) == 1, fmt.Sprintf("unexpected cells %+v", cells))
+
+	// In a percentage column, bare numbers are fractions and only a percent
+	// sign marks a percent.
+	cells, err = ReadCSV(strings.NewReader("Rate:percentage\n0.42\n42 %\n"), CSVOptions{Header: true, HintHeader: true})
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+	assert(t, cells[1][0] == MakeCell("0.42", "percentage") && cells[2][0] == cells[1][0], fmt.Sprintf("unexpected percentages %+v", cells))
+}
+
+func TestUnitPercentFraction(t *testing.T) {
+	cases := map[string]string{
+		"42":     "0.42",
+		"12.34":  "0.1234",
+		"7":      "0.07",
+		"100":    "1",
+		"1250.5": "12.505",
+		"-0.5":   "-0.005",
+		"0":      "0",
+	}
+	for input, expected := range cases {
+		actual := percentFraction(input)
+		assert(t, actual == expected, fmt.Sprintf("%s %%: expected %s, got %s", input, expected, actual))
+	}
+}
+
+func TestUnitReadCSVErrors(t *testing.T) {
+	cases := map[string]struct {
+		csv  string
+		opts CSVOptions
+	}{
+		`row 2, column 2: invalid float value "abc"`: {"a,b\n1,abc\n", CSVOptions{Header: true, Types: []string{"", "float"}}},
+		`row 3, column 1: invalid date "soon"`:       {"Due:date\n1.3.2026\nsoon\n", CSVOptions{HintHeader: true}},
+		`column 2: unknown value type "money"`:       {"1,2\n", CSVOptions{Types: []string{"", "money"}}},
+		`row 2: parse error`:                         {"a,b\n\"unterminated\n", CSVOptions{}},
+	}
+	for expected, c := range cases {
+		t.Run(expected, func(t *testing.T) {
+			_, err := ReadCSV(strings.NewReader(c.csv), c.opts)
+			assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+		})
+	}
+}
+
+func TestUnitImportCSV(t *testing.T) {
+	spreadsheet, err := ImportCSV(strings.NewReader(germanCSV), CSVOptions{DecimalComma: true, Header: true, SheetName: "Konto", Table: &TableOptions{AutoFilter: true, Totals: []Total{{}, {TotalSum}}}})
+	if err != nil {
+		t.Fatalf("ImportCSV: %v", err)
+	}
+	assert(t, spreadsheet.Tables[0].Name == "Konto", "unexpected sheet name "+spreadsheet.Tables[0].Name)
+	totals := spreadsheet.Tables[0].Rows[4].Cells[1]
+	assert(t, totals.Value == "-934.56", fmt.Sprintf("expected the amounts to be summed, got %q = %q", totals.Formula, totals.Value))
+}
+
+func TestImportCSVMatchesOdfSchema(t *testing.T) {
+	spreadsheet, err := ImportCSV(strings.NewReader(germanCSV), CSVOptions{DecimalComma: true, Header: true, Table: &TableOptions{AutoFilter: true}})
+	if err != nil {
+		t.Fatalf("ImportCSV: %v", err)
+	}
+
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	validateAgainstSchema(t, "flat.fods", flatOds)
+}
+
+func TestReadCSV(t *testing.T) {
+	cells, err := ReadCSV(strings.NewReader(germanCSV), CSVOptions{DecimalComma: true, Header: true})
+	if err != nil {
+		t.Fatalf("ReadCSV: %v", err)
+	}
+
+	expectedThisCsv := map[string][][]string{
+		"en_US.UTF-8": {
+			{"Datum", "Betrag", "Anteil", "Konto", "PLZ", "Beginn", "Notiz"},
+			{"2026-03-01", "−1,234.56", "12.50%", "100.00€", "01067", "08:15:00", "Miete"},
+			{"2026-03-05", "300.00", "7.00%", "−12.50€", "80331", "17:30:00", ""},
+			{"2026-03-06", "", "", "", "", "", "mit; Semikolon"},
+		},
+		"de_DE.UTF-8": {
+			{"Datum", "Betrag", "Anteil", "Konto", "PLZ", "Beginn", "Notiz"},
+			{"2026-03-01", "−1.234,56", "12,50%", "100.00€", "01067", "08:15:00", "Miete"},
+			{"2026-03-05", "300,00", "7,00%", "−12.50€", "80331", "17:30:00", ""},
+			{"2026-03-06", "", "", "", "", "", "mit; Semikolon"},
+		},
+	}
+
+	integrationTest(t, "read-csv", "ods", cells, expectedThisCsv)
+	integrationTest(t, "read-csv", "fods", cells, expectedThisCsv)
+}
@@ -0,0 +1,315 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// CellAddress is the position of a cell of a spreadsheet, with 1-based row
+// and column.
+type CellAddress struct {
+	Sheet  string
+	Row    int
+	Column int
+}
+
+// String returns the address in the notation of formulas, such as
+// "Sheet1.B3" or "'Q1 2026'.B3".
+func (a CellAddress) String() string {
+	return fmt.Sprintf("%s.%s%d", quoteSheetName(a.Sheet), columnToLetters(a.Column), a.Row)
+}
+
+// DependencyGraph records which cells and names the formula cells of a
+// spreadsheet refer to. Create it with [MakeDependencyGraph].
+//
+// Ranges are taken apart into the cells they span, leaving out the positions
+// the sheet holds nothing at: SUM(A1:A1000) over ten rows of data depends on
+// ten cells. Formulas that cannot be parsed, such as those given in a
+// namespace other than OpenFormula's, have no precedents.
+type DependencyGraph struct {
+	// sheets holds the position of each sheet, to order cells as the
+	// document does.
+	sheets     map[string]int
+	formulas   []CellAddress
+	precedents map[CellAddress][]CellAddress
+	dependents map[CellAddress][]CellAddress
+	names      map[CellAddress][]string
+	// nameDependents holds the formula cells referring to each name.
+	nameDependents map[string][]CellAddress
+}
+
+// MakeDependencyGraph builds the dependency graph of the formula cells of
+// spreadsheet, which may have been built by this package or read with
+// [ReadOds].
+func MakeDependencyGraph(spreadsheet Spreadsheet) DependencyGraph {
+	return makeDependencyGraph(spreadsheet.Tables, spreadsheet.NamedExpressions)
+}
+
+func makeDependencyGraph(tables []table, names namedExpressions) DependencyGraph {
+	g := DependencyGraph{
+		sheets:         map[string]int{},
+		precedents:     map[CellAddress][]CellAddress{},
+		dependents:     map[CellAddress][]CellAddress{},
+		names:          map[CellAddress][]string{},
+		nameDependents: map[string][]CellAddress{},
+	}
+	for i, t := range tables {
+		g.sheets[t.Name] = i
+	}
+
+	e := newFormulaEvaluator(tables, names)
+	for _, t := range tables {
+		for r, row := range t.Rows {
+			for c, cell := range row.Cells {
+				if cell.Formula == "" {
+					continue
+				}
+				addr := CellAddress{Sheet: t.Name, Row: r + 1, Column: c + 1}
+				g.formulas = append(g.formulas, addr)
+				g.addFormula(e, tables, addr, cell.Formula)
+				if cell.isMatrix() {
+					g.addMatrix(addr, cell)
+				}
+			}
+		}
+	}
+
+	for addr, precedents := range g.precedents {
+		slices.SortFunc(precedents, g.compare)
+		g.precedents[addr] = slices.Compact(precedents)
+		for _, p := range g.precedents[addr] {
+			g.dependents[p] = append(g.dependents[p], addr)
+		}
+	}
+	for _, dependents := range g.dependents {
+		slices.SortFunc(dependents, g.compare)
+	}
+	for addr, names := range g.names {
+		slices.Sort(names)
+		g.names[addr] = slices.Compact(names)
+		for _, name := range g.names[addr] {
+			g.nameDependents[name] = append(g.nameDependents[name], addr)
+		}
+	}
+	for _, dependents := range g.nameDependents {
+		slices.SortFunc(dependents, g.compare)
+	}
+	return g
+}
+
+// addFormula records the cells and names the formula of the cell at addr
+// refers to.
+func (g *DependencyGraph) addFormula(e *formulaEvaluator, tables []table, addr CellAddress, formula string) {
+	expression, ok := strings.CutPrefix(formula, formulaNamespace+"=")
+	if !ok {
+		return
+	}
+	node, err := parseFormula(expression, openFormulaNotation)
+	if err != nil {
+		return
+	}
+
+	walkFormula(node, func(node formulaNode) bool {
+		if n, ok := node.(nameNode); ok {
+			g.names[addr] = append(g.names[addr], n.name)
+		}
+		return true
+	})
+	// The cells of named expressions count as precedents of the formulas
+	// using them.
+	node, err = e.expand(node, addr.Sheet, nil)
+	if err != nil {
+		return
+	}
+	walkFormula(node, func(node formulaNode) bool {
+		if !isReferenceExpression(node) {
+			return true
+		}
+		ref, errValue := e.resolve(node, addr.Sheet)
+		if errValue != nil {
+			return true
+		}
+		first, last := g.sheets[ref.start.sheet], g.sheets[ref.end.sheet]
+		for _, t := range tables[min(first, last) : max(first, last)+1] {
+			ref := clampRange(ref, e.extents[t.Name])
+			for r := ref.start.row; r <= ref.end.row; r++ {
+				for c := ref.start.col; c <= ref.end.col; c++ {
+					if e.cell(cellAddress{sheet: t.Name, row: r, col: c}) != nil {
+						g.precedents[addr] = append(g.precedents[addr], CellAddress{Sheet: t.Name, Row: r, Column: c})
+					}
+				}
+			}
+		}
+		return false
+	})
+}
+
+// addMatrix records the cells covered by the matrix formula of the cell at
+// addr as depending on it.
+func (g *DependencyGraph) addMatrix(addr CellAddress, cell Cell) {
+	rows, columns := cell.matrixSize()
+	for r := addr.Row; r < addr.Row+rows; r++ {
+		for c := addr.Column; c < addr.Column+columns; c++ {
+			covered := CellAddress{Sheet: addr.Sheet, Row: r, Column: c}
+			if covered != addr {
+				g.precedents[covered] = append(g.precedents[covered], addr)
+			}
+		}
+	}
+}
+
+// compare orders cells as the document does: by sheet, row, and column.
+func (g DependencyGraph) compare(a, b CellAddress) int {
+	return cmp.Or(cmp.Compare(g.sheets[a.Sheet], g.sheets[b.Sheet]), cmp.Compare(a.Row, b.Row), cmp.Compare(a.Column, b.Column))
+}
+
+// Precedents returns the cells the formula in cell refers to directly,
+// including those of the names it uses, in document order. A cell
+// covered by a matrix formula has the cell holding the formula as its
+// precedent. Precedents returns nil if cell holds no formula.
+func (g DependencyGraph) Precedents(cell CellAddress) []CellAddress {
+	return slices.Clone(g.precedents[cell])
+}
+
+// Dependents returns the formula cells that refer to cell directly, in
+// document order.
+func (g DependencyGraph) Dependents(cell CellAddress) []CellAddress {
+	return slices.Clone(g.dependents[cell])
+}
+
+// Names returns the names the formula in cell refers to directly, sorted.
+func (g DependencyGraph) Names(cell CellAddress) []string {
+	return slices.Clone(g.names[cell])
+}
+
+// NameDependents returns the formula cells that refer to the name directly,
+// in document order.
+func (g DependencyGraph) NameDependents(name string) []CellAddress {
+	return slices.Clone(g.nameDependents[name])
+}
+
+// Cycles returns the circular references among the formula cells, which
+// office applications cannot compute. Each is given as the cells on it, in
+// the order they refer to each other, beginning with the first in document
+// order; a formula referring to its own cell is a cycle of one.
+func (g DependencyGraph) Cycles() [][]CellAddress {
+	var cycles [][]CellAddress
+	for _, component := range g.components() {
+		if len(component) == 1 && !slices.Contains(g.precedents[component[0]], component[0]) {
+			continue
+		}
+		cycles = append(cycles, g.cycleThrough(component))
+	}
+	return cycles
+}
+
+// components returns the strongly connected components of the graph, by
+// Tarjan's algorithm, each sorted and in the document order of their first
+// cell.
+func (g DependencyGraph) components() [][]CellAddress {
+	index := map[CellAddress]int{}
+	lowLink := map[CellAddress]int{}
+	onStack := map[CellAddress]bool{}
+	var stack []CellAddress
+	var components [][]CellAddress
+
+	var connect func(v CellAddress)
+	connect = func(v CellAddress) {
+		index[v] = len(index)
+		lowLink[v] = index[v]
+		stack = append(stack, v)
+		onStack[v] = true
+		for _, w := range g.precedents[v] {
+			if _, visited := index[w]; !visited {
+				connect(w)
+				lowLink[v] = min(lowLink[v], lowLink[w])
+			} else if onStack[w] {
+				lowLink[v] = min(lowLink[v], index[w])
+			}
+		}
+		if lowLink[v] != index[v] {
+			return
+		}
+		var component []CellAddress
+		for {
+			w := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			onStack[w] = false
+			component = append(component, w)
+			if w == v {
+				break
+			}
+		}
+		slices.SortFunc(component, g.compare)
+		components = append(components, component)
+	}
+
+	for _, addr := range g.formulas {
+		if _, visited := index[addr]; !visited {
+			connect(addr)
+		}
+	}
+	slices.SortFunc(components, func(a, b []CellAddress) int { return g.compare(a[0], b[0]) })
+	return components
+}
+
+// cycleThrough returns the shortest cycle from the first cell of a strongly
+// connected component back to it.
+func (g DependencyGraph) cycleThrough(component []CellAddress) []CellAddress {
+	start := component[0]
+	previous := map[CellAddress]CellAddress{}
+	queue := []CellAddress{start}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range g.precedents[v] {
+			if w == start {
+				cycle := []CellAddress{v}
+				for v != start {
+					v = previous[v]
+					cycle = append(cycle, v)
+				}
+				slices.Reverse(cycle)
+				return cycle
+			}
+			if _, seen := previous[w]; !seen && slices.Contains(component, w) {
+				previous[w] = v
+				queue = append(queue, w)
+			}
+		}
+	}
+	return component
+}
+
+// cycleErrors reports the circular references as a single joined error for
+// each sheet they begin on, with the cells on them given relative to that
+// sheet.
+func (g DependencyGraph) cycleErrors() map[string]error {
+	errs := map[string][]error{}
+	for _, cycle := range g.Cycles() {
+		sheet := cycle[0].Sheet
+		var path []string
+		for _, addr := range append(cycle, cycle[0]) {
+			if addr.Sheet == sheet {
+				path = append(path, columnToLetters(addr.Column)+strconv.Itoa(addr.Row))
+			} else {
+				path = append(path, addr.String())
+			}
+		}
+		errs[sheet] = append(errs[sheet], fmt.Errorf("row %d, column %d: circular reference %s", cycle[0].Row, cycle[0].Column, strings.Join(path, " -> ")))
+	}
+
+	joined := map[string]error{}
+	for sheet, sheetErrs := range errs {
+		joined[sheet] = errors.Join(sheetErrs...)
+	}
+	return joined
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestUnitDependencyGraph(t *testing.T) {
+	spreadsheet, err := MakeWorkbook(
+		MakeSheet("Budget", [][]Cell{
+			{MakeRangeCell("100", "float", "Rent"), MakeCell("50", "float"), MakeCell("SUM(A1:B1;D1:E1)", "formula")},
+			{MakeCell("Rent*12", "formula"), MakeCell("C1+A2+Data.A1", "formula")},
+		}),
+		MakeSheet("Data", [][]Cell{{MakeCell("7", "float")}}),
+	)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+	graph := MakeDependencyGraph(spreadsheet)
+
+	budget := func(row, column int) CellAddress { return CellAddress{Sheet: "Budget", Row: row, Column: column} }
+	data := CellAddress{Sheet: "Data", Row: 1, Column: 1}
+
+	cases := []struct {
+		name     string
+		actual   any
+		expected any
+	}{
+		// The range leaves out the positions the sheet holds nothing at.
+		{"precedents of a range", graph.Precedents(budget(1, 3)), []CellAddress{budget(1, 1), budget(1, 2)}},
+		{"precedents of a name", graph.Precedents(budget(2, 1)), []CellAddress{budget(1, 1)}},
+		{"precedents across sheets", graph.Precedents(budget(2, 2)), []CellAddress{budget(1, 3), budget(2, 1), data}},
+		{"precedents of a value", graph.Precedents(budget(1, 1)), []CellAddress(nil)},
+		{"dependents", graph.Dependents(budget(1, 1)), []CellAddress{budget(1, 3), budget(2, 1)}},
+		{"dependents across sheets", graph.Dependents(data), []CellAddress{budget(2, 2)}},
+		{"names", graph.Names(budget(2, 1)), []string{"Rent"}},
+		{"name dependents", graph.NameDependents("Rent"), []CellAddress{budget(2, 1)}},
+		{"cycles", graph.Cycles(), [][]CellAddress(nil)},
+	}
+	for _, c := range cases {
+		assert(t, reflect.DeepEqual(c.actual, c.expected), fmt.Sprintf("%s: expected %v, got %v", c.name, c.expected, c.actual))
+	}
+
+	assert(t, budget(2, 1).String() == "Budget.A2", "expected the address in formula notation")
+	assert(t, (CellAddress{Sheet: "Q1 2026", Row: 3, Column: 28}).String() == "'Q1 2026'.AB3", "expected the sheet name quoted")
+}
+
+func TestUnitDependencyGraphCycles(t *testing.T) {
+	formula := func(f string) Cell {
+		return Cell{Formula: "of:=" + f}
+	}
+	tables := []table{
+		{Name: "Sheet1", Rows: []row{
+			{Cells: []Cell{formula("[.B1]"), formula("[.C1]+[Sheet2.A1]"), formula("[.A1]")}},
+			{Cells: []Cell{formula("SUM([.A2:.B2])"), formula("1")}},
+		}},
+		{Name: "Sheet2", Rows: []row{{Cells: []Cell{formula("[Sheet1.C1]+Total")}}}},
+	}
+	graph := makeDependencyGraph(tables, namedExpressions{NamedRanges: []namedRange{{Name: "Total", CellRangeAddress: "$Sheet1.$B$1"}}})
+
+	a := func(sheet string, row, column int) CellAddress {
+		return CellAddress{Sheet: sheet, Row: row, Column: column}
+	}
+	expected := [][]CellAddress{
+		{a("Sheet1", 1, 1), a("Sheet1", 1, 2), a("Sheet1", 1, 3)},
+		{a("Sheet1", 2, 1)},
+	}
+	cycles := graph.Cycles()
+	assert(t, len(cycles) == 2, fmt.Sprintf("expected two cycles, got %v", cycles))
+	// The shortest way around the first component, which Sheet2.A1 is part
+	// of too.
+	assert(t, reflect.DeepEqual(cycles[0], expected[0]), fmt.Sprintf("expected %v, got %v", expected[0], cycles[0]))
+	assert(t, reflect.DeepEqual(cycles[1], expected[1]), fmt.Sprintf("expected %v, got %v", expected[1], cycles[1]))
+}
+
+func TestUnitMakeSpreadsheetReportsCycles(t *testing.T) {
+	_, err := MakeSpreadsheet([][]Cell{
+		{MakeCell("1", "float"), MakeCell("SUM(A1:B1)", "formula")},
+		{MakeRangeCell("C2*2", "formula", "Total"), MakeCell("A1", "formula"), MakeCell("Total+1", "formula")},
+	})
+	if err == nil {
+		t.Fatal("expected an error for circular references")
+	}
+	for _, expected := range []string{
+		"row 1, column 2: circular reference B1 -> B1",
+		"row 2, column 1: circular reference A2 -> C2 -> A2",
+	} {
+		assert(t, strings.Contains(err.Error(), expected), fmt.Sprintf("expected %q in the error, got: %v", expected, err))
+	}
+
+	_, err = MakeWorkbook(
+		MakeSheet("A", [][]Cell{{MakeCell("B.A1", "formula")}}),
+		MakeSheet("B", [][]Cell{{MakeCell("A.A1", "formula")}}),
+	)
+	expected := `sheet "A": row 1, column 1: circular reference A1 -> B.A1 -> A1`
+	if err == nil || !strings.Contains(err.Error(), expected) {
+		t.Errorf("expected an error containing %q, got: %v", expected, err)
+	}
+}
@@ -0,0 +1,967 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Formula cells are evaluated when a spreadsheet is built, and the result is
+// stored in the cell next to the formula, the way office applications cache
+// the values they computed. Consumers that do not recalculate (pandas, file
+// previewers, ReadOds) would otherwise see the cell as empty.
+//
+// The evaluator covers the common subset of OpenFormula: arithmetic,
+// comparison, text concatenation, date arithmetic, and the functions listed in
+// formulaEvaluator.call. A formula it cannot evaluate, because it calls another
+// function, refers to itself, or is given in a namespace other than "of:", is
+// stored without a result and left to the consumer to compute.
+
+// errNotEvaluable is returned for formulas the evaluator does not cover. It
+// is not a formula error: the cell is written without a cached result.
+var errNotEvaluable = errors.New("formula cannot be evaluated")
+
+// Codes of the formula errors, as office applications display them.
+const (
+	errorDivisionByZero = "#DIV/0!"
+	errorValueType      = "#VALUE!"
+	errorName           = "#NAME?"
+	errorReference      = "#REF!"
+	errorNumber         = "#NUM!"
+)
+
+// dateEpoch is day zero of the serial date numbers formulas compute with, as
+// in all office applications.
+var dateEpoch = time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)
+
+// serialDate returns the serial date number of t. It counts in seconds
+// since the Unix epoch rather than in a time.Duration, which only spans the
+// years 1678 to 2262.
+func serialDate(t time.Time) float64 {
+	return float64(t.Unix()-dateEpoch.Unix()) / 86400
+}
+
+// The serial date numbers of the first day of the year 1 and of the day after
+// the last of the year 9999, the dates office applications show.
+var (
+	firstSerialDate = serialDate(time.Date(1, 1, 1, 0, 0, 0, 0, time.UTC))
+	endSerialDate   = serialDate(time.Date(10000, 1, 1, 0, 0, 0, 0, time.UTC))
+)
+
+type formulaValueKind int
+
+const (
+	emptyValue formulaValueKind = iota
+	numberValue
+	textValue
+	booleanValue
+	errorValue
+)
+
+// formulaValue is the result of evaluating an expression.
+type formulaValue struct {
+	kind    formulaValueKind
+	number  float64
+	boolean bool
+	// text is the text of a textValue and the code of an errorValue.
+	text string
+	// numberType is "date" or "time" for numbers that denote one, so that
+	// date arithmetic keeps its type, and empty for plain numbers.
+	numberType string
+}
+
+func numberOf(n float64) formulaValue       { return formulaValue{kind: numberValue, number: n} }
+func textOf(s string) formulaValue          { return formulaValue{kind: textValue, text: s} }
+func booleanOf(b bool) formulaValue         { return formulaValue{kind: booleanValue, boolean: b} }
+func formulaError(code string) formulaValue { return formulaValue{kind: errorValue, text: code} }
+
+// formulaEvaluator evaluates the formula cells of a spreadsheet and stores
+// their results.
+type formulaEvaluator struct {
+	tables map[string]*table
+	// extents holds the extent of each table, taken once rather than for
+	// each reference.
+	extents map[string]tableExtent
+	// names holds the cells of named ranges, expressions the formulas of
+	// named expressions, by the sheet they are scoped to, which is empty for
+	// those of the whole workbook. A nil formula could not be parsed.
+	names       map[nameKey]cellRange
+	expressions map[nameKey]formulaNode
+
+	// state records the formula cells evaluated or being evaluated, the
+	// latter to detect circular references.
+	state map[cellAddress]evaluationState
+}
+
+type evaluationState struct {
+	done   bool
+	result formulaValue
+	err    error
+}
+
+// nameKey identifies a name by the sheet it is scoped to and its name in
+// upper case, as names are compared without regard to case.
+type nameKey struct {
+	sheet, name string
+}
+
+// evaluateFormulas stores the results of all formula cells of tables, which
+// the workbook-wide names refer to along with those the tables define.
+func evaluateFormulas(tables []table, names namedExpressions) {
+	e := newFormulaEvaluator(tables, names)
+	for _, t := range tables {
+		for r, row := range t.Rows {
+			for c := range row.Cells {
+				cell := &row.Cells[c]
+				if cell.Formula == "" {
+					continue
+				}
+				result, err := e.evaluateCell(cellAddress{sheet: t.Name, row: r + 1, col: c + 1})
+				if err != nil {
+					clearResult(cell)
+					continue
+				}
+				storeResult(cell, result)
+			}
+		}
+	}
+}
+
+func newFormulaEvaluator(tables []table, names namedExpressions) *formulaEvaluator {
+	e := &formulaEvaluator{
+		tables:      map[string]*table{},
+		extents:     map[string]tableExtent{},
+		names:       map[nameKey]cellRange{},
+		expressions: map[nameKey]formulaNode{},
+		state:       map[cellAddress]evaluationState{},
+	}
+	e.addNames("", names)
+	for i := range tables {
+		e.tables[tables[i].Name] = &tables[i]
+		e.extents[tables[i].Name] = extentOf(tables[i])
+		if tables[i].NamedExpressions != nil {
+			e.addNames(tables[i].Name, *tables[i].NamedExpressions)
+		}
+	}
+	return e
+}
+
+// addNames adds the names scoped to sheet, or to the whole workbook if sheet
+// is empty.
+func (e *formulaEvaluator) addNames(sheet string, names namedExpressions) {
+	for _, nr := range names.NamedRanges {
+		if ref, err := parseRangeAddress(nr.CellRangeAddress); err == nil {
+			e.names[nameKey{sheet, strings.ToUpper(nr.Name)}] = ref
+		}
+	}
+	for _, ne := range names.NamedExpressions {
+		// Office applications write expressions with and without the
+		// namespace prefix of formulas.
+		expression := strings.TrimPrefix(ne.Expression, formulaNamespace)
+		expression = strings.TrimPrefix(expression, "=")
+		node, err := parseFormula(expression, openFormulaNotation)
+		if err != nil {
+			node = nil
+		}
+		e.expressions[nameKey{sheet, strings.ToUpper(ne.Name)}] = node
+	}
+}
+
+// lookupName returns the key of the name as seen from sheet: the name scoped
+// to sheet if there is one, or else the workbook-wide one.
+func lookupName[V any](names map[nameKey]V, sheet, name string) (nameKey, V, bool) {
+	name = strings.ToUpper(name)
+	key := nameKey{sheet, name}
+	if v, ok := names[key]; ok {
+		return key, v, true
+	}
+	key = nameKey{"", name}
+	v, ok := names[key]
+	return key, v, ok
+}
+
+// expand replaces the named expressions node refers to, as seen from sheet,
+// by their formulas in parentheses. Within those, named ranges are replaced by
+// their cells too, as they are looked up where the expression is defined
+// rather than where it is used. Expressions that refer to themselves or
+// cannot be parsed make node not evaluable.
+func (e *formulaEvaluator) expand(node formulaNode, sheet string, seen []nameKey) (formulaNode, error) {
+	return replaceFormula(node, func(node formulaNode) (formulaNode, bool, error) {
+		n, ok := node.(nameNode)
+		if !ok {
+			return nil, false, nil
+		}
+		key, expression, ok := lookupName(e.expressions, sheet, n.name)
+		if !ok {
+			if _, ref, ok := lookupName(e.names, sheet, n.name); ok && len(seen) > 0 {
+				return referenceNode{address: absoluteAddress(ref), ref: ref}, true, nil
+			}
+			return nil, false, nil
+		}
+		if expression == nil || slices.Contains(seen, key) {
+			return nil, true, errNotEvaluable
+		}
+		expanded, err := e.expand(expression, key.sheet, append(slices.Clip(seen), key))
+		if err != nil {
+			return nil, true, err
+		}
+		return parenNode{inner: expanded}, true, nil
+	})
+}
+
+// cell returns the cell at addr, or nil if the sheet holds nothing there.
+func (e *formulaEvaluator) cell(addr cellAddress) *Cell {
+	t := e.tables[addr.sheet]
+	if t == nil || addr.row > len(t.Rows) || addr.col > len(t.Rows[addr.row-1].Cells) {
+		return nil
+	}
+	return &t.Rows[addr.row-1].Cells[addr.col-1]
+}
+
+// evaluateCell returns the value of the cell at addr, evaluating it if it
+// holds a formula.
+func (e *formulaEvaluator) evaluateCell(addr cellAddress) (formulaValue, error) {
+	cell := e.cell(addr)
+	if cell == nil {
+		return formulaValue{}, nil
+	}
+	if cell.isMatrix() || (cell.covered && isEmptyCell(*cell)) {
+		// The results of matrix formulas are left to consumers, apart from
+		// those read from a document.
+		return formulaValue{}, errNotEvaluable
+	}
+	if cell.Formula == "" {
+		return cellValue(cell), nil
+	}
+
+	if state, seen := e.state[addr]; seen {
+		if !state.done {
+			// A circular reference, which consumers report as an error of
+			// their own (Err:522 in LibreOffice).
+			return formulaValue{}, errNotEvaluable
+		}
+		return state.result, state.err
+	}
+	e.state[addr] = evaluationState{}
+
+	var result formulaValue
+	var err error
+	expression, ok := strings.CutPrefix(cell.Formula, formulaNamespace+"=")
+	if !ok {
+		err = errNotEvaluable
+	} else if node, parseErr := parseFormula(expression, openFormulaNotation); parseErr != nil {
+		err = errNotEvaluable
+	} else if node, err = e.expand(node, addr.sheet, nil); err == nil {
+		result, err = e.scalar(node, addr.sheet)
+	}
+	e.state[addr] = evaluationState{done: true, result: result, err: err}
+	return result, err
+}
+
+// cellValue returns the value of a cell that holds no formula.
+func cellValue(cell *Cell) formulaValue {
+	switch cell.ValueType {
+	case "string":
+		return textOf(cell.Text)
+	case "float", "percentage", "currency":
+		n, err := strconv.ParseFloat(cell.Value, 64)
+		if err != nil {
+			return formulaError(errorValueType)
+		}
+		return numberOf(n)
+	case "date":
+		for _, layout := range []string{"2006-01-02", "2006-01-02T15:04:05"} {
+			if t, err := time.Parse(layout, cell.DateValue); err == nil {
+				v := numberOf(serialDate(t))
+				v.numberType = "date"
+				return v
+			}
+		}
+		return formulaError(errorValueType)
+	case "time":
+		var hours, minutes, seconds float64
+		if _, err := fmt.Sscanf(cell.TimeValue, "PT%fH%fM%fS", &hours, &minutes, &seconds); err != nil {
+			return formulaError(errorValueType)
+		}
+		v := numberOf((hours*3600 + minutes*60 + seconds) / 86400)
+		v.numberType = "time"
+		return v
+	case "boolean":
+		return booleanOf(cell.BooleanValue == "true")
+	case "":
+		if cell.Text != "" {
+			return textOf(cell.Text)
+		}
+		return formulaValue{}
+	}
+	return formulaError(errorValueType)
+}
+
+// scalar evaluates node to a single value. A reference to more than one
+// cell is a #VALUE! error in this context.
+func (e *formulaEvaluator) scalar(node formulaNode, sheet string) (formulaValue, error) {
+	switch n := node.(type) {
+	case numberNode:
+		return numberOf(n.value), nil
+	case textNode:
+		return textOf(n.text), nil
+	case booleanNode:
+		return booleanOf(n.value), nil
+	case missingNode:
+		return formulaValue{}, nil
+	case parenNode:
+		if !isReferenceExpression(n.inner) {
+			return e.scalar(n.inner, sheet)
+		}
+	}
+
+	if isReferenceExpression(node) {
+		ref, errValue := e.resolve(node, sheet)
+		if errValue != nil {
+			return *errValue, nil
+		}
+		if ref.start.sheet != ref.end.sheet || ref.start.row != ref.end.row || ref.start.col != ref.end.col {
+			return formulaError(errorValueType), nil
+		}
+		return e.evaluateCell(ref.start)
+	}
+
+	switch n := node.(type) {
+	case unaryNode:
+		v, err := e.scalar(n.operand, sheet)
+		if err != nil || v.kind == errorValue {
+			return v, err
+		}
+		x, errValue := toNumber(v)
+		if errValue != nil {
+			return *errValue, nil
+		}
+		switch n.op {
+		case "-":
+			return numberOf(-x), nil
+		case "%":
+			return numberOf(x / 100), nil
+		}
+		return formulaValue{kind: numberValue, number: x, numberType: v.numberType}, nil
+	case binaryNode:
+		return e.binary(n, sheet)
+	case callNode:
+		return e.call(n, sheet)
+	}
+	return formulaValue{}, errNotEvaluable
+}
+
+// isReferenceExpression reports whether node denotes cells rather than a
+// value: a reference, a name, or the range spanned by two of them.
+func isReferenceExpression(node formulaNode) bool {
+	switch n := node.(type) {
+	case referenceNode, nameNode:
+		return true
+	case parenNode:
+		return isReferenceExpression(n.inner)
+	case binaryNode:
+		return n.op == ":" && isReferenceExpression(n.left) && isReferenceExpression(n.right)
+	}
+	return false
+}
+
+// resolve returns the cells a reference expression refers to, with the sheet
+// filled in, or the error value to evaluate to instead.
+func (e *formulaEvaluator) resolve(node formulaNode, sheet string) (cellRange, *formulaValue) {
+	var ref cellRange
+	switch n := node.(type) {
+	case referenceNode:
+		ref = n.ref
+	case nameNode:
+		_, named, ok := lookupName(e.names, sheet, n.name)
+		if !ok {
+			v := formulaError(errorName)
+			return ref, &v
+		}
+		ref = named
+	case parenNode:
+		return e.resolve(n.inner, sheet)
+	case binaryNode:
+		// The range spanned by both sides, which have to be on one sheet.
+		left, errValue := e.resolve(n.left, sheet)
+		if errValue != nil {
+			return ref, errValue
+		}
+		right, errValue := e.resolve(n.right, sheet)
+		if errValue != nil {
+			return ref, errValue
+		}
+		if left.start.sheet != right.start.sheet || left.end.sheet != left.start.sheet || right.end.sheet != right.start.sheet {
+			v := formulaError(errorReference)
+			return ref, &v
+		}
+		ref = cellRange{
+			start: cellAddress{sheet: left.start.sheet, row: min(left.start.row, left.end.row, right.start.row, right.end.row), col: min(left.start.col, left.end.col, right.start.col, right.end.col)},
+			end:   cellAddress{sheet: left.start.sheet, row: max(left.start.row, left.end.row, right.start.row, right.end.row), col: max(left.start.col, left.end.col, right.start.col, right.end.col)},
+		}
+	}
+	if ref.start.sheet == "" {
+		ref.start.sheet = sheet
+	}
+	if ref.end.sheet == "" {
+		ref.end.sheet = ref.start.sheet
+	}
+	if e.tables[ref.start.sheet] == nil || e.tables[ref.end.sheet] == nil {
+		v := formulaError(errorReference)
+		return ref, &v
+	}
+	return ref, nil
+}
+
+// values returns the values of the cells of ref, row by row, leaving out the
+// positions the sheet holds nothing at.
+func (e *formulaEvaluator) values(ref cellRange) ([]formulaValue, error) {
+	if ref.start.sheet != ref.end.sheet {
+		// 3D ranges spanning several sheets are left to the consumer.
+		return nil, errNotEvaluable
+	}
+	var values []formulaValue
+	ref = clampRange(ref, e.extents[ref.start.sheet])
+	for r := ref.start.row; r <= ref.end.row; r++ {
+		for c := ref.start.col; c <= ref.end.col; c++ {
+			addr := cellAddress{sheet: ref.start.sheet, row: r, col: c}
+			if e.cell(addr) == nil {
+				continue
+			}
+			v, err := e.evaluateCell(addr)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// tableExtent is the number of rows and columns a table holds cells in.
+type tableExtent struct {
+	rows, columns int
+}
+
+func extentOf(t table) tableExtent {
+	extent := tableExtent{rows: len(t.Rows)}
+	for _, r := range t.Rows {
+		extent.columns = max(extent.columns, len(r.Cells))
+	}
+	return extent
+}
+
+// clampRange returns ref with its ends ordered and limited to the extent of
+// its table, so that whole columns and rows are not walked to the end of the
+// sheet. It is empty, its start beyond its end, if ref lies outside of it.
+func clampRange(ref cellRange, extent tableExtent) cellRange {
+	return cellRange{
+		start: cellAddress{sheet: ref.start.sheet, row: min(ref.start.row, ref.end.row), col: min(ref.start.col, ref.end.col)},
+		end:   cellAddress{sheet: ref.end.sheet, row: min(max(ref.start.row, ref.end.row), extent.rows), col: min(max(ref.start.col, ref.end.col), extent.columns)},
+	}
+}
+
+func (e *formulaEvaluator) binary(n binaryNode, sheet string) (formulaValue, error) {
+	left, err := e.scalar(n.left, sheet)
+	if err != nil {
+		return left, err
+	}
+	right, err := e.scalar(n.right, sheet)
+	if err != nil {
+		return right, err
+	}
+	if left.kind == errorValue {
+		return left, nil
+	}
+	if right.kind == errorValue {
+		return right, nil
+	}
+
+	switch n.op {
+	case "&":
+		return textOf(toText(left) + toText(right)), nil
+	case "=", "<>", "<", ">", "<=", ">=":
+		return booleanOf(compareResult(n.op, compareValues(left, right))), nil
+	}
+
+	x, errValue := toNumber(left)
+	if errValue != nil {
+		return *errValue, nil
+	}
+	y, errValue := toNumber(right)
+	if errValue != nil {
+		return *errValue, nil
+	}
+	var result formulaValue
+	switch n.op {
+	case "+":
+		result = numberOf(x + y)
+		result.numberType = sumType(left.numberType, right.numberType)
+	case "-":
+		result = numberOf(x - y)
+		result.numberType = differenceType(left.numberType, right.numberType)
+	case "*":
+		result = numberOf(x * y)
+	case "/":
+		if y == 0 {
+			return formulaError(errorDivisionByZero), nil
+		}
+		result = numberOf(x / y)
+	case "^":
+		result = numberOf(math.Pow(x, y))
+	default:
+		return formulaValue{}, errNotEvaluable
+	}
+	if math.IsNaN(result.number) || math.IsInf(result.number, 0) {
+		return formulaError(errorNumber), nil
+	}
+	return result, nil
+}
+
+// sumType returns the type of the sum of numbers of the given types: a date
+// moved by a number of days or a time of day is a date, and two times add up
+// to a time.
+func sumType(a, b string) string {
+	switch {
+	case a == "date" && b != "date", b == "date" && a != "date":
+		return "date"
+	case a == "time" && b == "time":
+		return "time"
+	}
+	return ""
+}
+
+// differenceType returns the type of the difference of numbers of the given
+// types: two dates are a number of days apart, a date moved back is a date.
+func differenceType(a, b string) string {
+	switch {
+	case a == "date" && b != "date":
+		return "date"
+	case a == "time" && b == "time":
+		return "time"
+	}
+	return ""
+}
+
+// toNumber converts a value to a number for arithmetic: empty cells count as
+// zero, booleans as one and zero, and text only if it spells a number.
+func toNumber(v formulaValue) (float64, *formulaValue) {
+	switch v.kind {
+	case numberValue:
+		return v.number, nil
+	case booleanValue:
+		if v.boolean {
+			return 1, nil
+		}
+		return 0, nil
+	case textValue:
+		n, err := strconv.ParseFloat(strings.TrimSpace(v.text), 64)
+		if err != nil {
+			e := formulaError(errorValueType)
+			return 0, &e
+		}
+		return n, nil
+	case errorValue:
+		return 0, &v
+	}
+	return 0, nil
+}
+
+// toText converts a value to text for concatenation.
+func toText(v formulaValue) string {
+	switch v.kind {
+	case numberValue:
+		return formatGeneral(v.number)
+	case textValue, errorValue:
+		return v.text
+	case booleanValue:
+		if v.boolean {
+			return "TRUE"
+		}
+		return "FALSE"
+	}
+	return ""
+}
+
+// compareValues orders two values: numbers before text before booleans, text
+// compared without regard to case. An empty cell compares like the zero value
+// of the other side.
+func compareValues(a, b formulaValue) int {
+	if a.kind == emptyValue {
+		a = zeroLike(b)
+	}
+	if b.kind == emptyValue {
+		b = zeroLike(a)
+	}
+	if a.kind != b.kind {
+		return compareNumbers(float64(kindOrder(a.kind)), float64(kindOrder(b.kind)))
+	}
+	switch a.kind {
+	case numberValue:
+		return compareNumbers(a.number, b.number)
+	case textValue:
+		return strings.Compare(strings.ToLower(a.text), strings.ToLower(b.text))
+	case booleanValue:
+		x, _ := toNumber(a)
+		y, _ := toNumber(b)
+		return compareNumbers(x, y)
+	}
+	return 0
+}
+
+func zeroLike(v formulaValue) formulaValue {
+	switch v.kind {
+	case textValue:
+		return textOf("")
+	case booleanValue:
+		return booleanOf(false)
+	}
+	return numberOf(0)
+}
+
+func kindOrder(kind formulaValueKind) int {
+	switch kind {
+	case numberValue:
+		return 0
+	case textValue:
+		return 1
+	}
+	return 2
+}
+
+func compareNumbers(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func compareResult(op string, cmp int) bool {
+	switch op {
+	case "=":
+		return cmp == 0
+	case "<>":
+		return cmp != 0
+	case "<":
+		return cmp < 0
+	case ">":
+		return cmp > 0
+	case "<=":
+		return cmp <= 0
+	}
+	return cmp >= 0
+}
+
+// call evaluates a function call. The functions known are the ones listed
+// here; any other leaves the formula to the consumer.
+func (e *formulaEvaluator) call(n callNode, sheet string) (formulaValue, error) {
+	name := strings.ToUpper(n.name)
+	switch name {
+	case "SUM":
+		return e.aggregate(n.args, sheet, 9)
+	case "AVERAGE":
+		return e.aggregate(n.args, sheet, 1)
+	case "COUNT":
+		return e.aggregate(n.args, sheet, 2)
+	case "MAX":
+		return e.aggregate(n.args, sheet, 4)
+	case "MIN":
+		return e.aggregate(n.args, sheet, 5)
+	case "SUBTOTAL":
+		return e.subtotal(n.args, sheet)
+	case "IF":
+		return e.ifFunction(n.args, sheet)
+	case "ROUND":
+		return e.round(n.args, sheet)
+	case "CONCATENATE":
+		return e.concatenate(n.args, sheet)
+	case "TRUE", "FALSE":
+		if len(n.args) > 0 {
+			return formulaValue{}, errNotEvaluable
+		}
+		return booleanOf(name == "TRUE"), nil
+	}
+	return formulaValue{}, errNotEvaluable
+}
+
+// aggregate computes the aggregate given by its SUBTOTAL function number
+// over the arguments. Within ranges, only numbers count; given directly, text
+// spelling a number and booleans count too.
+func (e *formulaEvaluator) aggregate(args []formulaNode, sheet string, code int) (formulaValue, error) {
+	var numbers []formulaValue
+	for _, arg := range args {
+		if array, ok := arg.(arrayNode); ok {
+			// Like ranges, arrays contribute their numbers only.
+			for _, r := range array.rows {
+				for _, element := range r {
+					if n, ok := element.(numberNode); ok {
+						numbers = append(numbers, numberOf(n.value))
+					}
+				}
+			}
+			continue
+		}
+		if isReferenceExpression(arg) {
+			ref, errValue := e.resolve(arg, sheet)
+			if errValue != nil {
+				return *errValue, nil
+			}
+			values, err := e.values(ref)
+			if err != nil {
+				return formulaValue{}, err
+			}
+			for _, v := range values {
+				switch v.kind {
+				case errorValue:
+					return v, nil
+				case numberValue:
+					numbers = append(numbers, v)
+				}
+			}
+			continue
+		}
+
+		v, err := e.scalar(arg, sheet)
+		if err != nil || v.kind == errorValue {
+			return v, err
+		}
+		if v.kind == emptyValue {
+			continue
+		}
+		x, errValue := toNumber(v)
+		if errValue != nil {
+			return *errValue, nil
+		}
+		numbers = append(numbers, formulaValue{kind: numberValue, number: x, numberType: v.numberType})
+	}
+
+	switch code {
+	case 1:
+		if len(numbers) == 0 {
+			return formulaError(errorDivisionByZero), nil
+		}
+		sum := 0.0
+		for _, v := range numbers {
+			sum += v.number
+		}
+		return numberOf(sum / float64(len(numbers))), nil
+	case 2:
+		return numberOf(float64(len(numbers))), nil
+	case 4, 5:
+		if len(numbers) == 0 {
+			return numberOf(0), nil
+		}
+		result := numbers[0]
+		for _, v := range numbers[1:] {
+			if code == 4 && v.number > result.number || code == 5 && v.number < result.number {
+				result.number = v.number
+			}
+			if v.numberType != result.numberType {
+				result.numberType = ""
+			}
+		}
+		return result, nil
+	case 9:
+		sum := 0.0
+		for _, v := range numbers {
+			sum += v.number
+		}
+		return numberOf(sum), nil
+	}
+	return formulaValue{}, errNotEvaluable
+}
+
+// subtotal computes SUBTOTAL for the aggregates the evaluator knows. The
+// sheets are written unfiltered, so the variants ignoring hidden rows (101
+// and up) compute the same as the others.
+func (e *formulaEvaluator) subtotal(args []formulaNode, sheet string) (formulaValue, error) {
+	if len(args) < 2 {
+		return formulaValue{}, errNotEvaluable
+	}
+	v, err := e.scalar(args[0], sheet)
+	if err != nil || v.kind == errorValue {
+		return v, err
+	}
+	x, errValue := toNumber(v)
+	if errValue != nil {
+		return *errValue, nil
+	}
+	code := int(x)
+	if code > 100 {
+		code -= 100
+	}
+	return e.aggregate(args[1:], sheet, code)
+}
+
+func (e *formulaEvaluator) ifFunction(args []formulaNode, sheet string) (formulaValue, error) {
+	if len(args) == 0 || len(args) > 3 {
+		return formulaValue{}, errNotEvaluable
+	}
+	v, err := e.scalar(args[0], sheet)
+	if err != nil || v.kind == errorValue {
+		return v, err
+	}
+	var condition bool
+	switch v.kind {
+	case booleanValue:
+		condition = v.boolean
+	case numberValue:
+		condition = v.number != 0
+	case textValue:
+		return formulaError(errorValueType), nil
+	}
+
+	switch {
+	case condition && len(args) >= 2:
+		return e.branch(args[1], sheet)
+	case !condition && len(args) == 3:
+		return e.branch(args[2], sheet)
+	}
+	return booleanOf(condition), nil
+}
+
+// branch evaluates the chosen branch of IF, where an omitted value counts as
+// zero.
+func (e *formulaEvaluator) branch(arg formulaNode, sheet string) (formulaValue, error) {
+	if _, missing := arg.(missingNode); missing {
+		return numberOf(0), nil
+	}
+	return e.scalar(arg, sheet)
+}
+
+func (e *formulaEvaluator) round(args []formulaNode, sheet string) (formulaValue, error) {
+	if len(args) == 0 || len(args) > 2 {
+		return formulaValue{}, errNotEvaluable
+	}
+	var numbers []formulaValue
+	for _, arg := range args {
+		v, err := e.scalar(arg, sheet)
+		if err != nil || v.kind == errorValue {
+			return v, err
+		}
+		x, errValue := toNumber(v)
+		if errValue != nil {
+			return *errValue, nil
+		}
+		numbers = append(numbers, formulaValue{kind: numberValue, number: x, numberType: v.numberType})
+	}
+	digits := 0.0
+	if len(numbers) == 2 {
+		digits = math.Trunc(numbers[1].number)
+	}
+	scale := math.Pow(10, digits)
+	// Rounding the representation error away first keeps 2.675 from
+	// becoming 2.67 because it is stored as 2.67499999...
+	result := numbers[0]
+	result.number = math.Round(roundSignificant(result.number*scale)) / scale
+	return result, nil
+}
+
+func (e *formulaEvaluator) concatenate(args []formulaNode, sheet string) (formulaValue, error) {
+	var b strings.Builder
+	for _, arg := range args {
+		v, err := e.scalar(arg, sheet)
+		if err != nil || v.kind == errorValue {
+			return v, err
+		}
+		b.WriteString(toText(v))
+	}
+	return textOf(b.String()), nil
+}
+
+// roundSignificant rounds x to the 15 significant digits office applications
+// compute with, dropping the representation error of binary floating point.
+func roundSignificant(x float64) float64 {
+	rounded, err := strconv.ParseFloat(strconv.FormatFloat(x, 'g', 15, 64), 64)
+	if err != nil {
+		return x
+	}
+	return rounded
+}
+
+// formatGeneral renders a number as the "General" format of office
+// applications does, without trailing zeros or an exponent.
+func formatGeneral(x float64) string {
+	x = roundSignificant(x)
+	if x == 0 {
+		return "0"
+	}
+	return strconv.FormatFloat(x, 'f', -1, 64)
+}
+
+// clearResult removes the result stored in a formula cell.
+func clearResult(cell *Cell) {
+	cell.ValueType = ""
+	cell.Value = ""
+	cell.DateValue = ""
+	cell.TimeValue = ""
+	cell.BooleanValue = ""
+	cell.Currency = ""
+	cell.Text = ""
+}
+
+// storeResult stores the result of a formula in its cell. Dates and times are
+// given the matching data style, unless the cell has a style already, so that
+// they render as such rather than as serial numbers.
+func storeResult(cell *Cell, v formulaValue) {
+	clearResult(cell)
+	switch v.kind {
+	case emptyValue:
+		cell.ValueType = "float"
+		cell.Value = "0"
+		cell.Text = "0"
+	case numberValue:
+		switch v.numberType {
+		case "date":
+			if v.number < firstSerialDate || v.number >= endSerialDate {
+				storeResult(cell, formulaError(errorNumber))
+				return
+			}
+			t := time.Unix(dateEpoch.Unix()+int64(math.Round(v.number*86400)), 0).UTC()
+			cell.ValueType = "date"
+			cell.DateValue = t.Format("2006-01-02")
+			if t.Hour() != 0 || t.Minute() != 0 || t.Second() != 0 {
+				cell.DateValue = t.Format("2006-01-02T15:04:05")
+			}
+			cell.Text = t.Format("2006-01-02")
+			if cell.StyleName == "" {
+				cell.StyleName = "DATE_STYLE"
+			}
+		case "time":
+			seconds := int64(math.Round(v.number * 86400))
+			sign := ""
+			if seconds < 0 {
+				sign, seconds = "-", -seconds
+			}
+			h, m, s := seconds/3600, seconds/60%60, seconds%60
+			cell.ValueType = "time"
+			cell.TimeValue = fmt.Sprintf("%sPT%02dH%02dM%02dS", sign, h, m, s)
+			cell.Text = fmt.Sprintf("%s%02d:%02d:%02d", sign, h, m, s)
+			if cell.StyleName == "" {
+				cell.StyleName = "TIME_STYLE"
+			}
+		default:
+			cell.ValueType = "float"
+			cell.Value = formatGeneral(v.number)
+			cell.Text = cell.Value
+		}
+	case textValue, errorValue:
+		cell.ValueType = "string"
+		cell.Text = v.text
+	case booleanValue:
+		cell.ValueType = "boolean"
+		cell.BooleanValue = strconv.FormatBool(v.boolean)
+		cell.Text = toText(v)
+	}
+}
@@ -0,0 +1,185 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestUnitEvaluateFormulas(t *testing.T) {
+	inputs := []Cell{
+		MakeRangeCell("42.5", "float", "InputA"),
+		MakeCell("-2", "float"),
+		MakeCell("2026-01-31", "date"),
+		MakeCell("2026-03-02", "date"),
+		MakeCell("08:30", "time"),
+		MakeCell("Total", "string"),
+		MakeCell("0.25", "percentage"),
+		MakeCell("10.10", "currency"),
+	}
+
+	cases := []struct {
+		formula   string
+		valueType string
+		value     string
+		text      string
+	}{
+		{"A1+B1*2", "float", "38.5", "38.5"},
+		{"(A1+B1)*2", "float", "81", "81"},
+		{"-2^2", "float", "4", "4"},
+		{"2^3^2", "float", "64", "64"},
+		{"0.1+0.2", "float", "0.3", "0.3"},
+		{"InputA/4", "float", "10.625", "10.625"},
+		{"inputa/4", "float", "10.625", "10.625"},
+		{"G1*H1", "float", "2.525", "2.525"},
+		{"50%", "float", "0.5", "0.5"},
+		{"SUM(A1:B1;10)", "float", "50.5", "50.5"},
+		{"SUM(A1:B1;G1:H1)", "float", "50.85", "50.85"},
+		{"AVERAGE(A1:B1)", "float", "20.25", "20.25"},
+		{"COUNT(A1:H1)", "float", "7", "7"},
+		{"MIN(A1:B1)", "float", "-2", "-2"},
+		{"MAX(A1:B1)", "float", "42.5", "42.5"},
+		{"ROUND(A1/3,2)", "float", "14.17", "14.17"},
+		{"ROUND(2.675,2)", "float", "2.68", "2.68"},
+		{"ROUND(A1)", "float", "43", "43"},
+		{"SUBTOTAL(9,A1:B1)", "float", "40.5", "40.5"},
+		{"SUBTOTAL(101,A1:B1)", "float", "20.25", "20.25"},
+		{`IF(A1>40,"high","low")`, "string", "", "high"},
+		{"IF(B1>0,1)", "boolean", "", "FALSE"},
+		{"IF(A1>0,TRUE,FALSE)", "boolean", "true", "TRUE"},
+		{"A1>=42.5", "boolean", "", "TRUE"},
+		{`F1="TOTAL"`, "boolean", "", "TRUE"},
+		{`F1&": "&A1`, "string", "", "Total: 42.5"},
+		{`CONCATENATE(F1;" ";C1)`, "string", "", "Total 46053"},
+		{"C1+30", "date", "", "2026-03-02"},
+		{"MAX(C1:D1)", "date", "", "2026-03-02"},
+		{"D1-C1", "float", "30", "30"},
+		{"E1+E1", "time", "", "17:00:00"},
+		{"A1/0", "string", "", "#DIV/0!"},
+		{"F1*2", "string", "", "#VALUE!"},
+		{"Z99", "float", "0", "0"},
+		{"SUM(B:B)+COUNT(1:1)", "float", "5", "5"},
+		{"SUM({1,2;3,4};A1)", "float", "52.5", "52.5"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.formula, func(t *testing.T) {
+			spreadsheet, err := MakeSpreadsheet([][]Cell{inputs, {MakeCell(c.formula, "formula")}})
+			if err != nil {
+				t.Fatalf("MakeSpreadsheet: %v", err)
+			}
+			cell := spreadsheet.Tables[0].Rows[1].Cells[0]
+			value := cell.Value + cell.DateValue + cell.TimeValue + cell.BooleanValue
+			if c.value != "" && value != c.value || cell.ValueType != c.valueType || cell.Text != c.text {
+				t.Errorf("%s: got %s %q %q, expected %s %q %q", cell.Formula, cell.ValueType, value, cell.Text, c.valueType, c.value, c.text)
+			}
+		})
+	}
+}
+
+func TestUnitEvaluateFormulasStoresTypedResults(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheet([][]Cell{{
+		MakeCell("2026-01-31", "date"),
+		MakeCell("A1+1.5", "formula"),
+		MakeStyledCell("A1+1", "formula", CellStyle{Bold: true}),
+		MakeCell("A1<B1", "formula"),
+	}})
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	actual, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+
+	assert(t, strings.Contains(actual, `table:style-name="DATE_STYLE" table:formula="of:=[.A1]+1.5"`), "expected a date result to be formatted as a date")
+	assert(t, strings.Contains(actual, `office:value-type="date" office:date-value="2026-02-01T12:00:00"`), "expected a date and time result")
+	assert(t, strings.Contains(actual, `<style:style style:name="CUSTOM_STYLE_1" style:family="table-cell" style:parent-style-name="Default" style:data-style-name="DATE_DATA_STYLE">`), "expected the generated style of a styled date result to format it as a date")
+	assert(t, strings.Contains(actual, `office:value-type="boolean" office:boolean-value="true"`), "expected a boolean result")
+
+	validateAgainstSchema(t, "flat.fods", actual)
+}
+
+func TestUnitEvaluateFormulasFarDates(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheet([][]Cell{{
+		MakeCell("2200-01-01", "date"),
+		MakeCell("A1+1", "formula"),
+		MakeCell("9999-12-31", "date"),
+		MakeCell("C1+1", "formula"),
+		MakeCell("A1-C1", "formula"),
+	}})
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	cells := spreadsheet.Tables[0].Rows[0].Cells
+	assert(t, cells[1].ValueType == "date" && cells[1].DateValue == "2200-01-02", fmt.Sprintf("expected the day after 2200-01-01, got %s %q", cells[1].ValueType, cells[1].DateValue))
+	assert(t, cells[3].Text == "#NUM!", fmt.Sprintf("expected a date after the year 9999 to be an error, got %s %q", cells[3].ValueType, cells[3].Text))
+	assert(t, cells[4].Value == "-2848890", fmt.Sprintf("expected the days between the dates, got %q", cells[4].Value))
+}
+
+func TestUnitEvaluateFormulasLeavesUnknownToConsumer(t *testing.T) {
+	for _, formula := range []string{"VLOOKUP(1;C1:C2;1)", "of:=LEN(\"abc\")", "msoxl:=1+1"} {
+		t.Run(formula, func(t *testing.T) {
+			spreadsheet, err := MakeSpreadsheet([][]Cell{{MakeCell(formula, "formula"), MakeCell("A1", "formula")}})
+			if err != nil {
+				t.Fatalf("MakeSpreadsheet: %v", err)
+			}
+			cell := spreadsheet.Tables[0].Rows[0].Cells[0]
+			assert(t, cell.ValueType == "" && cell.Text == "", fmt.Sprintf("expected %s to be stored without a result, got %s %q", cell.Formula, cell.ValueType, cell.Text))
+		})
+	}
+}
+
+func TestUnitEvaluateFormulasAcrossSheets(t *testing.T) {
+	spreadsheet, err := MakeWorkbook(workbookSheets()...)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	// Through the named range generated for the column of the table on the
+	// second sheet.
+	total := spreadsheet.Tables[0].Rows[0].Cells[1]
+	assert(t, total.ValueType == "float" && total.Value == "2100", fmt.Sprintf("expected the total of the second sheet, got %s %q", total.ValueType, total.Value))
+
+	cells := [][]Cell{
+		{MakeCell("Price", "string"), MakeCell("Quantity", "string")},
+		{MakeCell("1.5", "currency"), MakeCell("4", "float")},
+		{MakeCell("2.25", "currency"), MakeCell("2", "float")},
+	}
+	table, err := MakeTable(cells, TableOptions{Header: true, StructuredRefs: true, Totals: []Total{{TotalAverage}, {TotalSum}}})
+	if err != nil {
+		t.Fatalf("MakeTable: %v", err)
+	}
+	totals := table.Tables[0].Rows[3].Cells
+	assert(t, totals[0].Value == "1.875" && totals[1].Value == "6", fmt.Sprintf("expected the totals row to be evaluated, got %q and %q", totals[0].Value, totals[1].Value))
+}
+
+func TestUnitParseOpenFormulaInvalid(t *testing.T) {
+	for _, expression := range []string{"SUM([.A1]", "1+", "[.A1", `"open`, "1 2", "[Sheet1]"} {
+		if _, err := parseFormula(expression, openFormulaNotation); err == nil {
+			t.Errorf("expected an error for %q", expression)
+		}
+	}
+}
+
+// BenchmarkMakeSpreadsheetFormulas builds a sheet with a formula per row, whose
+// time should grow with the rows rather than with their square.
+func BenchmarkMakeSpreadsheetFormulas(b *testing.B) {
+	for _, rows := range []int{1000, 10000, 50000} {
+		cells := make([][]Cell, rows)
+		for r := range cells {
+			cells[r] = []Cell{MakeCell(fmt.Sprint(r), "float"), MakeCell(fmt.Sprintf("A%d*2", r+1), "formula")}
+		}
+		b.Run(fmt.Sprintf("rows=%d", rows), func(b *testing.B) {
+			for b.Loop() {
+				if _, err := MakeSpreadsheet(cells); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -5,8 +5,13 @@
 package ods
 
 import (
+	"errors"
+	"fmt"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"unicode"
 )
 
 // formulaNamespace is the OpenFormula namespace prefix that table:formula
@@ -17,6 +22,13 @@ const formulaNamespace = "of:"
 // absolute markers, as written by callers of MakeCell.
 var cellReference = regexp.MustCompile(`^\$?[A-Za-z]{1,3}\$?[0-9]{1,7}$`)
 
+// columnReference and rowReference match the ends of whole-column ("A:C")
+// and whole-row ("2:3") ranges.
+var (
+	columnReference = regexp.MustCompile(`^\$?[A-Za-z]{1,3}$`)
+	rowReference    = regexp.MustCompile(`^\$?[0-9]{1,7}$`)
+)
+
 // toOpenFormula converts the A1-style formula callers write ("SUM(A1:B1)")
 // into the OpenFormula expression stored in table:formula
 // ("of:=SUM([.A1:.B1])").
@@ -28,170 +40,1012 @@ var cellReference = regexp.MustCompile(`^\$?[A-Za-z]{1,3}\$?[0-9]{1,7}$`)
 // conforming consumers (Excel, Gnumeric) either drop such formulas or, worse,
 // misread the references and compute wrong results.
 //
-// Identifiers that are not cell addresses are left alone: function names are
-// recognized by the following parenthesis, everything else is assumed to be a
-// named range and referenced by name, which is how OpenFormula spells it too.
+// Function names are translated too, where Excel and OpenFormula spell them
+// differently (see excelFunctions), and functions that have no OpenFormula
+// equivalent are reported as errors.
+//
+// The formula is parsed, so that syntax errors are reported here rather than
+// by the consumer opening the document. Identifiers that are not cell
+// addresses are left alone: function names are recognized by the following
+// parenthesis, everything else is assumed to be a named range and referenced
+// by name, which is how OpenFormula spells it too.
+//
+// Structured references to tables (Products[Qty]) cannot be translated
+// before the tables are known; for formulas holding them, toOpenFormula
+// returns errStructuredReference, and the workbook builder translates them.
 //
 // A formula that already carries a namespace prefix is passed through
 // unchanged, as an escape hatch for expressions this translation cannot
 // express.
-func toOpenFormula(formula string) string {
+func toOpenFormula(formula string) (string, error) {
 	if strings.Contains(formula, ":=") {
-		return formula
+		return formula, nil
+	}
+	node, err := parseFormula(strings.TrimPrefix(formula, "="), a1Notation)
+	if err != nil {
+		return "", fmt.Errorf("invalid formula %q: %w", formula, err)
+	}
+	if hasStructured(node) {
+		return "", errStructuredReference
+	}
+	return formulaNamespace + "=" + renderFormula(node), nil
+}
+
+// cellAddress is the position of a cell, with 1-based row and column. An
+// empty sheet denotes the sheet the formula is on.
+type cellAddress struct {
+	sheet    string
+	row, col int
+}
+
+// cellRange is a rectangular block of cells on one sheet.
+type cellRange struct {
+	start, end cellAddress
+}
+
+// Nodes of a parsed formula. They keep the spelling of their source where
+// the notations agree, so that rendering a parsed formula reproduces it.
+type (
+	numberNode struct {
+		text  string
+		value float64
+	}
+	textNode struct{ text string }
+	// referenceNode is a cell or range address. address is its spelling
+	// within the brackets of OpenFormula (".A1", "Sheet2.A1:.B2").
+	referenceNode struct {
+		address string
+		ref     cellRange
+	}
+	nameNode  struct{ name string }
+	parenNode struct{ inner formulaNode }
+	// unaryNode is a prefix "+" or "-", or the postfix "%".
+	unaryNode struct {
+		op      string
+		operand formulaNode
+	}
+	// binaryNode is an infix operator, including the range operator ":"
+	// between names ("InputA:InputB").
+	binaryNode struct {
+		op          string
+		left, right formulaNode
+	}
+	callNode struct {
+		name string
+		args []formulaNode
+	}
+	// missingNode stands for an omitted function argument, as in IF(A1;;1).
+	missingNode struct{}
+	// arrayNode is an inline array of constants, row by row.
+	arrayNode   struct{ rows [][]formulaNode }
+	booleanNode struct{ value bool }
+)
+
+type formulaNode any
+
+// formulaNotation selects the syntax a formula is parsed in.
+type formulaNotation int
+
+const (
+	// a1Notation is what callers of MakeCell write: bare A1 references and
+	// commas or semicolons between arguments.
+	a1Notation formulaNotation = iota
+	// openFormulaNotation is what table:formula holds, without the namespace
+	// prefix: bracketed references and semicolons between arguments.
+	openFormulaNotation
+	// r1c1Notation is what callers of MakeR1C1Cell write: like a1Notation,
+	// with references given by row and column numbers, absolute (R5C2) or
+	// relative to the cell of the formula (R[-1]C[2]).
+	r1c1Notation
+)
+
+// parseFormula parses expression into its syntax tree.
+func parseFormula(expression string, notation formulaNotation) (formulaNode, error) {
+	p := &formulaParser{notation: notation, input: []rune(expression)}
+	return p.parse()
+}
+
+// parseR1C1Formula parses an expression in R1C1 notation into its syntax
+// tree, with relative references taken from origin, the cell of the formula.
+// References are translated into A1 notation as they are parsed.
+func parseR1C1Formula(expression string, origin cellAddress) (formulaNode, error) {
+	p := &formulaParser{notation: r1c1Notation, input: []rune(expression), origin: origin}
+	return p.parse()
+}
+
+func (p *formulaParser) parse() (formulaNode, error) {
+	p.next()
+	node, err := p.comparison()
+	if err != nil {
+		return nil, err
+	}
+	if p.token.kind != tokenEnd {
+		return nil, p.unexpected()
 	}
-	formula = strings.TrimPrefix(formula, "=")
+	return node, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEnd tokenKind = iota
+	tokenNumber
+	tokenText
+	tokenReference
+	tokenIdent
+	tokenStructured
+	tokenOperator
+	tokenInvalid
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	// address and ref are the bracketed spelling and the cells of a
+	// tokenReference.
+	address string
+	ref     cellRange
+	// structured is the reference of a tokenStructured.
+	structured structuredNode
+	// err explains a tokenInvalid.
+	err error
+}
+
+type formulaParser struct {
+	notation formulaNotation
+	// origin is the cell relative references in r1c1Notation refer from.
+	origin cellAddress
+	input  []rune
+	pos    int
+	token  token
+}
+
+// operators are the single-character operators and punctuation of both
+// notations; "<>", "<=", and ">=" are scanned as one token.
+const operators = "+-*/^&=<>%():;,{}|"
 
-	tokens := tokenizeFormula(formula)
+// next scans the next token into p.token.
+func (p *formulaParser) next() {
+	for p.pos < len(p.input) && unicode.IsSpace(p.input[p.pos]) {
+		p.pos++
+	}
+	if p.pos >= len(p.input) {
+		p.token = token{kind: tokenEnd}
+		return
+	}
+
+	c := p.input[p.pos]
+	switch {
+	case c == '"':
+		p.token = p.scanText()
+	case c == '[' && p.notation == openFormulaNotation:
+		p.token = p.scanBracketReference()
+	case c == '[':
+		p.token = p.scanStructuredReference("", p.pos)
+	case isDigit(c) && p.notation == a1Notation:
+		// A whole-row range ("2:3") or a number.
+		start := p.pos
+		if t, ok := p.scanA1Reference(); ok {
+			p.token = t
+			return
+		}
+		p.pos = start
+		p.token = p.scanNumber()
+	case isDigit(c) || c == '.' && p.pos+1 < len(p.input) && isDigit(p.input[p.pos+1]):
+		p.token = p.scanNumber()
+	case c == '_' || c == '$' && p.notation == a1Notation || c == '\'' && p.notation != openFormulaNotation || unicode.IsLetter(c):
+		p.token = p.scanIdent()
+	default:
+		start := p.pos
+		p.pos++
+		if p.pos < len(p.input) {
+			if two := string(p.input[start : p.pos+1]); two == "<>" || two == "<=" || two == ">=" {
+				p.pos++
+				p.token = token{kind: tokenOperator, text: two}
+				return
+			}
+		}
+		if !strings.ContainsRune(operators, c) || c == ',' && p.notation == openFormulaNotation || c == '|' && p.notation != openFormulaNotation {
+			p.token = token{kind: tokenInvalid, text: string(c), err: fmt.Errorf("unexpected character %q", c)}
+			return
+		}
+		p.token = token{kind: tokenOperator, text: string(c)}
+	}
+}
+
+func isDigit(c rune) bool {
+	return c >= '0' && c <= '9'
+}
+
+// scanText scans a string literal, in which a doubled quote stands for a
+// quote.
+func (p *formulaParser) scanText() token {
 	var b strings.Builder
-	b.WriteString(formulaNamespace + "=")
-	for i := 0; i < len(tokens); i++ {
-		t := tokens[i]
-		switch {
-		case t.kind == tokenIdent && isFunctionCall(tokens, i):
-			b.WriteString(t.text)
-		case t.kind == tokenIdent && isReference(t.text):
-			// A reference followed by ":" and a second reference is a range,
-			// which OpenFormula writes as a single bracketed expression.
-			if end, ok := rangeEnd(tokens, i); ok {
-				b.WriteString("[" + bracketBody(t.text) + ":" + bracketBody(tokens[end].text) + "]")
-				i = end
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		if p.input[p.pos] == '"' {
+			if p.pos+1 < len(p.input) && p.input[p.pos+1] == '"' {
+				b.WriteRune('"')
+				p.pos++
 				continue
 			}
-			b.WriteString("[" + bracketBody(t.text) + "]")
-		case t.kind == tokenOther && t.text == ",":
-			b.WriteString(";")
+			p.pos++
+			return token{kind: tokenText, text: b.String()}
+		}
+		b.WriteRune(p.input[p.pos])
+	}
+	return token{kind: tokenInvalid, err: errors.New("unterminated string literal")}
+}
+
+// scanBracketReference scans a bracketed OpenFormula reference. Sheet names
+// within it may contain "]" only if quoted.
+func (p *formulaParser) scanBracketReference() token {
+	start := p.pos + 1
+	quoted := false
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		switch {
+		case p.input[p.pos] == '\'':
+			quoted = !quoted
+		case p.input[p.pos] == ']' && !quoted:
+			address := string(p.input[start:p.pos])
+			p.pos++
+			ref, err := parseRangeAddress(address)
+			if err != nil {
+				return token{kind: tokenInvalid, text: address, err: err}
+			}
+			return token{kind: tokenReference, text: "[" + address + "]", address: address, ref: ref}
+		}
+	}
+	return token{kind: tokenInvalid, err: errors.New("unterminated reference")}
+}
+
+func (p *formulaParser) scanNumber() token {
+	start := p.pos
+	for p.pos < len(p.input) && (isDigit(p.input[p.pos]) || p.input[p.pos] == '.') {
+		p.pos++
+	}
+	if p.pos < len(p.input) && (p.input[p.pos] == 'e' || p.input[p.pos] == 'E') {
+		exponent := p.pos + 1
+		if exponent < len(p.input) && (p.input[exponent] == '+' || p.input[exponent] == '-') {
+			exponent++
+		}
+		if exponent < len(p.input) && isDigit(p.input[exponent]) {
+			p.pos = exponent
+			for p.pos < len(p.input) && isDigit(p.input[p.pos]) {
+				p.pos++
+			}
+		}
+	}
+	text := string(p.input[start:p.pos])
+	if _, err := strconv.ParseFloat(text, 64); err != nil {
+		return token{kind: tokenInvalid, text: text, err: fmt.Errorf("invalid number %q", text)}
+	}
+	return token{kind: tokenNumber, text: text}
+}
+
+// scanIdent scans a function name, a named range, or, in A1 and R1C1
+// notation, a cell reference or a structured reference. "." is part of an
+// identifier, so that namespaced function names (COM.MICROSOFT.IFS) are a
+// single token.
+func (p *formulaParser) scanIdent() token {
+	start := p.pos
+	switch p.notation {
+	case a1Notation:
+		if t, ok := p.scanA1Reference(); ok {
+			return t
+		}
+		p.pos = start
+	case r1c1Notation:
+		if t, ok := p.scanR1C1Reference(); ok {
+			return t
+		}
+		p.pos = start
+	}
+	p.skipIdent()
+	text := string(p.input[start:p.pos])
+	switch {
+	case text != "" && p.notation != openFormulaNotation && p.peekIs(0, '['):
+		return p.scanStructuredReference(text, start)
+	case text == "":
+		p.pos++
+		return token{kind: tokenInvalid, text: string(p.input[start]), err: fmt.Errorf("unexpected character %q", p.input[start])}
+	case strings.Contains(text, "$"):
+		return token{kind: tokenInvalid, text: text, err: fmt.Errorf("invalid reference %q", text)}
+	}
+	return token{kind: tokenIdent, text: text}
+}
+
+func (p *formulaParser) skipIdent() {
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c != '_' && c != '.' && !(c == '$' && p.notation == a1Notation) && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			return
+		}
+		p.pos++
+	}
+}
+
+// peekIs reports whether the character at p.pos+offset is c.
+func (p *formulaParser) peekIs(offset int, c rune) bool {
+	return p.pos+offset < len(p.input) && p.input[p.pos+offset] == c
+}
+
+// a1Endpoint is one end of a reference in A1 notation: a cell address,
+// optionally qualified with a sheet, or with the first and last sheet of a
+// 3D reference.
+type a1Endpoint struct {
+	sheets []string
+	// absolute is set if the sheet is marked absolute ("$Sheet1.A1").
+	absolute bool
+	// cell is a cell address, or a column or row if kind says so.
+	cell string
+	kind endpointKind
+}
+
+type endpointKind int
+
+const (
+	cellEndpoint endpointKind = iota
+	columnEndpoint
+	rowEndpoint
+)
+
+// scanA1Reference scans a cell or range reference in A1 notation and
+// translates it into the bracketed OpenFormula form. Sheets are given in the
+// notation of either application, and quoted if their name calls for it:
+//
+//	A1:B2                     .A1:.B2
+//	Sheet1.A1, $'Q1 2026'.B3  Sheet1.A1, $'Q1 2026'.B3
+//	'Q1 2026'!A1:B2           'Q1 2026'.A1:.B2
+//	Sheet1.A1:Sheet3.B2       Sheet1.A1:Sheet3.B2
+//	Sheet1:Sheet3!A1:B2       Sheet1.A1:Sheet3.B2
+//	'Q1:Q4'!A1                Q1.A1:Q4.A1
+//	A:A, Sheet2!2:3           .A:.A, Sheet2.2:.3
+//
+// Whole columns and rows are ranges only: a column or row on its own is not
+// a reference. It reports false if there is no reference at p.pos, as for
+// names, function names, and numbers.
+func (p *formulaParser) scanA1Reference() (token, bool) {
+	start := p.pos
+	first, ok := p.scanA1Endpoint()
+	if !ok || p.peekIs(0, '(') {
+		return token{}, false
+	}
+	address := first.render(0, first.cell)
+	end := first.cell
+
+	ranged := false
+	if p.peekIs(0, ':') {
+		colon := p.pos
+		p.pos++
+		second, ok := p.scanA1Endpoint()
+		ranged = ok && second.kind == first.kind && len(second.sheets) < 2 && (len(second.sheets) == 0 || len(first.sheets) < 2)
+		switch {
+		case !ranged:
+			// A name after the colon makes the colon the range operator.
+			p.pos = colon
+		case len(second.sheets) == 1:
+			address += ":" + second.render(0, second.cell)
+		case len(first.sheets) == 2:
+			end = second.cell
 		default:
-			b.WriteString(t.text)
+			address += ":." + second.cell
 		}
 	}
-	return b.String()
+	if first.kind != cellEndpoint && !ranged {
+		return token{}, false
+	}
+	if len(first.sheets) == 2 {
+		address += ":" + first.render(1, end)
+	} else if first.kind != cellEndpoint && !strings.Contains(address, ":") {
+		address += ":" + first.render(0, end)
+	}
+
+	text := string(p.input[start:p.pos])
+	ref, err := parseRangeAddress(address)
+	if err != nil {
+		return token{kind: tokenInvalid, text: text, err: err}, true
+	}
+	return token{kind: tokenReference, text: text, address: address, ref: ref}, true
 }
 
-// bracketBody renders a reference for use inside square brackets: an
-// unqualified address is prefixed with "." to denote the current sheet,
-// a sheet-qualified one ("Sheet1.A1") is already in the expected form.
-func bracketBody(reference string) string {
-	if strings.Contains(reference, ".") {
-		return reference
+// render returns the OpenFormula spelling of cell on the i-th sheet of e.
+func (e a1Endpoint) render(i int, cell string) string {
+	if len(e.sheets) == 0 {
+		return "." + cell
+	}
+	sheet := quoteSheetName(e.sheets[i])
+	if e.absolute {
+		sheet = "$" + sheet
 	}
-	return "." + reference
+	return sheet + "." + cell
 }
 
-// isReference reports whether an identifier is a cell address, either
-// unqualified ("A1") or sheet-qualified ("Sheet1.A1"). Anything else is a
-// named range.
-func isReference(identifier string) bool {
-	if dot := strings.LastIndex(identifier, "."); dot >= 0 {
-		return dot > 0 && cellReference.MatchString(identifier[dot+1:])
+// scanA1Endpoint scans one end of a reference, as described for
+// scanA1Reference.
+func (p *formulaParser) scanA1Endpoint() (a1Endpoint, bool) {
+	var e a1Endpoint
+	if p.peekIs(0, '$') && p.peekIs(1, '\'') {
+		e.absolute = true
+		p.pos++
+	}
+
+	if p.peekIs(0, '\'') {
+		name, ok := p.scanQuotedSheetName()
+		if !ok {
+			return e, false
+		}
+		switch {
+		case p.peekIs(0, '!'):
+			// Excel quotes the sheets of a 3D reference as a whole; no sheet
+			// name contains a colon.
+			e.sheets = strings.Split(name, ":")
+		case p.peekIs(0, '.'):
+			e.sheets = []string{name}
+		default:
+			return e, false
+		}
+		p.pos++
+		e.cell = p.scanWord(false)
+	} else {
+		word := p.scanWord(true)
+		if p.peekIs(0, ':') {
+			// The first sheet of a 3D reference, if the second is followed
+			// by "!".
+			colon := p.pos
+			p.pos++
+			if last := p.scanWord(true); last != "" && p.peekIs(0, '!') {
+				e.sheets = []string{word, last}
+			} else {
+				p.pos = colon
+			}
+		}
+		switch {
+		case p.peekIs(0, '!'):
+			if e.sheets == nil {
+				e.sheets = []string{word}
+			}
+			p.pos++
+			e.cell = p.scanWord(false)
+		case strings.Contains(word, "."):
+			dot := strings.LastIndex(word, ".")
+			e.sheets = []string{word[:dot]}
+			e.cell = word[dot+1:]
+		default:
+			e.cell = word
+		}
+		for i, sheet := range e.sheets {
+			if trimmed, ok := strings.CutPrefix(sheet, "$"); ok {
+				e.absolute = true
+				e.sheets[i] = trimmed
+			}
+		}
+	}
+
+	switch {
+	case len(e.sheets) > 2:
+		return e, false
+	case cellReference.MatchString(e.cell):
+		e.kind = cellEndpoint
+	case columnReference.MatchString(e.cell):
+		e.kind = columnEndpoint
+	case rowReference.MatchString(e.cell):
+		e.kind = rowEndpoint
+	default:
+		return e, false
+	}
+	for _, sheet := range e.sheets {
+		if sheet == "" || strings.Contains(sheet, "$") {
+			return e, false
+		}
 	}
-	return cellReference.MatchString(identifier)
+	return e, true
 }
 
-// isFunctionCall reports whether the identifier at index i is followed by an
-// opening parenthesis, which makes it a function name rather than a
-// reference.
-func isFunctionCall(tokens []token, i int) bool {
-	next := nextSignificant(tokens, i+1)
-	return next >= 0 && tokens[next].text == "("
+// scanWord scans a run of identifier characters, including "." if dots is
+// set.
+func (p *formulaParser) scanWord(dots bool) string {
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c != '_' && c != '$' && (c != '.' || !dots) && !unicode.IsLetter(c) && !unicode.IsDigit(c) {
+			break
+		}
+		p.pos++
+	}
+	return string(p.input[start:p.pos])
 }
 
-// rangeEnd reports the index of the closing reference of a range starting at
-// index i, if the reference at i is followed by ":" and a second reference.
-func rangeEnd(tokens []token, i int) (int, bool) {
-	colon := nextSignificant(tokens, i+1)
-	if colon < 0 || tokens[colon].text != ":" {
-		return 0, false
+// scanQuotedSheetName scans a sheet name enclosed in apostrophes, in which a
+// doubled apostrophe stands for one.
+func (p *formulaParser) scanQuotedSheetName() (string, bool) {
+	var b strings.Builder
+	for p.pos++; p.pos < len(p.input); p.pos++ {
+		if p.input[p.pos] == '\'' {
+			if p.peekIs(1, '\'') {
+				b.WriteRune('\'')
+				p.pos++
+				continue
+			}
+			p.pos++
+			return b.String(), true
+		}
+		b.WriteRune(p.input[p.pos])
 	}
-	end := nextSignificant(tokens, colon+1)
-	if end < 0 || tokens[end].kind != tokenIdent || !isReference(tokens[end].text) {
-		return 0, false
+	return "", false
+}
+
+// unexpected returns the error for the current token.
+func (p *formulaParser) unexpected() error {
+	switch p.token.kind {
+	case tokenInvalid:
+		return p.token.err
+	case tokenEnd:
+		return errors.New("unexpected end of formula")
 	}
-	return end, true
+	return fmt.Errorf("unexpected %q", p.token.text)
 }
 
-// nextSignificant returns the index of the first token at or after i that is
-// not whitespace, or -1 if there is none.
-func nextSignificant(tokens []token, i int) int {
-	for ; i < len(tokens); i++ {
-		if strings.TrimSpace(tokens[i].text) != "" {
-			return i
+func (p *formulaParser) isOperator(ops ...string) bool {
+	return p.token.kind == tokenOperator && slices.Contains(ops, p.token.text)
+}
+
+// binary parses a left-associative chain of operands joined by any of ops.
+func (p *formulaParser) binary(operand func() (formulaNode, error), ops ...string) (formulaNode, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator(ops...) {
+		op := p.token.text
+		p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
 		}
+		left = binaryNode{op: op, left: left, right: right}
 	}
-	return -1
+	return left, nil
 }
 
-type tokenKind int
+// The precedence levels of OpenFormula, from the loosest binding to the
+// tightest. Unary minus binds tighter than "^", so -2^2 is 4.
+func (p *formulaParser) comparison() (formulaNode, error) {
+	return p.binary(p.concatenation, "=", "<>", "<", ">", "<=", ">=")
+}
 
-const (
-	// tokenOther covers operators, parentheses, numbers, and whitespace:
-	// everything that is copied through unchanged except for the comma.
-	tokenOther tokenKind = iota
-	tokenIdent
-	tokenString
-)
+func (p *formulaParser) concatenation() (formulaNode, error) {
+	return p.binary(p.additive, "&")
+}
 
-type token struct {
-	kind tokenKind
-	text string
+func (p *formulaParser) additive() (formulaNode, error) {
+	return p.binary(p.multiplicative, "+", "-")
+}
+
+func (p *formulaParser) multiplicative() (formulaNode, error) {
+	return p.binary(p.power, "*", "/")
+}
+
+func (p *formulaParser) power() (formulaNode, error) {
+	return p.binary(p.unary, "^")
+}
+
+func (p *formulaParser) unary() (formulaNode, error) {
+	if p.isOperator("+", "-") {
+		op := p.token.text
+		p.next()
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return unaryNode{op: op, operand: operand}, nil
+	}
+	node, err := p.binary(p.primary, ":")
+	if err != nil {
+		return nil, err
+	}
+	for p.isOperator("%") {
+		p.next()
+		node = unaryNode{op: "%", operand: node}
+	}
+	return node, nil
+}
+
+func (p *formulaParser) primary() (formulaNode, error) {
+	t := p.token
+	switch t.kind {
+	case tokenNumber:
+		p.next()
+		value, _ := strconv.ParseFloat(t.text, 64)
+		return numberNode{text: t.text, value: value}, nil
+	case tokenText:
+		p.next()
+		return textNode{t.text}, nil
+	case tokenReference:
+		p.next()
+		return referenceNode{address: t.address, ref: t.ref}, nil
+	case tokenStructured:
+		p.next()
+		return t.structured, nil
+	case tokenIdent:
+		p.next()
+		if p.isOperator("(") {
+			name := t.text
+			if p.notation != openFormulaNotation {
+				var err error
+				if name, err = openFormulaFunction(name); err != nil {
+					return nil, err
+				}
+			}
+			p.next()
+			args, err := p.arguments()
+			if err != nil {
+				return nil, err
+			}
+			return callNode{name: name, args: args}, nil
+		}
+		if strings.EqualFold(t.text, "TRUE") || strings.EqualFold(t.text, "FALSE") {
+			return booleanNode{strings.EqualFold(t.text, "TRUE")}, nil
+		}
+		return nameNode{t.text}, nil
+	case tokenOperator:
+		if t.text == "(" {
+			p.next()
+			inner, err := p.comparison()
+			if err != nil {
+				return nil, err
+			}
+			if !p.isOperator(")") {
+				return nil, p.missingParenthesis()
+			}
+			p.next()
+			return parenNode{inner}, nil
+		}
+		if t.text == "{" {
+			p.next()
+			return p.array()
+		}
+	}
+	return nil, p.unexpected()
 }
 
-// tokenizeFormula splits a formula into identifiers, string literals, and
-// single characters. It is deliberately loose: anything it does not
-// recognize ends up as a tokenOther and is copied through verbatim.
-func tokenizeFormula(formula string) []token {
-	var tokens []token
-	runes := []rune(formula)
-	for i := 0; i < len(runes); {
-		c := runes[i]
+// array parses an inline array after its "{". Its elements are separated by
+// "," within a row and by ";" between rows in A1 and R1C1 notation, which
+// OpenFormula writes as ";" and "|".
+func (p *formulaParser) array() (formulaNode, error) {
+	columnSeparator, rowSeparator := ";", "|"
+	if p.notation != openFormulaNotation {
+		columnSeparator, rowSeparator = ",", ";"
+	}
+
+	rows := [][]formulaNode{nil}
+	for {
+		element, err := p.arrayElement()
+		if err != nil {
+			return nil, err
+		}
+		rows[len(rows)-1] = append(rows[len(rows)-1], element)
 		switch {
-		case c == '"':
-			// String literals are copied verbatim, so that a comma or a word
-			// that looks like a cell address inside one is left alone. A
-			// doubled quote escapes a quote and does not end the literal.
-			start := i
-			i++
-			for i < len(runes) {
-				if runes[i] == '"' {
-					if i+1 < len(runes) && runes[i+1] == '"' {
-						i += 2
-						continue
-					}
-					i++
-					break
+		case p.isOperator(columnSeparator):
+			p.next()
+		case p.isOperator(rowSeparator):
+			p.next()
+			rows = append(rows, nil)
+		case p.isOperator("}"):
+			p.next()
+			for _, r := range rows[1:] {
+				if len(r) != len(rows[0]) {
+					return nil, errors.New("rows of an array differ in length")
 				}
-				i++
-			}
-			tokens = append(tokens, token{tokenString, string(runes[start:i])})
-		case isIdentStart(c):
-			start := i
-			for i < len(runes) && isIdentRune(runes[i]) {
-				i++
-			}
-			tokens = append(tokens, token{tokenIdent, string(runes[start:i])})
-		case c >= '0' && c <= '9':
-			// Scanned separately from identifiers so that the decimal point
-			// of a number is not mistaken for a sheet separator.
-			start := i
-			for i < len(runes) && (runes[i] == '.' || (runes[i] >= '0' && runes[i] <= '9')) {
-				i++
-			}
-			tokens = append(tokens, token{tokenOther, string(runes[start:i])})
+			}
+			return arrayNode{rows}, nil
+		case p.token.kind == tokenEnd:
+			return nil, errors.New("missing closing brace")
+		default:
+			return nil, p.unexpected()
+		}
+	}
+}
+
+// arrayElement parses an element of an inline array, which is a constant: a
+// number, optionally signed, a string, or TRUE or FALSE.
+func (p *formulaParser) arrayElement() (formulaNode, error) {
+	t := p.token
+	sign := ""
+	if t.kind == tokenOperator && (t.text == "-" || t.text == "+") {
+		sign = t.text
+		p.next()
+		t = p.token
+		if t.kind != tokenNumber {
+			return nil, p.unexpected()
+		}
+	}
+	switch {
+	case t.kind == tokenNumber:
+		p.next()
+		value, _ := strconv.ParseFloat(t.text, 64)
+		if sign == "-" {
+			value = -value
+		}
+		return numberNode{text: sign + t.text, value: value}, nil
+	case t.kind == tokenText:
+		p.next()
+		return textNode{t.text}, nil
+	case t.kind == tokenIdent && (strings.EqualFold(t.text, "TRUE") || strings.EqualFold(t.text, "FALSE")):
+		p.next()
+		return booleanNode{strings.EqualFold(t.text, "TRUE")}, nil
+	case t.kind == tokenEnd:
+		return nil, errors.New("missing closing brace")
+	}
+	return nil, fmt.Errorf("array element %q is not a constant", t.text)
+}
+
+// arguments parses the arguments of a function call up to and including the
+// closing parenthesis.
+func (p *formulaParser) arguments() ([]formulaNode, error) {
+	var args []formulaNode
+	if p.isOperator(")") {
+		p.next()
+		return args, nil
+	}
+	for {
+		if p.isSeparator() || p.isOperator(")") {
+			args = append(args, missingNode{})
+		} else {
+			arg, err := p.comparison()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+		}
+		switch {
+		case p.isSeparator():
+			p.next()
+		case p.isOperator(")"):
+			p.next()
+			return args, nil
 		default:
-			tokens = append(tokens, token{tokenOther, string(c)})
-			i++
+			return nil, p.missingParenthesis()
+		}
+	}
+}
+
+// isSeparator reports whether the current token separates function
+// arguments. A1 and R1C1 notation accept the comma as well as the semicolon.
+func (p *formulaParser) isSeparator() bool {
+	return p.isOperator(";") || p.notation != openFormulaNotation && p.isOperator(",")
+}
+
+func (p *formulaParser) missingParenthesis() error {
+	if p.token.kind == tokenEnd {
+		return errors.New("missing closing parenthesis")
+	}
+	return p.unexpected()
+}
+
+// renderFormula renders a syntax tree as an OpenFormula expression, without
+// the namespace prefix.
+func renderFormula(node formulaNode) string {
+	var b strings.Builder
+	writeFormula(&b, node)
+	return b.String()
+}
+
+func writeFormula(b *strings.Builder, node formulaNode) {
+	switch n := node.(type) {
+	case numberNode:
+		b.WriteString(n.text)
+	case textNode:
+		b.WriteString(`"` + strings.ReplaceAll(n.text, `"`, `""`) + `"`)
+	case referenceNode:
+		b.WriteString("[" + n.address + "]")
+	case nameNode:
+		b.WriteString(n.name)
+	case structuredNode:
+		b.WriteString(n.text)
+	case parenNode:
+		b.WriteString("(")
+		writeFormula(b, n.inner)
+		b.WriteString(")")
+	case unaryNode:
+		if n.op == "%" {
+			writeFormula(b, n.operand)
+			b.WriteString("%")
+			return
+		}
+		b.WriteString(n.op)
+		writeFormula(b, n.operand)
+	case binaryNode:
+		writeFormula(b, n.left)
+		b.WriteString(n.op)
+		writeFormula(b, n.right)
+	case arrayNode:
+		b.WriteString("{")
+		for i, r := range n.rows {
+			if i > 0 {
+				b.WriteString("|")
+			}
+			for j, element := range r {
+				if j > 0 {
+					b.WriteString(";")
+				}
+				writeFormula(b, element)
+			}
+		}
+		b.WriteString("}")
+	case booleanNode:
+		if n.value {
+			b.WriteString("TRUE")
+		} else {
+			b.WriteString("FALSE")
+		}
+	case callNode:
+		b.WriteString(n.name + "(")
+		for i, arg := range n.args {
+			if i > 0 {
+				b.WriteString(";")
+			}
+			writeFormula(b, arg)
+		}
+		b.WriteString(")")
+	}
+}
+
+// walkFormula calls visit for node and all nodes below it, parents first.
+// The nodes below a node are skipped if visit returns false for it.
+func walkFormula(node formulaNode, visit func(formulaNode) bool) {
+	if !visit(node) {
+		return
+	}
+	switch n := node.(type) {
+	case parenNode:
+		walkFormula(n.inner, visit)
+	case unaryNode:
+		walkFormula(n.operand, visit)
+	case binaryNode:
+		walkFormula(n.left, visit)
+		walkFormula(n.right, visit)
+	case callNode:
+		for _, arg := range n.args {
+			walkFormula(arg, visit)
 		}
 	}
-	return tokens
 }
 
-// isIdentStart reports whether c can begin an identifier. "$" is included so
-// that the absolute marker of "$A$1" stays part of the reference.
-func isIdentStart(c rune) bool {
-	return c == '_' || c == '$' || (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || c > 127
+// replaceFormula returns node with the nodes for which replace reports true
+// replaced by what it returns for them, parents first. The nodes below a
+// replaced node are not visited.
+func replaceFormula(node formulaNode, replace func(formulaNode) (formulaNode, bool, error)) (formulaNode, error) {
+	if replaced, ok, err := replace(node); ok || err != nil {
+		return replaced, err
+	}
+	var err error
+	switch n := node.(type) {
+	case parenNode:
+		n.inner, err = replaceFormula(n.inner, replace)
+		return n, err
+	case unaryNode:
+		n.operand, err = replaceFormula(n.operand, replace)
+		return n, err
+	case binaryNode:
+		if n.left, err = replaceFormula(n.left, replace); err != nil {
+			return nil, err
+		}
+		n.right, err = replaceFormula(n.right, replace)
+		return n, err
+	case callNode:
+		args := make([]formulaNode, len(n.args))
+		for i, arg := range n.args {
+			if args[i], err = replaceFormula(arg, replace); err != nil {
+				return nil, err
+			}
+		}
+		n.args = args
+		return n, nil
+	}
+	return node, nil
+}
+
+// parseRangeAddress parses a cell or range address as written within the
+// brackets of a formula reference and in the addresses of named ranges:
+// ".A1", "$Sheet1.$A$1", "$'Q1 2026'.B2:.B3". Whole columns (".A:.C") and
+// rows (".2:.3") span all rows or columns a sheet can have.
+func parseRangeAddress(address string) (cellRange, error) {
+	start, rest, err := parseCellAddress(address)
+	if err != nil {
+		return cellRange{}, err
+	}
+	if rest == "" {
+		if start.row == 0 || start.col == 0 {
+			return cellRange{}, fmt.Errorf("invalid cell address %q", address)
+		}
+		return cellRange{start, start}, nil
+	}
+	if rest[0] != ':' {
+		return cellRange{}, fmt.Errorf("invalid cell address %q", address)
+	}
+	end, rest, err := parseCellAddress(rest[1:])
+	if err != nil {
+		return cellRange{}, err
+	}
+	if rest != "" {
+		return cellRange{}, fmt.Errorf("invalid cell address %q", address)
+	}
+	if end.sheet == "" {
+		end.sheet = start.sheet
+	}
+	switch {
+	case (start.row == 0) != (end.row == 0) || (start.col == 0) != (end.col == 0):
+		return cellRange{}, fmt.Errorf("invalid cell address %q", address)
+	case start.row == 0:
+		start.row, end.row = 1, maxRows
+	case start.col == 0:
+		start.col, end.col = 1, maxColumns
+	}
+	return cellRange{start, end}, nil
 }
 
-// isIdentRune reports whether c can continue an identifier. "." is included
-// so that sheet-qualified references ("Sheet1.A1") stay a single token.
-func isIdentRune(c rune) bool {
-	return isIdentStart(c) || c == '.' || (c >= '0' && c <= '9')
+// parseCellAddress parses the cell address at the start of s and returns the
+// text following it. The row of a column address ("A" in ".A:.C") and the
+// column of a row address are zero.
+func parseCellAddress(s string) (cellAddress, string, error) {
+	original := s
+	var addr cellAddress
+
+	s = strings.TrimPrefix(s, "$")
+	switch {
+	case strings.HasPrefix(s, "."):
+		s = s[1:]
+	case strings.HasPrefix(s, "'"):
+		var b strings.Builder
+		i := 1
+		for ; i < len(s); i++ {
+			if s[i] == '\'' {
+				if i+1 < len(s) && s[i+1] == '\'' {
+					b.WriteByte('\'')
+					i++
+					continue
+				}
+				break
+			}
+			b.WriteByte(s[i])
+		}
+		if i+1 >= len(s) || s[i+1] != '.' {
+			return addr, "", fmt.Errorf("invalid cell address %q", original)
+		}
+		addr.sheet = b.String()
+		s = s[i+2:]
+	default:
+		dot := strings.IndexByte(s, '.')
+		if dot <= 0 {
+			return addr, "", fmt.Errorf("invalid cell address %q", original)
+		}
+		addr.sheet = s[:dot]
+		s = s[dot+1:]
+	}
+
+	s = strings.TrimPrefix(s, "$")
+	letters := 0
+	for letters < len(s) && (s[letters] >= 'A' && s[letters] <= 'Z' || s[letters] >= 'a' && s[letters] <= 'z') {
+		letters++
+	}
+	column := strings.ToUpper(s[:letters])
+	s = strings.TrimPrefix(s[letters:], "$")
+	digits := 0
+	for digits < len(s) && s[digits] >= '0' && s[digits] <= '9' {
+		digits++
+	}
+	if letters > 3 || letters == 0 && digits == 0 {
+		return addr, "", fmt.Errorf("invalid cell address %q", original)
+	}
+	if digits > 0 {
+		row, err := strconv.Atoi(s[:digits])
+		if err != nil || row < 1 {
+			return addr, "", fmt.Errorf("invalid cell address %q", original)
+		}
+		addr.row = row
+	}
+	for _, c := range column {
+		addr.col = addr.col*26 + int(c-'A') + 1
+	}
+	return addr, s[digits:], nil
 }
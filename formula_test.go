@@ -4,7 +4,11 @@
 
 package ods
 
-import "testing"
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
 
 func TestUnitToOpenFormula(t *testing.T) {
 	cases := map[string]string{
@@ -17,6 +21,37 @@ func TestUnitToOpenFormula(t *testing.T) {
 		"Sheet2.A1":                "of:=[Sheet2.A1]",
 		"SUM(Sheet2.A1:Sheet2.B2)": "of:=SUM([Sheet2.A1:Sheet2.B2])",
 
+		// Sheet-qualified references in Excel and ODF notation, with sheet
+		// names quoted where OpenFormula requires it.
+		"Sheet2!A1":                 "of:=[Sheet2.A1]",
+		"'Q1 2026'!B3":              "of:=['Q1 2026'.B3]",
+		"SUM('Q1 2026'!A1:B2)":      "of:=SUM(['Q1 2026'.A1:.B2])",
+		"'Q1 2026'.B3":              "of:=['Q1 2026'.B3]",
+		"$'Q1 2026'.B3*2":           "of:=[$'Q1 2026'.B3]*2",
+		"$Sheet2.$A$1":              "of:=[$Sheet2.$A$1]",
+		"'Bob''s'!A1&Übersicht!A1":  "of:=['Bob''s'.A1]&[Übersicht.A1]",
+		"'Sheet2'!A1":               "of:=[Sheet2.A1]",
+		"SUM(Data.A1:'Q1 2026'.B2)": "of:=SUM([Data.A1:'Q1 2026'.B2])",
+		"Sheet2!A1:InputA":          "of:=[Sheet2.A1]:InputA",
+		"SUM(Sheet1:Sheet3!A1:B2)":  "of:=SUM([Sheet1.A1:Sheet3.B2])",
+		"SUM('Q1 2026:Q4 2026'!B3)": "of:=SUM(['Q1 2026'.B3:'Q4 2026'.B3])",
+		"SUM(Jan:Dec!$B$3,Jan!B4)":  "of:=SUM([Jan.$B$3:Dec.$B$3];[Jan.B4])",
+
+		// Whole columns and rows.
+		"SUM(A:A)":             "of:=SUM([.A:.A])",
+		"SUM(2:2)":             "of:=SUM([.2:.2])",
+		"SUM($B:$D,10:12)":     "of:=SUM([.$B:.$D];[.10:.12])",
+		"SUM(Sheet2!A:C)":      "of:=SUM([Sheet2.A:.C])",
+		"SUM('Q1 2026'!$3:$3)": "of:=SUM(['Q1 2026'.$3:.$3])",
+		"SUM(Jan:Dec!B:B)":     "of:=SUM([Jan.B:Dec.B])",
+		"2:2*1.5":              "of:=[.2:.2]*1.5",
+
+		// Array constants separate columns with ";" and rows with "|".
+		"SUM({1,2;3,4})":          "of:=SUM({1;2|3;4})",
+		`IF(A1,{"a","b"},{-1.5})`: `of:=IF([.A1];{"a";"b"};{-1.5})`,
+		"{TRUE;false}":            "of:={TRUE|FALSE}",
+		"SUMPRODUCT(A1:B1,{2,3})": "of:=SUMPRODUCT([.A1:.B1];{2;3})",
+
 		// Named ranges are referenced bare in OpenFormula, so they are left
 		// as they are.
 		"InputA+InputB":      "of:=InputA+InputB",
@@ -24,8 +59,9 @@ func TestUnitToOpenFormula(t *testing.T) {
 		"AVERAGE(A1:B1)":     "of:=AVERAGE([.A1:.B1])",
 
 		// Function arguments are separated by semicolons.
-		"SUM(A1,B1)":      "of:=SUM([.A1];[.B1])",
-		"IF(A1>0,A1,-A1)": "of:=IF([.A1]>0;[.A1];-[.A1])",
+		"SUM(A1,B1)":          "of:=SUM([.A1];[.B1])",
+		"IF(A1>0,A1,-A1)":     "of:=IF([.A1]>0;[.A1];-[.A1])",
+		"IF(A1>0,TRUE,false)": "of:=IF([.A1]>0;TRUE;FALSE)",
 
 		// Whatever looks like a reference inside a string literal is not one.
 		`CONCATENATE("A1, B1",A1)`: `of:=CONCATENATE("A1, B1";[.A1])`,
@@ -38,13 +74,116 @@ func TestUnitToOpenFormula(t *testing.T) {
 		// already carries a namespace prefix is passed through.
 		"=A1+B1":         "of:=[.A1]+[.B1]",
 		"of:=SUM([.A1])": "of:=SUM([.A1])",
+
+		// Semicolons are accepted as argument separators, whitespace is
+		// dropped, and quotes within string literals stay doubled.
+		"SUBTOTAL(9;B2:B3)":      "of:=SUBTOTAL(9;[.B2:.B3])",
+		"IF( A1 > 0 , 1 , 2 )":   "of:=IF([.A1]>0;1;2)",
+		`IF(A1,"say ""hi""","")`: `of:=IF([.A1];"say ""hi""";"")`,
+
+		// Operators, omitted arguments, and namespaced function names.
+		"-A1^2%<>B1&\"x\"":          `of:=-[.A1]^2%<>[.B1]&"x"`,
+		"IF(A1;;1)":                 "of:=IF([.A1];;1)",
+		"ROUND(A1)":                 "of:=ROUND([.A1])",
+		"NOW()":                     "of:=NOW()",
+		"COM.MICROSOFT.IFS(A1>0,1)": "of:=COM.MICROSOFT.IFS([.A1]>0;1)",
+		"1.5E3+.5":                  "of:=1.5E3+.5",
+		"A1:InputA":                 "of:=[.A1]:InputA",
 	}
 
 	for input, expected := range cases {
 		t.Run(input, func(t *testing.T) {
-			if actual := toOpenFormula(input); actual != expected {
+			actual, err := toOpenFormula(input)
+			if err != nil {
+				t.Fatalf("toOpenFormula(%q): %v", input, err)
+			}
+			if actual != expected {
 				t.Errorf("toOpenFormula(%q) = %q, expected %q", input, actual, expected)
 			}
+
+			// What is stored parses as OpenFormula and renders unchanged.
+			node, err := parseFormula(strings.TrimPrefix(actual, "of:="), openFormulaNotation)
+			if err != nil {
+				t.Fatalf("parsing %q: %v", actual, err)
+			}
+			if rendered := "of:=" + renderFormula(node); rendered != actual {
+				t.Errorf("%q renders as %q", actual, rendered)
+			}
+		})
+	}
+}
+
+func TestUnitToOpenFormulaSyntaxErrors(t *testing.T) {
+	cases := map[string]string{
+		"SUM(A1:":     "unexpected end of formula",
+		"SUM(A1,B1":   "missing closing parenthesis",
+		"(A1+B1))":    `unexpected ")"`,
+		"A1+":         "unexpected end of formula",
+		"A1 B1":       `unexpected "B1"`,
+		"A1#2":        "unexpected character '#'",
+		`"open`:       "unterminated string literal",
+		"1.2.3":       `invalid number "1.2.3"`,
+		"$Input":      `invalid reference "$Input"`,
+		"SUM(A1;*2)":  `unexpected "*"`,
+		"":            "unexpected end of formula",
+		"'Q1 2026!A1": "unexpected character '\\''",
+		"Sheet2!":     `unexpected character '!'`,
+		"{1,2;3}":     "rows of an array differ in length",
+		"{1,A1}":      `array element "A1" is not a constant`,
+		"SUM({1,2)":   "unexpected \")\"",
+		"{1":          "missing closing brace",
+		"A:":          "unexpected end of formula",
+	}
+
+	for input, expected := range cases {
+		t.Run(input, func(t *testing.T) {
+			_, err := toOpenFormula(input)
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("toOpenFormula(%q): expected an error containing %q, got: %v", input, expected, err)
+			}
 		})
 	}
 }
+
+func TestUnitMakeSpreadsheetReportsFormulaErrors(t *testing.T) {
+	_, err := MakeSpreadsheet([][]Cell{
+		{MakeCell("1", "float"), MakeCell("SUM(A1", "formula")},
+		{MakeCell("A1*2", "formula"), MakeCell("A1+*2", "formula")},
+	})
+	if err == nil {
+		t.Fatal("expected an error for invalid formulas")
+	}
+	for _, expected := range []string{
+		`row 1, column 2: invalid formula "SUM(A1": missing closing parenthesis`,
+		`row 2, column 2: invalid formula "A1+*2": unexpected "*"`,
+	} {
+		assert(t, strings.Contains(err.Error(), expected), fmt.Sprintf("expected %q in the error, got: %v", expected, err))
+	}
+	assert(t, !strings.Contains(err.Error(), "row 2, column 1"), "expected no error for the valid formula")
+}
+
+func TestUnitMakeSpreadsheetReportsUnresolvedReferences(t *testing.T) {
+	_, err := MakeSpreadsheet([][]Cell{
+		{MakeRangeCell("1", "float", "InputA"), MakeCell("InputA*2", "formula")},
+		{MakeCell("InputB*2", "formula"), MakeCell("SUM(InputA:Missing)", "formula")},
+		{MakeCell("Sheet2.A1", "formula"), MakeCell("SUM(Sheet1.A1:Sheet3.A2)", "formula")},
+		{MakeCell("XFD1048576+XFE1", "formula"), MakeCell("A1048577", "formula")},
+		{MakeCell("of:=[.A1]+Undefined", "formula"), MakeCell("SUM(A1:B2)", "formula")},
+	})
+	if err == nil {
+		t.Fatal("expected an error for unresolved references")
+	}
+	for _, expected := range []string{
+		`row 2, column 1: undefined name "InputB"`,
+		`row 2, column 2: undefined name "Missing"`,
+		`row 3, column 1: reference to missing sheet "Sheet2"`,
+		`row 3, column 2: reference to missing sheet "Sheet3"`,
+		`row 4, column 1: address XFE1 is beyond the 1048576 rows and 16384 columns of a sheet`,
+		`row 4, column 2: address A1048577 is beyond`,
+	} {
+		assert(t, strings.Contains(err.Error(), expected), fmt.Sprintf("expected %q in the error, got: %v", expected, err))
+	}
+	for _, valid := range []string{"row 1, column 2", "row 5, column 1", "row 5, column 2"} {
+		assert(t, !strings.Contains(err.Error(), valid), fmt.Sprintf("expected no error at %s", valid))
+	}
+}
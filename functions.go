@@ -0,0 +1,240 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"strings"
+)
+
+// excelFunctions maps the names of Excel functions that OpenFormula spells
+// differently to the OpenFormula spelling, as LibreOffice writes them. Most
+// functions Excel added after OpenFormula was standardized are stored in the
+// COM.MICROSOFT namespace, the forecasting functions LibreOffice implements
+// under names of its own in the ORG.LIBREOFFICE namespace. Functions not
+// listed here are spelled alike in both.
+var excelFunctions = map[string]string{
+	// Logical and lookup functions.
+	"IFS":     "COM.MICROSOFT.IFS",
+	"SWITCH":  "COM.MICROSOFT.SWITCH",
+	"XLOOKUP": "COM.MICROSOFT.XLOOKUP",
+	"XMATCH":  "COM.MICROSOFT.XMATCH",
+	"LET":     "COM.MICROSOFT.LET",
+
+	// Dynamic arrays.
+	"FILTER":    "COM.MICROSOFT.FILTER",
+	"SORT":      "COM.MICROSOFT.SORT",
+	"SORTBY":    "COM.MICROSOFT.SORTBY",
+	"UNIQUE":    "COM.MICROSOFT.UNIQUE",
+	"SEQUENCE":  "COM.MICROSOFT.SEQUENCE",
+	"RANDARRAY": "COM.MICROSOFT.RANDARRAY",
+
+	// Shaping arrays.
+	"TAKE":       "COM.MICROSOFT.TAKE",
+	"DROP":       "COM.MICROSOFT.DROP",
+	"VSTACK":     "COM.MICROSOFT.VSTACK",
+	"HSTACK":     "COM.MICROSOFT.HSTACK",
+	"TOCOL":      "COM.MICROSOFT.TOCOL",
+	"TOROW":      "COM.MICROSOFT.TOROW",
+	"CHOOSECOLS": "COM.MICROSOFT.CHOOSECOLS",
+	"CHOOSEROWS": "COM.MICROSOFT.CHOOSEROWS",
+	"WRAPROWS":   "COM.MICROSOFT.WRAPROWS",
+	"WRAPCOLS":   "COM.MICROSOFT.WRAPCOLS",
+	"EXPAND":     "COM.MICROSOFT.EXPAND",
+
+	// Text and information.
+	"CONCAT":      "COM.MICROSOFT.CONCAT",
+	"TEXTJOIN":    "COM.MICROSOFT.TEXTJOIN",
+	"TEXTBEFORE":  "COM.MICROSOFT.TEXTBEFORE",
+	"TEXTAFTER":   "COM.MICROSOFT.TEXTAFTER",
+	"TEXTSPLIT":   "COM.MICROSOFT.TEXTSPLIT",
+	"FORMULATEXT": "FORMULA",
+	"ENCODEURL":   "COM.MICROSOFT.ENCODEURL",
+	"FILTERXML":   "COM.MICROSOFT.FILTERXML",
+	"WEBSERVICE":  "COM.MICROSOFT.WEBSERVICE",
+
+	// Aggregates, dates, and rounding.
+	"MAXIFS":            "COM.MICROSOFT.MAXIFS",
+	"MINIFS":            "COM.MICROSOFT.MINIFS",
+	"AGGREGATE":         "COM.MICROSOFT.AGGREGATE",
+	"NETWORKDAYS.INTL":  "COM.MICROSOFT.NETWORKDAYS.INTL",
+	"WORKDAY.INTL":      "COM.MICROSOFT.WORKDAY.INTL",
+	"CEILING.MATH":      "COM.MICROSOFT.CEILING.MATH",
+	"CEILING.PRECISE":   "COM.MICROSOFT.CEILING.PRECISE",
+	"FLOOR.MATH":        "COM.MICROSOFT.FLOOR.MATH",
+	"FLOOR.PRECISE":     "COM.MICROSOFT.FLOOR.PRECISE",
+	"ERF.PRECISE":       "COM.MICROSOFT.ERF.PRECISE",
+	"ERFC.PRECISE":      "COM.MICROSOFT.ERFC.PRECISE",
+	"GAMMALN.PRECISE":   "COM.MICROSOFT.GAMMALN.PRECISE",
+	"FORECAST.LINEAR":   "COM.MICROSOFT.FORECAST.LINEAR",
+	"FORECAST.ETS":      "ORG.LIBREOFFICE.FORECAST.ETS.ADD",
+	"FORECAST.ETS.STAT": "ORG.LIBREOFFICE.FORECAST.ETS.STAT.ADD",
+	// Excel's confidence interval is LibreOffice's prediction interval.
+	"FORECAST.ETS.CONFINT": "ORG.LIBREOFFICE.FORECAST.ETS.PI.ADD",
+
+	// Statistical functions, renamed in Excel 2010.
+	"BETA.DIST":       "COM.MICROSOFT.BETA.DIST",
+	"BETA.INV":        "COM.MICROSOFT.BETA.INV",
+	"BINOM.DIST":      "COM.MICROSOFT.BINOM.DIST",
+	"BINOM.INV":       "COM.MICROSOFT.BINOM.INV",
+	"CHISQ.DIST":      "COM.MICROSOFT.CHISQ.DIST",
+	"CHISQ.DIST.RT":   "COM.MICROSOFT.CHISQ.DIST.RT",
+	"CHISQ.INV":       "COM.MICROSOFT.CHISQ.INV",
+	"CHISQ.INV.RT":    "COM.MICROSOFT.CHISQ.INV.RT",
+	"CHISQ.TEST":      "COM.MICROSOFT.CHISQ.TEST",
+	"CONFIDENCE.NORM": "COM.MICROSOFT.CONFIDENCE.NORM",
+	"CONFIDENCE.T":    "COM.MICROSOFT.CONFIDENCE.T",
+	"COVARIANCE.P":    "COM.MICROSOFT.COVARIANCE.P",
+	"COVARIANCE.S":    "COM.MICROSOFT.COVARIANCE.S",
+	"EXPON.DIST":      "COM.MICROSOFT.EXPON.DIST",
+	"F.DIST":          "COM.MICROSOFT.F.DIST",
+	"F.DIST.RT":       "COM.MICROSOFT.F.DIST.RT",
+	"F.INV":           "COM.MICROSOFT.F.INV",
+	"F.INV.RT":        "COM.MICROSOFT.F.INV.RT",
+	"F.TEST":          "COM.MICROSOFT.F.TEST",
+	"GAMMA.DIST":      "COM.MICROSOFT.GAMMA.DIST",
+	"GAMMA.INV":       "COM.MICROSOFT.GAMMA.INV",
+	"HYPGEOM.DIST":    "COM.MICROSOFT.HYPGEOM.DIST",
+	"LOGNORM.DIST":    "COM.MICROSOFT.LOGNORM.DIST",
+	"LOGNORM.INV":     "COM.MICROSOFT.LOGNORM.INV",
+	"MODE.MULT":       "COM.MICROSOFT.MODE.MULT",
+	"MODE.SNGL":       "COM.MICROSOFT.MODE.SNGL",
+	"NEGBINOM.DIST":   "COM.MICROSOFT.NEGBINOM.DIST",
+	"NORM.DIST":       "COM.MICROSOFT.NORM.DIST",
+	"NORM.INV":        "COM.MICROSOFT.NORM.INV",
+	"NORM.S.DIST":     "COM.MICROSOFT.NORM.S.DIST",
+	"NORM.S.INV":      "COM.MICROSOFT.NORM.S.INV",
+	"PERCENTILE.EXC":  "COM.MICROSOFT.PERCENTILE.EXC",
+	"PERCENTILE.INC":  "COM.MICROSOFT.PERCENTILE.INC",
+	"PERCENTRANK.EXC": "COM.MICROSOFT.PERCENTRANK.EXC",
+	"PERCENTRANK.INC": "COM.MICROSOFT.PERCENTRANK.INC",
+	"POISSON.DIST":    "COM.MICROSOFT.POISSON.DIST",
+	"QUARTILE.EXC":    "COM.MICROSOFT.QUARTILE.EXC",
+	"QUARTILE.INC":    "COM.MICROSOFT.QUARTILE.INC",
+	"RANK.AVG":        "COM.MICROSOFT.RANK.AVG",
+	"RANK.EQ":         "COM.MICROSOFT.RANK.EQ",
+	"STDEV.P":         "COM.MICROSOFT.STDEV.P",
+	"STDEV.S":         "COM.MICROSOFT.STDEV.S",
+	"T.DIST":          "COM.MICROSOFT.T.DIST",
+	"T.DIST.2T":       "COM.MICROSOFT.T.DIST.2T",
+	"T.DIST.RT":       "COM.MICROSOFT.T.DIST.RT",
+	"T.INV":           "COM.MICROSOFT.T.INV",
+	"T.INV.2T":        "COM.MICROSOFT.T.INV.2T",
+	"T.TEST":          "COM.MICROSOFT.T.TEST",
+	"VAR.P":           "COM.MICROSOFT.VAR.P",
+	"VAR.S":           "COM.MICROSOFT.VAR.S",
+	"WEIBULL.DIST":    "COM.MICROSOFT.WEIBULL.DIST",
+	"Z.TEST":          "COM.MICROSOFT.Z.TEST",
+}
+
+// prefixedExcelFunctions are the functions Excel marks with the _xlfn.
+// prefix in its files that OpenFormula spells alike. Any other prefixed
+// function that is neither mapped nor listed here is rejected rather than
+// passed on under a name no consumer knows.
+var prefixedExcelFunctions = map[string]bool{
+	"ACOT":         true,
+	"ACOTH":        true,
+	"ARABIC":       true,
+	"BASE":         true,
+	"BITAND":       true,
+	"BITLSHIFT":    true,
+	"BITOR":        true,
+	"BITRSHIFT":    true,
+	"BITXOR":       true,
+	"COMBINA":      true,
+	"COT":          true,
+	"COTH":         true,
+	"CSC":          true,
+	"CSCH":         true,
+	"DAYS":         true,
+	"DECIMAL":      true,
+	"GAMMA":        true,
+	"GAUSS":        true,
+	"IFNA":         true,
+	"IMCOSH":       true,
+	"IMCOT":        true,
+	"IMCSC":        true,
+	"IMCSCH":       true,
+	"IMSEC":        true,
+	"IMSECH":       true,
+	"IMSINH":       true,
+	"IMTAN":        true,
+	"ISFORMULA":    true,
+	"ISOWEEKNUM":   true,
+	"MUNIT":        true,
+	"NUMBERVALUE":  true,
+	"PDURATION":    true,
+	"PERMUTATIONA": true,
+	"PHI":          true,
+	"RRI":          true,
+	"SEC":          true,
+	"SECH":         true,
+	"SHEET":        true,
+	"SHEETS":       true,
+	"UNICHAR":      true,
+	"UNICODE":      true,
+	"XOR":          true,
+}
+
+// unsupportedExcelFunctions are the Excel functions no OpenFormula consumer
+// implements: lambdas and the functions taking them, regular expressions in
+// Excel's flavor, conversions of values to their text in formulas, and
+// functions relying on online services, OLAP cubes, or an embedded Python.
+var unsupportedExcelFunctions = map[string]bool{
+	"LAMBDA":             true,
+	"MAP":                true,
+	"REDUCE":             true,
+	"SCAN":               true,
+	"BYROW":              true,
+	"BYCOL":              true,
+	"MAKEARRAY":          true,
+	"ISOMITTED":          true,
+	"GROUPBY":            true,
+	"PIVOTBY":            true,
+	"PERCENTOF":          true,
+	"TRIMRANGE":          true,
+	"REGEXTEST":          true,
+	"REGEXEXTRACT":       true,
+	"REGEXREPLACE":       true,
+	"ARRAYTOTEXT":        true,
+	"VALUETOTEXT":        true,
+	"STOCKHISTORY":       true,
+	"IMAGE":              true,
+	"RTD":                true,
+	"PY":                 true,
+	"CUBEKPIMEMBER":      true,
+	"CUBEMEMBER":         true,
+	"CUBEMEMBERPROPERTY": true,
+	"CUBERANKEDMEMBER":   true,
+	"CUBESET":            true,
+	"CUBESETCOUNT":       true,
+	"CUBEVALUE":          true,
+}
+
+// openFormulaFunction returns the OpenFormula spelling of the function an
+// A1-notation formula calls. Names are compared without regard to case, and
+// the prefixes Excel marks newer functions with in its files (_xlfn.,
+// _xlws.) are dropped; prefixed names must be known to be mapped or spelled
+// alike. Names that are the same in both notations, or already carry an
+// OpenFormula namespace, are returned as they are.
+func openFormulaFunction(name string) (string, error) {
+	upper := strings.ToUpper(name)
+	prefixed := false
+	for _, prefix := range []string{"_XLFN.", "_XLWS."} {
+		if trimmed, ok := strings.CutPrefix(upper, prefix); ok {
+			upper, name, prefixed = trimmed, name[len(prefix):], true
+		}
+	}
+	if unsupportedExcelFunctions[upper] {
+		return "", fmt.Errorf("function %s has no OpenFormula equivalent", upper)
+	}
+	if mapped, ok := excelFunctions[upper]; ok {
+		return mapped, nil
+	}
+	if prefixed && !prefixedExcelFunctions[upper] {
+		return "", fmt.Errorf("function %s is unknown to OpenFormula", upper)
+	}
+	return name, nil
+}
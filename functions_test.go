@@ -0,0 +1,77 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUnitToOpenFormulaMapsExcelFunctions(t *testing.T) {
+	cases := map[string]string{
+		`IFS(A1>0,"pos",A1<0,"neg")`:        `of:=COM.MICROSOFT.IFS([.A1]>0;"pos";[.A1]<0;"neg")`,
+		"XLOOKUP(A1,B1:B9,C1:C9)":           "of:=COM.MICROSOFT.XLOOKUP([.A1];[.B1:.B9];[.C1:.C9])",
+		`TEXTJOIN(", ",TRUE(),A1:A3)`:       `of:=COM.MICROSOFT.TEXTJOIN(", ";TRUE();[.A1:.A3])`,
+		"concat(A1,B1)":                     "of:=COM.MICROSOFT.CONCAT([.A1];[.B1])",
+		"STDEV.S(A1:A9)/STDEV.P(A1:A9)":     "of:=COM.MICROSOFT.STDEV.S([.A1:.A9])/COM.MICROSOFT.STDEV.P([.A1:.A9])",
+		"FORECAST.ETS(A10,B1:B9,A1:A9)":     "of:=ORG.LIBREOFFICE.FORECAST.ETS.ADD([.A10];[.B1:.B9];[.A1:.A9])",
+		"FORMULATEXT(A1)":                   "of:=FORMULA([.A1])",
+		"_xlfn.MAXIFS(A1:A9,B1:B9,\">0\")":  `of:=COM.MICROSOFT.MAXIFS([.A1:.A9];[.B1:.B9];">0")`,
+		"_xlfn.VSTACK(A1:A3,TAKE(B1:B9,2))": "of:=COM.MICROSOFT.VSTACK([.A1:.A3];COM.MICROSOFT.TAKE([.B1:.B9];2))",
+		`TEXTBEFORE(A1," ")`:                `of:=COM.MICROSOFT.TEXTBEFORE([.A1];" ")`,
+		"_xlfn.IFNA(A1,0)":                  "of:=IFNA([.A1];0)",
+
+		// Nested calls are mapped too, and functions spelled alike in both
+		// notations keep their spelling.
+		"SUM(IFS(A1>0,1),CONCAT(B1))": "of:=SUM(COM.MICROSOFT.IFS([.A1]>0;1);COM.MICROSOFT.CONCAT([.B1]))",
+		"Round(A1,2)":                 "of:=Round([.A1];2)",
+		"COM.MICROSOFT.IFS(A1>0,1)":   "of:=COM.MICROSOFT.IFS([.A1]>0;1)",
+
+		// Function names spelled like cell addresses.
+		"LOG10(100)+ATAN2(1,2)": "of:=LOG10(100)+ATAN2(1;2)",
+	}
+
+	for input, expected := range cases {
+		t.Run(input, func(t *testing.T) {
+			actual, err := toOpenFormula(input)
+			if err != nil {
+				t.Fatalf("toOpenFormula(%q): %v", input, err)
+			}
+			if actual != expected {
+				t.Errorf("toOpenFormula(%q) = %q, expected %q", input, actual, expected)
+			}
+		})
+	}
+}
+
+func TestUnitToOpenFormulaRejectsUnsupportedFunctions(t *testing.T) {
+	for input, expected := range map[string]string{
+		"LAMBDA(x,x*2)(A1)":                    "function LAMBDA has no OpenFormula equivalent",
+		`SUM(A1,regextest(B1,"[0-9]+"))`:       "function REGEXTEST has no OpenFormula equivalent",
+		`_xlfn.CUBEVALUE("Sales","[Measure]")`: "function CUBEVALUE has no OpenFormula equivalent",
+		"VALUETOTEXT(A1)":                      "function VALUETOTEXT has no OpenFormula equivalent",
+		"_xlfn.NEWFUNCTION(A1)":                "function NEWFUNCTION is unknown to OpenFormula",
+	} {
+		t.Run(input, func(t *testing.T) {
+			_, err := toOpenFormula(input)
+			if err == nil || !strings.Contains(err.Error(), expected) {
+				t.Errorf("toOpenFormula(%q): expected an error containing %q, got: %v", input, expected, err)
+			}
+		})
+	}
+}
+
+func TestUnitExcelFunctionsAreMappedOnce(t *testing.T) {
+	for name, mapped := range excelFunctions {
+		assert(t, !unsupportedExcelFunctions[name], name+" is both mapped and unsupported")
+		assert(t, name == strings.ToUpper(name), name+" is not upper case")
+		_, renamed := excelFunctions[mapped]
+		assert(t, !renamed, name+" is mapped to a name that is mapped again")
+	}
+	for name := range prefixedExcelFunctions {
+		_, mapped := excelFunctions[name]
+		assert(t, !mapped && !unsupportedExcelFunctions[name], name+" is both spelled alike and mapped or unsupported")
+	}
+}
@@ -0,0 +1,412 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// jsonDocument is the JSON form of a spreadsheet, as read by
+// [Spreadsheet.UnmarshalJSON].
+type jsonDocument struct {
+	Sheets []jsonSheet `json:"sheets"`
+}
+
+type jsonSheet struct {
+	Name string `json:"name,omitempty"`
+	// Rows holds the cells, each an object or a bare value, decoded by
+	// jsonCellOf so that their errors carry the row and column.
+	Rows  [][]json.RawMessage `json:"rows"`
+	Table *jsonTable          `json:"table,omitempty"`
+	Names []jsonName          `json:"names,omitempty"`
+}
+
+type jsonCell struct {
+	Value  json.RawMessage `json:"value,omitempty"`
+	Type   string          `json:"type,omitempty"`
+	Range  string          `json:"range,omitempty"`
+	Style  *jsonStyle      `json:"style,omitempty"`
+	Matrix *jsonMatrix     `json:"matrix,omitempty"`
+}
+
+type jsonStyle struct {
+	BackgroundColor string `json:"backgroundColor,omitempty"`
+	FontColor       string `json:"fontColor,omitempty"`
+	Bold            bool   `json:"bold,omitempty"`
+	Italic          bool   `json:"italic,omitempty"`
+	Border          string `json:"border,omitempty"`
+}
+
+type jsonMatrix struct {
+	Rows    int `json:"rows"`
+	Columns int `json:"columns"`
+}
+
+// jsonTable mirrors TableOptions, with totals and the style given by name.
+type jsonTable struct {
+	Name           string   `json:"name,omitempty"`
+	Header         bool     `json:"header,omitempty"`
+	AutoFilter     bool     `json:"autoFilter,omitempty"`
+	BandedRows     bool     `json:"bandedRows,omitempty"`
+	Totals         []string `json:"totals,omitempty"`
+	StructuredRefs bool     `json:"structuredRefs,omitempty"`
+	Style          string   `json:"style,omitempty"`
+}
+
+type jsonName struct {
+	Name       string `json:"name"`
+	Range      string `json:"range,omitempty"`
+	Expression string `json:"expression,omitempty"`
+	SheetScope bool   `json:"sheetScope,omitempty"`
+}
+
+// tableStyles maps the style names of JSON tables to their themes.
+var tableStyles = map[string]TableStyle{
+	"":      TableStyleBlue,
+	"blue":  TableStyleBlue,
+	"gray":  TableStyleGray,
+	"green": TableStyleGreen,
+}
+
+// UnmarshalJSON builds the spreadsheet described by a JSON document, so that
+// a spreadsheet can be decoded with [json.Unmarshal]:
+//
+//	{
+//	  "sheets": [{
+//	    "name": "Products",
+//	    "rows": [
+//	      ["Product", "Unit Price"],
+//	      ["Pen", {"value": "1.49", "type": "currency", "range": "PenPrice"}],
+//	      [{"value": "Desk", "style": {"bold": true}}, 189]
+//	    ],
+//	    "table": {"header": true, "autoFilter": true, "totals": ["count", "sum"]},
+//	    "names": [{"name": "VAT", "expression": "0.19"}]
+//	  }]
+//	}
+//
+// Each sheet is made as by [MakeSheet], or by [MakeTableSheet] if it has a
+// "table", whose fields mirror those of [TableOptions]: "totals" names the
+// aggregate of each column ("sum", "average", "count", "min", "max", or ""
+// for none) and "style" the theme ("blue", "gray", or "green"). The "names"
+// of a sheet are its [DefinedName]s, with the fields "name", "range",
+// "expression", and "sheetScope". Sheets without a name are named "Sheet1",
+// "Sheet2", and so on, by their position.
+//
+// A cell is an object with the "value" and "type" of [MakeCell], an optional
+// "range" name as for [MakeRangeCell], a "style" with the fields of
+// [CellStyle] ("backgroundColor", "fontColor", "bold", "italic", "border"),
+// and, for an array formula as by [MakeMatrixCell], a "matrix" with the
+// "rows" and "columns" it spans. Values may be strings, numbers, or
+// booleans; without a type, strings are "string" cells, numbers "float"
+// cells, and booleans "boolean" cells. A bare value stands for a cell with
+// just that value, and null, like an object without a value, for an empty
+// cell.
+//
+// Unknown fields, malformed cells, and unknown totals or styles are reported
+// with their sheet, and cells with their row and column; the spreadsheet is
+// then built by [MakeWorkbook], which reports invalid values and formulas.
+func (s *Spreadsheet) UnmarshalJSON(data []byte) error {
+	var document jsonDocument
+	if err := decodeJSONStrict(data, &document); err != nil {
+		return err
+	}
+
+	sheets := make([]Sheet, len(document.Sheets))
+	var errs []error
+	for i, js := range document.Sheets {
+		if js.Name == "" {
+			js.Name = fmt.Sprintf("Sheet%d", i+1)
+		}
+		sheet, err := js.sheet()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("sheet %q: %w", js.Name, err))
+		}
+		sheets[i] = sheet
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	spreadsheet, err := MakeWorkbook(sheets...)
+	if err != nil {
+		return err
+	}
+	*s = spreadsheet
+	return nil
+}
+
+// sheet makes the sheet described by js, reporting its malformed cells and
+// table options as a single joined error.
+func (js jsonSheet) sheet() (Sheet, error) {
+	var errs []error
+	cells := make([][]Cell, len(js.Rows))
+	for r, jsonRow := range js.Rows {
+		cells[r] = make([]Cell, len(jsonRow))
+		for c, raw := range jsonRow {
+			cell, err := jsonCellOf(raw)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("row %d, column %d: %w", r+1, c+1, err))
+			}
+			cells[r][c] = cell
+		}
+	}
+
+	sheet := MakeSheet(js.Name, cells)
+	if js.Table != nil {
+		opts, err := js.Table.options()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		sheet = MakeTableSheet(js.Name, cells, opts)
+	}
+	for _, n := range js.Names {
+		sheet = sheet.WithNames(DefinedName(n))
+	}
+	return sheet, errors.Join(errs...)
+}
+
+func (jt jsonTable) options() (TableOptions, error) {
+	opts := TableOptions{
+		Name:           jt.Name,
+		Header:         jt.Header,
+		AutoFilter:     jt.AutoFilter,
+		BandedRows:     jt.BandedRows,
+		StructuredRefs: jt.StructuredRefs,
+	}
+	var errs []error
+	for _, name := range jt.Totals {
+		total, ok := totalFuncs[name]
+		if !ok && name != "" && name != "none" {
+			errs = append(errs, fmt.Errorf("table: unknown total %q, expected sum, average, count, min, max, or none", name))
+		}
+		opts.Totals = append(opts.Totals, Total{Func: total})
+	}
+	style, ok := tableStyles[jt.Style]
+	if !ok {
+		errs = append(errs, fmt.Errorf("table: unknown style %q, expected blue, gray, or green", jt.Style))
+	}
+	opts.Style = style
+	return opts, errors.Join(errs...)
+}
+
+// jsonCellOf makes the cell described by raw, an object or a bare value.
+func jsonCellOf(raw json.RawMessage) (Cell, error) {
+	var jc jsonCell
+	if bytes.HasPrefix(bytes.TrimSpace(raw), []byte("{")) {
+		if err := decodeJSONStrict(raw, &jc); err != nil {
+			return Cell{}, err
+		}
+	} else {
+		jc.Value = raw
+	}
+
+	value, valueType, err := jsonValue(jc.Value)
+	if err != nil {
+		return Cell{}, err
+	}
+	if jc.Type != "" {
+		valueType = jc.Type
+	}
+	if valueType == "" {
+		if jc.Style == nil && jc.Range == "" {
+			return Cell{}, nil
+		}
+		// An empty cell that is styled or named nonetheless.
+		valueType = "string"
+	}
+
+	var cell Cell
+	if jc.Matrix != nil {
+		if valueType != "formula" {
+			return Cell{}, fmt.Errorf("invalid matrix of a %s cell, expected a formula", valueType)
+		}
+		cell = MakeMatrixCell(value, jc.Matrix.Rows, jc.Matrix.Columns)
+	} else {
+		cell = createCell(cellData{Value: value, ValueType: valueType})
+	}
+	cell.rangeName = jc.Range
+	if jc.Style != nil {
+		style := CellStyle(*jc.Style)
+		cell.style = &style
+	}
+	return cell, nil
+}
+
+// jsonValue returns the text of a JSON value as [MakeCell] takes it, and
+// the value type its kind stands for.
+func jsonValue(raw json.RawMessage) (string, string, error) {
+	var v any
+	if len(raw) == 0 {
+		return "", "", nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return "", "", err
+	}
+	switch v := v.(type) {
+	case nil:
+		return "", "", nil
+	case string:
+		return v, "string", nil
+	case json.Number:
+		return v.String(), "float", nil
+	case bool:
+		return strconv.FormatBool(v), "boolean", nil
+	}
+	return "", "", fmt.Errorf("invalid cell value %s, expected a string, number, or boolean", raw)
+}
+
+// decodeJSONStrict decodes data into v, reporting unknown fields, which are
+// most likely misspelled.
+func decodeJSONStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// MarshalJSON encodes the spreadsheet in the JSON form read by
+// [Spreadsheet.UnmarshalJSON], so that it can be encoded with
+// [json.Marshal]. The cells are those [SheetCells] returns, each an object
+// with its value and type, range name, and style; formulas are given in
+// the OpenFormula notation they are stored in, which [MakeCell] accepts as
+// well, and empty cells are null. Names of blocks of cells and of formulas
+// are given as the "names" of the sheet they are based on.
+//
+// Like SheetCells, it leaves out the options of tables, whose styles and
+// totals are kept as those of their cells, and AutoFilter settings.
+func (s Spreadsheet) MarshalJSON() ([]byte, error) {
+	document := jsonDocument{Sheets: make([]jsonSheet, len(s.Tables))}
+	for i, t := range s.Tables {
+		cells, err := SheetCells(s, t.Name)
+		if err != nil {
+			return nil, err
+		}
+		js := jsonSheet{Name: t.Name, Rows: make([][]json.RawMessage, len(cells))}
+		for r, row := range cells {
+			js.Rows[r] = make([]json.RawMessage, len(row))
+			for c, cell := range row {
+				js.Rows[r][c], err = json.Marshal(jsonCellFor(cell))
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		if t.NamedExpressions != nil {
+			names, err := jsonNames(*t.NamedExpressions, true, func(string) bool { return true })
+			if err != nil {
+				return nil, err
+			}
+			js.Names = names
+		}
+		names, err := jsonNames(s.NamedExpressions, false, func(sheet string) bool { return sheet == t.Name })
+		if err != nil {
+			return nil, err
+		}
+		js.Names = append(js.Names, names...)
+		document.Sheets[i] = js
+	}
+	return json.Marshal(document)
+}
+
+// jsonNames returns the JSON form of the names of ne whose base cell is on
+// a sheet onSheet accepts. Workbook-wide names of single cells are left out,
+// as they are the range names of their cells.
+func jsonNames(ne namedExpressions, sheetScope bool, onSheet func(string) bool) ([]jsonName, error) {
+	var names []jsonName
+	for _, nr := range ne.NamedRanges {
+		ref, err := parseRangeAddress(nr.CellRangeAddress)
+		if err != nil {
+			return nil, fmt.Errorf("name %q: %w", nr.Name, err)
+		}
+		if !onSheet(ref.start.sheet) || !sheetScope && nr.BaseCellAddress == nr.CellRangeAddress {
+			continue
+		}
+		names = append(names, jsonName{Name: nr.Name, Range: a1Range(ref), SheetScope: sheetScope})
+	}
+	for _, expression := range ne.NamedExpressions {
+		base, err := parseRangeAddress(expression.BaseCellAddress)
+		if err != nil {
+			return nil, fmt.Errorf("name %q: %w", expression.Name, err)
+		}
+		if !onSheet(base.start.sheet) {
+			continue
+		}
+		names = append(names, jsonName{Name: expression.Name, Expression: expression.Expression, SheetScope: sheetScope})
+	}
+	return names, nil
+}
+
+// a1Range returns ref in the A1 notation of [DefinedName.Range], qualified
+// with its sheet: "'Sheet1'!$B$2:$B$13".
+func a1Range(ref cellRange) string {
+	sheet := "'" + strings.ReplaceAll(ref.start.sheet, "'", "''") + "'!"
+	address := fmt.Sprintf("%s$%s$%d", sheet, columnToLetters(ref.start.col), ref.start.row)
+	if ref.end == ref.start {
+		return address
+	}
+	return fmt.Sprintf("%s:$%s$%d", address, columnToLetters(ref.end.col), ref.end.row)
+}
+
+// jsonCellFor returns the JSON form of a cell returned by [SheetCells], or
+// nil for an empty one.
+func jsonCellFor(cell Cell) *jsonCell {
+	jc := &jsonCell{Range: cell.rangeName}
+	if cell.style != nil {
+		style := jsonStyle(*cell.style)
+		jc.Style = &style
+	}
+	value := ""
+	switch {
+	case cell.Formula != "":
+		jc.Type, value = "formula", cell.Formula
+		rows, _ := strconv.Atoi(cell.NumberMatrixRowsSpanned)
+		columns, _ := strconv.Atoi(cell.NumberMatrixColumnsSpanned)
+		if rows > 0 && columns > 0 {
+			jc.Matrix = &jsonMatrix{Rows: rows, Columns: columns}
+		}
+	case cell.ValueType == "float" || cell.ValueType == "percentage":
+		jc.Type, value = cell.ValueType, cell.Value
+	case cell.ValueType == "currency":
+		jc.Type, value = "currency", cell.Value
+		if cell.Currency != "" {
+			jc.Type = "currency-" + strings.ToLower(cell.Currency)
+		}
+	case cell.ValueType == "date":
+		jc.Type, value = "date", cell.DateValue
+		if strings.Contains(cell.DateValue, "T") {
+			jc.Type = "datetime"
+		}
+	case cell.ValueType == "time":
+		d, err := parseODFDuration(cell.TimeValue)
+		if err != nil {
+			jc.Type, value = "string", cell.Text
+			break
+		}
+		d = d.Round(time.Second)
+		h, m, sec := d/time.Hour, d%time.Hour/time.Minute, d%time.Minute/time.Second
+		jc.Type, value = "time", fmt.Sprintf("%02d:%02d:%02d", h, m, sec)
+		if cell.StyleName == "DURATION_STYLE" || h >= 24 {
+			jc.Type = "duration"
+		}
+	case cell.ValueType == "boolean":
+		jc.Type, value = "boolean", cell.BooleanValue
+	case cell.ValueType != "" || cell.Text != "":
+		jc.Type, value = "string", cell.Text
+	}
+	if value != "" {
+		jc.Value, _ = json.Marshal(value)
+	}
+	if jc.Type == "" && jc.Range == "" && jc.Style == nil {
+		return nil
+	}
+	return jc
+}
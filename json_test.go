@@ -0,0 +1,145 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+const productsJSON = `{
+  "sheets": [
+    {
+      "name": "Products",
+      "rows": [
+        ["Product", "Unit Price", "Stock", "Listed"],
+        ["Pen", {"value": "1.49", "type": "currency", "range": "PenPrice"}, 120, true],
+        [{"value": "Desk", "style": {"bold": true, "backgroundColor": "#ffff00"}}, {"value": 189, "type": "currency-usd"}, null, {"value": "2026-03-01", "type": "date"}]
+      ],
+      "table": {"name": "Products", "header": true, "autoFilter": true, "totals": ["count", "sum", "none"], "style": "green"}
+    },
+    {
+      "rows": [
+        [{"value": "PenPrice*(1+VAT)", "type": "formula"}, {"value": "Products!B2:B3*2", "type": "formula", "matrix": {"rows": 2, "columns": 1}}],
+        [{"style": {"italic": true}}]
+      ],
+      "names": [
+        {"name": "VAT", "expression": "0.19"},
+        {"name": "Prices", "range": "Products!B2:B3", "sheetScope": true}
+      ]
+    }
+  ]
+}`
+
+func TestUnitSpreadsheetJSON(t *testing.T) {
+	var spreadsheet Spreadsheet
+	if err := json.Unmarshal([]byte(productsJSON), &spreadsheet); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	assert(t, len(spreadsheet.Tables) == 2 && spreadsheet.Tables[1].Name == "Sheet2", "expected the unnamed sheet to be named by its position")
+	products, err := SheetCells(spreadsheet, "Products")
+	if err != nil {
+		t.Fatalf("SheetCells: %v", err)
+	}
+	assert(t, products[1][1].ValueType == "currency" && products[1][1].Currency == "EUR" && products[1][1].rangeName == "PenPrice", fmt.Sprintf("unexpected price %+v", products[1][1]))
+	assert(t, products[1][2].ValueType == "float" && products[1][2].Value == "120", fmt.Sprintf("expected the number to be a float cell, got %+v", products[1][2]))
+	assert(t, products[1][3].ValueType == "boolean" && products[1][3].BooleanValue == "true", fmt.Sprintf("expected the boolean to be a boolean cell, got %+v", products[1][3]))
+	assert(t, products[2][0].style.Bold && products[2][0].style.BackgroundColor == "#ffff00", fmt.Sprintf("expected the style of the desk, got %+v", products[2][0].style))
+	assert(t, products[2][1].Currency == "USD" && products[2][1].Value == "189", fmt.Sprintf("unexpected price %+v", products[2][1]))
+	assert(t, products[3][0].Formula == "of:=SUBTOTAL(3;[.A2:.A3])" && products[3][1].Value == "190.49", fmt.Sprintf("unexpected totals %+v", products[3]))
+	assert(t, spreadsheet.DatabaseRanges != nil && spreadsheet.DatabaseRanges.Ranges[0].Name == "Products", "expected the table's database range")
+
+	formulas := spreadsheet.Tables[1].Rows[0].Cells
+	assert(t, formulas[0].Formula == "of:=PenPrice*(1+VAT)" && formulas[0].Value == "1.7731", fmt.Sprintf("unexpected formula %q = %q", formulas[0].Formula, formulas[0].Value))
+	assert(t, formulas[1].NumberMatrixRowsSpanned == "2", fmt.Sprintf("expected a matrix of two rows, got %+v", formulas[1]))
+	styled := spreadsheet.Tables[1].Rows[1].Cells[0]
+	assert(t, styled.ValueType == "string" && styled.Text == "" && strings.HasPrefix(styled.StyleName, "CUSTOM_STYLE"), fmt.Sprintf("expected an empty styled cell, got %+v", styled))
+	assert(t, spreadsheet.Tables[1].NamedExpressions != nil && spreadsheet.Tables[1].NamedExpressions.NamedRanges[0].Name == "Prices", "expected the name scoped to the sheet")
+}
+
+func TestUnitSpreadsheetJSONRoundTrip(t *testing.T) {
+	var spreadsheet Spreadsheet
+	if err := json.Unmarshal([]byte(productsJSON), &spreadsheet); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+	typed, err := MakeWorkbook(
+		MakeSheet("Typed", typedCells()),
+		MakeSheet("Times", [][]Cell{{MakeCell("19:03", "time"), MakeCell("2:05", "duration"), MakeCell("multi\nline", "string"), MakeCell("0.4223", "percentage"), MakeCell("-2.22", "currency-gbp")}}),
+	)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	for name, original := range map[string]Spreadsheet{"products": spreadsheet, "typed": typed} {
+		t.Run(name, func(t *testing.T) {
+			encoded, err := json.Marshal(original)
+			if err != nil {
+				t.Fatalf("json.Marshal: %v", err)
+			}
+			var decoded Spreadsheet
+			if err := json.Unmarshal(encoded, &decoded); err != nil {
+				t.Fatalf("json.Unmarshal: %v\n%s", err, encoded)
+			}
+
+			for _, sheet := range original.Tables {
+				want, err := SheetCells(original, sheet.Name)
+				if err != nil {
+					t.Fatalf("SheetCells: %v", err)
+				}
+				got, err := SheetCells(decoded, sheet.Name)
+				if err != nil {
+					t.Fatalf("SheetCells: %v", err)
+				}
+				assert(t, len(got) == len(want), fmt.Sprintf("sheet %q: expected %d rows, got %d", sheet.Name, len(want), len(got)))
+				for r := range min(len(got), len(want)) {
+					for c := range want[r] {
+						w, g := want[r][c], got[r][c]
+						equal := c < len(got[r]) && (w.style == nil) == (g.style == nil) && (w.style == nil || *w.style == *g.style)
+						// Decoded formulas carry their namespace and are
+						// passed through.
+						w.style, g.style = nil, nil
+						w.verbatim, g.verbatim = false, false
+						assert(t, equal && w == g, fmt.Sprintf("sheet %q, row %d, column %d: expected %+v, got %+v", sheet.Name, r+1, c+1, want[r][c], got[r][c]))
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUnitSpreadsheetJSONErrors(t *testing.T) {
+	cases := map[string]string{
+		`unknown field "colour"`:                                   `{"sheets": [{"rows": [[{"value": "x", "style": {"colour": "red"}}]]}]}`,
+		`sheet "Sheet1": row 1, column 2: invalid cell value [1]`:  `{"sheets": [{"rows": [["a", [1]]]}]}`,
+		`sheet "Data": table: unknown total "total"`:               `{"sheets": [{"name": "Data", "rows": [], "table": {"totals": ["total"]}}]}`,
+		`sheet "Data": table: unknown style "red"`:                 `{"sheets": [{"name": "Data", "rows": [], "table": {"style": "red"}}]}`,
+		`row 1, column 1: invalid matrix of a string cell`:         `{"sheets": [{"rows": [[{"value": "x", "matrix": {"rows": 1, "columns": 1}}]]}]}`,
+		`sheet "Sheet1": row 2, column 1: invalid date "tomorrow"`: `{"sheets": [{"rows": [[], [{"value": "tomorrow", "type": "date"}]]}]}`,
+		`sheet "Sheet1": name "1st": invalid range name`:           `{"sheets": [{"rows": [], "names": [{"name": "1st", "expression": "1"}]}]}`,
+	}
+	for expected, document := range cases {
+		t.Run(expected, func(t *testing.T) {
+			var spreadsheet Spreadsheet
+			err := json.Unmarshal([]byte(document), &spreadsheet)
+			assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+		})
+	}
+}
+
+func TestSpreadsheetJSONMatchesOdfSchema(t *testing.T) {
+	var spreadsheet Spreadsheet
+	if err := json.Unmarshal([]byte(productsJSON), &spreadsheet); err != nil {
+		t.Fatalf("json.Unmarshal: %v", err)
+	}
+
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	validateAgainstSchema(t, "flat.fods", flatOds)
+}
@@ -0,0 +1,330 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"cmp"
+	"encoding"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MarshalSheet turns v, a slice or array of structs or of pointers to
+// structs, into rows of cells: a header row naming the columns, and a row per
+// element. Each exported field becomes a column, configured by its "ods" tag:
+//
+//	Amount float64   `ods:"Amount,currency-eur,sum"`
+//	Booked time.Time `ods:"Booked,date"`
+//	Note   string    `ods:"-"`
+//
+// The first part of the tag is the header, which defaults to the field name.
+// The second is a value type of [MakeCell]; if it is empty, the cell is made
+// as by [CellOf] from the field's Go type. Strings are parsed as the value
+// type says, and numbers, times, durations, and booleans are converted to it
+// directly, so that a float64 can be a currency and a time.Time a date. The
+// optional third part is the aggregate of the column's totals row, as used by
+// [MarshalTable]: "sum", "average", "count", "min", or "max". Fields tagged
+// "-" are left out.
+//
+// The fields of embedded structs are taken as if they were fields of the
+// outer struct, unless the embedded field has a tag of its own. Nil pointers,
+// including nil embedded structs and nil elements of v, become empty cells.
+// Values implementing [encoding.TextMarshaler] become the text they marshal
+// to, parsed as the value type if the tag gives one.
+//
+// MarshalSheet reports unsupported field types and invalid tags. Values that
+// do not fit their value type, such as a string that is no date, are reported
+// by [MakeSpreadsheet] with their row and column.
+func MarshalSheet(v any) ([][]Cell, error) {
+	cells, _, err := marshalSheet(v)
+	return cells, err
+}
+
+// MarshalTable turns v into a table as [MakeTable] does with the rows of
+// [MarshalSheet]. The first row is always the header; if opts has no totals,
+// they are taken from the tags of the fields.
+func MarshalTable(v any, opts TableOptions) (Spreadsheet, error) {
+	cells, fields, err := marshalSheet(v)
+	if err != nil {
+		return Spreadsheet{}, err
+	}
+	opts.Header = true
+	if len(opts.Totals) == 0 {
+		for _, f := range fields {
+			if f.total != TotalNone {
+				opts.Totals = make([]Total, len(fields))
+				for i, f := range fields {
+					opts.Totals[i] = Total{Func: f.total}
+				}
+				break
+			}
+		}
+	}
+	return MakeTable(cells, opts)
+}
+
+func marshalSheet(v any) ([][]Cell, []sheetField, error) {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		return nil, nil, fmt.Errorf("cannot marshal %T into a sheet, expected a slice or array of structs", v)
+	}
+	fields, err := sheetFields(rv.Type().Elem())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	header := make([]Cell, len(fields))
+	for i, f := range fields {
+		header[i] = MakeCell(f.header, "string")
+	}
+	cells := [][]Cell{header}
+	for i := range rv.Len() {
+		element := rv.Index(i)
+		row := make([]Cell, len(fields))
+		if element.Kind() == reflect.Pointer && element.IsNil() {
+			cells = append(cells, row)
+			continue
+		}
+		element = reflect.Indirect(element)
+		for j, f := range fields {
+			field, err := element.FieldByIndexErr(f.index)
+			if err != nil {
+				// A nil embedded struct holds no values.
+				continue
+			}
+			row[j] = marshalCell(field, f.valueType)
+		}
+		cells = append(cells, row)
+	}
+	return cells, fields, nil
+}
+
+// sheetField is a column of a sheet of structs, as given by the field's tag.
+type sheetField struct {
+	// index is the path to the field through embedded structs, as taken by
+	// reflect.Value.FieldByIndex.
+	index     []int
+	name      string
+	header    string
+	valueType string
+	total     TotalFunc
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	durationType      = reflect.TypeFor[time.Duration]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// totalFuncs maps the aggregates of tags to the totals they stand for.
+var totalFuncs = map[string]TotalFunc{
+	"sum":     TotalSum,
+	"average": TotalAverage,
+	"count":   TotalCount,
+	"min":     TotalMin,
+	"max":     TotalMax,
+}
+
+// sheetFields returns the columns of a sheet of elements of type t, which is
+// a struct or a pointer to one. It reports invalid tags and field types as a
+// single joined error.
+func sheetFields(t reflect.Type) ([]sheetField, error) {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("cannot map %s to the columns of a sheet, expected a struct", t)
+	}
+	var fields []sheetField
+	var errs []error
+	collectSheetFields(t, nil, &fields, &errs)
+	return fields, errors.Join(errs...)
+}
+
+func collectSheetFields(t reflect.Type, index []int, fields *[]sheetField, errs *[]error) {
+	for i := range t.NumField() {
+		sf := t.Field(i)
+		tag, tagged := sf.Tag.Lookup("ods")
+		if tag == "-" {
+			continue
+		}
+		fieldIndex := append(index[:len(index):len(index)], i)
+		ft := sf.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+		if sf.Anonymous && !tagged && ft.Kind() == reflect.Struct && !isCellValueType(ft) {
+			collectSheetFields(ft, fieldIndex, fields, errs)
+			continue
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		parts := strings.Split(tag, ",")
+		f := sheetField{index: fieldIndex, name: sf.Name, header: parts[0]}
+		if f.header == "" {
+			f.header = sf.Name
+		}
+		if len(parts) > 1 {
+			f.valueType = parts[1]
+		}
+		if len(parts) > 2 {
+			total, ok := totalFuncs[parts[2]]
+			if !ok {
+				*errs = append(*errs, fmt.Errorf("field %s: unknown total %q, expected sum, average, count, min, or max", sf.Name, parts[2]))
+			}
+			f.total = total
+		}
+		if len(parts) > 3 {
+			*errs = append(*errs, fmt.Errorf("field %s: invalid tag %q, expected header, value type, and total", sf.Name, tag))
+		}
+		if err := checkFieldType(ft, f.valueType); err != nil {
+			*errs = append(*errs, fmt.Errorf("field %s: %w", sf.Name, err))
+		}
+		*fields = append(*fields, f)
+	}
+}
+
+// isCellValueType reports whether values of type t become a single cell,
+// rather than a struct whose fields are columns of their own.
+func isCellValueType(t reflect.Type) bool {
+	return t == timeType || t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType)
+}
+
+// valueKind classifies the value types of [MakeCell] by the Go values that
+// can be converted to them without passing through a string.
+func valueKind(valueType string) (reflect.Type, bool) {
+	switch valueType {
+	case "float", "percentage", "currency", "currency-eur", "currency-usd", "currency-gbp":
+		return reflect.TypeFor[float64](), true
+	case "date", "datetime", "time":
+		return timeType, true
+	case "duration":
+		return durationType, true
+	case "boolean":
+		return reflect.TypeFor[bool](), true
+	case "string", "formula":
+		return reflect.TypeFor[string](), true
+	}
+	return nil, false
+}
+
+// checkFieldType checks that values of type t, dereferenced, can be marshaled
+// as valueType, or by their Go type if it is empty.
+func checkFieldType(t reflect.Type, valueType string) error {
+	want, ok := valueKind(valueType)
+	if valueType != "" && !ok {
+		return fmt.Errorf("unknown value type %q", valueType)
+	}
+	switch {
+	case isCellValueType(t) && t != timeType:
+		// Marshaled to text, which is parsed as the value type.
+		return nil
+	case t == timeType || t == durationType:
+		if valueType == "" || want == t || valueType == "string" {
+			return nil
+		}
+	case isNumberKind(t.Kind()):
+		if valueType == "" || want.Kind() == reflect.Float64 || valueType == "string" {
+			return nil
+		}
+	case t.Kind() == reflect.Bool:
+		if valueType == "" || want.Kind() == reflect.Bool || valueType == "string" {
+			return nil
+		}
+	case t.Kind() == reflect.String:
+		return nil
+	default:
+		return fmt.Errorf("unsupported type %s", t)
+	}
+	return fmt.Errorf("cannot marshal %s as %s", t, valueType)
+}
+
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// marshalCell makes the cell of a field holding v, as described for
+// [MarshalSheet]. The field's type has been checked by checkFieldType.
+func marshalCell(v reflect.Value, valueType string) Cell {
+	for v.Kind() == reflect.Pointer {
+		if v.IsNil() {
+			return Cell{}
+		}
+		v = v.Elem()
+	}
+
+	switch {
+	case v.Type() == timeType:
+		t := v.Interface().(time.Time)
+		switch valueType {
+		case "date":
+			return MakeDateCell(t)
+		case "datetime":
+			return MakeDateTimeCell(t)
+		case "time":
+			return MakeCell(t.Format("15:04:05"), "time")
+		case "string":
+			return MakeCell(t.Format(time.RFC3339Nano), "string")
+		}
+		return CellOf(t)
+	case v.Type() == durationType:
+		d := v.Interface().(time.Duration)
+		if valueType == "string" {
+			return MakeCell(d.String(), "string")
+		}
+		return MakeDurationCell(d)
+	case isCellValueType(v.Type()):
+		if !v.Type().Implements(textMarshalerType) {
+			// The method has a pointer receiver.
+			p := reflect.New(v.Type())
+			p.Elem().Set(v)
+			v = p
+		}
+		text, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return Cell{err: err}
+		}
+		return MakeCell(string(text), cmp.Or(valueType, "string"))
+	}
+
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if valueType == "" {
+			return MakeIntCell(v.Int())
+		}
+		return MakeCell(strconv.FormatInt(v.Int(), 10), valueType)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if valueType == "" {
+			return uintCell(v.Uint())
+		}
+		return MakeCell(strconv.FormatUint(v.Uint(), 10), valueType)
+	case reflect.Float32, reflect.Float64:
+		cell := floatCell(v.Float(), v.Type().Bits())
+		if valueType == "" || cell.err != nil {
+			return cell
+		}
+		return MakeCell(cell.Value, valueType)
+	case reflect.Bool:
+		if valueType == "string" {
+			return MakeCell(strconv.FormatBool(v.Bool()), "string")
+		}
+		return MakeBoolCell(v.Bool())
+	case reflect.String:
+		return MakeCell(v.String(), cmp.Or(valueType, "string"))
+	}
+	return Cell{err: fmt.Errorf("unsupported type %s", v.Type())}
+}
@@ -0,0 +1,181 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// iban is a value that marshals itself to text.
+type iban string
+
+func (i iban) MarshalText() ([]byte, error) {
+	if i == "" {
+		return nil, errors.New("empty IBAN")
+	}
+	return []byte(strings.ToUpper(string(i))), nil
+}
+
+type booking struct {
+	Booked time.Time `ods:"Booked,date"`
+	Valued *time.Time
+}
+
+type transaction struct {
+	booking
+	Purpose  string
+	Amount   float64 `ods:"Amount,currency-eur,sum"`
+	Account  iban    `ods:"IBAN"`
+	Cleared  bool
+	Duration *time.Duration `ods:",duration,max"`
+	Note     string         `ods:"-"`
+	internal int
+}
+
+func transactions() []transaction {
+	duration := 90 * time.Minute
+	valued := time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)
+	return []transaction{
+		{booking{time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), &valued}, "Rent", -900, "de02120300000000202051", false, nil, "ignored", 1},
+		{booking{time.Date(2026, 3, 5, 0, 0, 0, 0, time.UTC), nil}, "Salary", 300.5, "de02500105170137075030", true, &duration, "", 2},
+	}
+}
+
+func TestUnitMarshalSheet(t *testing.T) {
+	cells, err := MarshalSheet(transactions())
+	if err != nil {
+		t.Fatalf("MarshalSheet: %v", err)
+	}
+
+	header := make([]string, len(cells[0]))
+	for i, c := range cells[0] {
+		header[i] = c.Text
+	}
+	assert(t, strings.Join(header, "|") == "Booked|Valued|Purpose|Amount|IBAN|Cleared|Duration", "unexpected header "+strings.Join(header, "|"))
+	assert(t, len(cells) == 3, fmt.Sprintf("expected a header and two rows, got %d rows", len(cells)))
+
+	expected := [][]Cell{
+		{MakeCell("2026-03-01", "date"), MakeCell("2026-03-02", "date"), MakeCell("Rent", "string"), MakeCell("-900", "currency-eur"), MakeCell("DE02120300000000202051", "string"), MakeBoolCell(false), {}},
+		{MakeCell("2026-03-05", "date"), {}, MakeCell("Salary", "string"), MakeCell("300.5", "currency-eur"), MakeCell("DE02500105170137075030", "string"), MakeBoolCell(true), MakeCell("1:30", "duration")},
+	}
+	for r, row := range expected {
+		for c, e := range row {
+			assert(t, cells[r+1][c] == e, fmt.Sprintf("row %d, column %d: expected %+v, got %+v", r+2, c+1, e, cells[r+1][c]))
+		}
+	}
+
+	// Pointers to structs, nil elements, and nil embedded structs make empty
+	// cells.
+	type outer struct {
+		*booking
+		Name string
+	}
+	cells, err = MarshalSheet([]*outer{nil, {nil, "x"}})
+	if err != nil {
+		t.Fatalf("MarshalSheet: %v", err)
+	}
+	assert(t, len(cells) == 3 && len(cells[1]) == 3 && cells[1][2] == Cell{} && cells[2][0] == Cell{} && cells[2][2].Text == "x", fmt.Sprintf("unexpected cells %+v", cells))
+
+	// Strings are parsed as their value type.
+	cells, err = MarshalSheet([]struct {
+		Day string `ods:"Day,date"`
+	}{{"01.03.2026"}, {"someday"}})
+	if err != nil {
+		t.Fatalf("MarshalSheet: %v", err)
+	}
+	assert(t, cells[1][0].DateValue == "2026-03-01", "expected the German date to be parsed, got "+cells[1][0].DateValue)
+	_, err = MakeSpreadsheet(cells)
+	assert(t, err != nil && strings.Contains(err.Error(), `row 3, column 1: invalid date "someday"`), fmt.Sprintf("expected the invalid date to be reported, got: %v", err))
+}
+
+func TestUnitMarshalSheetErrors(t *testing.T) {
+	cases := map[string]any{
+		"cannot marshal int into a sheet":             42,
+		"cannot map string to the columns of a sheet": []string{"a"},
+		`field Amount: cannot marshal float64 as date`: []struct {
+			Amount float64 `ods:",date"`
+		}{},
+		`field Amount: unknown value type "money"`: []struct {
+			Amount float64 `ods:",money"`
+		}{},
+		`field Amount: unknown total "total"`: []struct {
+			Amount float64 `ods:",float,total"`
+		}{},
+		`field Tags: unsupported type []string`: []struct {
+			Tags []string
+		}{},
+		`field Due: cannot marshal time.Time as boolean`: []struct {
+			Due time.Time `ods:",boolean"`
+		}{},
+	}
+	for expected, v := range cases {
+		t.Run(expected, func(t *testing.T) {
+			_, err := MarshalSheet(v)
+			assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+		})
+	}
+
+	// Errors of text marshalers are reported with the cell.
+	cells, err := MarshalSheet([]struct{ Account iban }{{""}})
+	if err != nil {
+		t.Fatalf("MarshalSheet: %v", err)
+	}
+	_, err = MakeSpreadsheet(cells)
+	assert(t, err != nil && strings.Contains(err.Error(), "row 2, column 1: empty IBAN"), fmt.Sprintf("expected the marshaler's error, got: %v", err))
+}
+
+func TestUnitMarshalTable(t *testing.T) {
+	spreadsheet, err := MarshalTable(transactions(), TableOptions{Name: "Transactions", StructuredRefs: true})
+	if err != nil {
+		t.Fatalf("MarshalTable: %v", err)
+	}
+
+	rows := spreadsheet.Tables[0].Rows
+	assert(t, len(rows) == 4, fmt.Sprintf("expected a header, two rows, and totals, got %d rows", len(rows)))
+	totals := rows[3].Cells
+	assert(t, totals[3].Formula == "of:=SUBTOTAL(9;Amount)" && totals[3].Value == "-599.5", fmt.Sprintf("unexpected total of the amounts %q = %q", totals[3].Formula, totals[3].Value))
+	assert(t, totals[6].Formula == "of:=SUBTOTAL(4;Duration)", "unexpected total of the durations "+totals[6].Formula)
+	assert(t, totals[2].Formula == "", "expected no total for the purpose, got "+totals[2].Formula)
+}
+
+func TestMarshalTableMatchesOdfSchema(t *testing.T) {
+	spreadsheet, err := MarshalTable(transactions(), TableOptions{AutoFilter: true})
+	if err != nil {
+		t.Fatalf("MarshalTable: %v", err)
+	}
+
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	validateAgainstSchema(t, "flat.fods", flatOds)
+}
+
+func TestMarshalSheet(t *testing.T) {
+	cells, err := MarshalSheet(transactions())
+	if err != nil {
+		t.Fatalf("MarshalSheet: %v", err)
+	}
+
+	expectedThisCsv := map[string][][]string{
+		"en_US.UTF-8": {
+			{"Booked", "Valued", "Purpose", "Amount", "IBAN", "Cleared", "Duration"},
+			{"2026-03-01", "2026-03-02", "Rent", "−900.00€", "DE02120300000000202051", "FALSE", ""},
+			{"2026-03-05", "", "Salary", "300.50€", "DE02500105170137075030", "TRUE", "01:30:00"},
+		},
+		"de_DE.UTF-8": {
+			{"Booked", "Valued", "Purpose", "Amount", "IBAN", "Cleared", "Duration"},
+			{"2026-03-01", "2026-03-02", "Rent", "−900.00€", "DE02120300000000202051", "FALSCH", ""},
+			{"2026-03-05", "", "Salary", "300.50€", "DE02500105170137075030", "WAHR", "01:30:00"},
+		},
+	}
+
+	integrationTest(t, "marshal-sheet", "ods", cells, expectedThisCsv)
+	integrationTest(t, "marshal-sheet", "fods", cells, expectedThisCsv)
+}
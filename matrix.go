@@ -0,0 +1,141 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// MakeMatrixCell creates a cell holding an array formula, whose result fills
+// a block of rows by columns cells, with the cell itself at its top left.
+// The formula is given in A1 notation like for [MakeCell], optionally in the
+// braces Excel shows array formulas in, such as "{=TRANSPOSE(A1:C1)}".
+//
+// The other cells of the block are covered by the formula: [MakeSpreadsheet]
+// fills them in, extending the sheet as needed, and reports an error if any
+// of them holds content of its own or belongs to another matrix formula.
+// Leave them out or pass empty cells, e.g. Cell{}, in their place.
+func MakeMatrixCell(formula string, rows, columns int) Cell {
+	if inner, ok := strings.CutPrefix(formula, "{"); ok {
+		if inner, ok = strings.CutSuffix(inner, "}"); ok {
+			formula = inner
+		}
+	}
+	cell := MakeCell(formula, "formula")
+	if rows < 1 || columns < 1 {
+		cell.err = fmt.Errorf("invalid matrix of %d rows and %d columns, expected at least one of each", rows, columns)
+		return cell
+	}
+	cell.NumberMatrixRowsSpanned = strconv.Itoa(rows)
+	cell.NumberMatrixColumnsSpanned = strconv.Itoa(columns)
+	return cell
+}
+
+// MarshalXML writes the cells covered by a matrix formula as
+// table:covered-table-cell elements, and all others as table:table-cell.
+func (c Cell) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	// The name is set in either case, as encoding/xml names the elements of
+	// marshalers after their type rather than their XMLName field.
+	start.Name = xml.Name{Local: "table:table-cell"}
+	if c.covered {
+		start.Name.Local = "table:covered-table-cell"
+	}
+	// The conversion drops the method, which would otherwise be called
+	// again.
+	type plainCell Cell
+	return e.EncodeElement(plainCell(c), start)
+}
+
+// isMatrix reports whether the cell holds a matrix formula.
+func (c Cell) isMatrix() bool {
+	return c.NumberMatrixRowsSpanned != "" || c.NumberMatrixColumnsSpanned != ""
+}
+
+// matrixSize returns the rows and columns the matrix formula of the cell
+// spans, which are 1 for cells that hold none.
+func (c Cell) matrixSize() (rows, columns int) {
+	rows, columns = 1, 1
+	if n, err := strconv.Atoi(c.NumberMatrixRowsSpanned); err == nil && n > 0 {
+		rows = n
+	}
+	if n, err := strconv.Atoi(c.NumberMatrixColumnsSpanned); err == nil && n > 0 {
+		columns = n
+	}
+	return rows, columns
+}
+
+// coveredSpan is a run of cells of a row covered by the matrix formula at
+// anchorRow and anchorCol. All indices are 0-based; to is inclusive.
+type coveredSpan struct {
+	from, to             int
+	anchorRow, anchorCol int
+}
+
+// matrixCover covers the cells of the matrix formulas of a sheet while its
+// rows are added one by one, remembering the cells of rows still to come.
+type matrixCover struct {
+	pending map[int][]coveredSpan
+}
+
+// cover marks the cells of the rowIdx-th row covered by matrix formulas in it
+// or in rows above it, extending the row as far as the formulas reach. It
+// returns the row and the errors of matrix formulas reaching beyond the
+// sheet or overlapping content. The row is modified in place.
+func (m *matrixCover) cover(rowIdx int, cells []Cell) ([]Cell, []error) {
+	var errs []error
+	spans := m.pending[rowIdx]
+	delete(m.pending, rowIdx)
+
+	for c := range cells {
+		if !cells[c].isMatrix() || cells[c].err != nil {
+			continue
+		}
+		rows, columns := cells[c].matrixSize()
+		if rowIdx+rows > maxRows || c+columns > maxColumns {
+			errs = append(errs, fmt.Errorf("row %d, column %d: matrix formula reaches beyond the %d rows and %d columns of a sheet", rowIdx+1, c+1, maxRows, maxColumns))
+			continue
+		}
+		if columns > 1 {
+			spans = append(spans, coveredSpan{from: c + 1, to: c + columns - 1, anchorRow: rowIdx, anchorCol: c})
+		}
+		for r := rowIdx + 1; r < rowIdx+rows; r++ {
+			if m.pending == nil {
+				m.pending = map[int][]coveredSpan{}
+			}
+			m.pending[r] = append(m.pending[r], coveredSpan{from: c, to: c + columns - 1, anchorRow: rowIdx, anchorCol: c})
+		}
+	}
+
+	for _, span := range spans {
+		for len(cells) <= span.to {
+			cells = append(cells, Cell{})
+		}
+		for c := span.from; c <= span.to; c++ {
+			if holdsContent(cells[c]) {
+				errs = append(errs, fmt.Errorf("row %d, column %d: matrix formula overlaps the cell at row %d, column %d", span.anchorRow+1, span.anchorCol+1, rowIdx+1, c+1))
+				continue
+			}
+			cells[c] = Cell{covered: true}
+		}
+	}
+	return cells, errs
+}
+
+// done reports whether no rows remain to be covered.
+func (m *matrixCover) done() bool {
+	return len(m.pending) == 0
+}
+
+// holdsContent reports whether a cell holds anything a matrix formula must
+// not cover: a value, a formula, a range name, or the cover of another
+// matrix formula.
+func holdsContent(c Cell) bool {
+	return c.Text != "" || c.Value != "" || c.DateValue != "" || c.TimeValue != "" ||
+		c.BooleanValue != "" || c.Formula != "" || c.structuredSource != "" || c.r1c1Source != "" || c.rangeName != "" ||
+		c.covered || c.err != nil
+}
@@ -0,0 +1,171 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func matrixCells() [][]Cell {
+	return [][]Cell{
+		{MakeCell("1", "float"), MakeCell("2", "float"), MakeCell("3", "float")},
+		{MakeMatrixCell("{=TRANSPOSE(A1:C1)}", 3, 1), MakeMatrixCell("A1:C1*2", 1, 2)},
+	}
+}
+
+func TestUnitMatrixCell(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheet(matrixCells())
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+
+	rows := spreadsheet.Tables[0].Rows
+	assert(t, len(rows) == 4, fmt.Sprintf("expected the sheet to be extended to four rows, got %d", len(rows)))
+	anchor := rows[1].Cells[0]
+	assert(t, anchor.Formula == "of:=TRANSPOSE([.A1:.C1])", "expected the braces to be dropped, got "+anchor.Formula)
+	assert(t, anchor.NumberMatrixRowsSpanned == "3" && anchor.NumberMatrixColumnsSpanned == "1", "expected the anchor to span three rows and one column")
+	assert(t, anchor.Value == "" && anchor.ValueType == "", "expected the result to be left to consumers")
+	assert(t, len(rows[1].Cells) == 3 && rows[1].Cells[2].covered, "expected the second matrix to cover the cell to its right")
+	assert(t, len(rows[2].Cells) == 1 && rows[2].Cells[0].covered, "expected the cell below the anchor to be covered")
+	assert(t, len(rows[3].Cells) == 1 && rows[3].Cells[0].covered, "expected the last cell of the block to be covered")
+
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	for _, expected := range []string{
+		`table:formula="of:=TRANSPOSE([.A1:.C1])" table:number-matrix-columns-spanned="1" table:number-matrix-rows-spanned="3"`,
+		`<table:covered-table-cell></table:covered-table-cell>`,
+	} {
+		assert(t, strings.Contains(flatOds, expected), fmt.Sprintf("expected %q in the document", expected))
+	}
+	assert(t, strings.Count(flatOds, "<table:covered-table-cell>") == 3, "expected three covered cells")
+
+	// Covered cells refer to the formula they belong to.
+	graph := MakeDependencyGraph(spreadsheet)
+	covered := CellAddress{Sheet: "Sheet1", Row: 4, Column: 1}
+	assert(t, reflect.DeepEqual(graph.Precedents(covered), []CellAddress{{Sheet: "Sheet1", Row: 2, Column: 1}}), fmt.Sprintf("expected the anchor as precedent, got %v", graph.Precedents(covered)))
+}
+
+func TestUnitMatrixCellErrors(t *testing.T) {
+	cases := map[string]struct {
+		cells    [][]Cell
+		expected string
+	}{
+		"overlapping content": {
+			[][]Cell{{MakeMatrixCell("A3:B3", 1, 2), MakeCell("1", "float")}},
+			"row 1, column 1: matrix formula overlaps the cell at row 1, column 2",
+		},
+		"overlapping content below": {
+			[][]Cell{{MakeMatrixCell("TRANSPOSE(B3:D3)", 3, 1)}, {}, {MakeCell("x", "string")}},
+			"row 1, column 1: matrix formula overlaps the cell at row 3, column 1",
+		},
+		"overlapping matrices": {
+			[][]Cell{{MakeMatrixCell("C3:D4", 2, 2)}, {Cell{}, MakeMatrixCell("C3:D4", 2, 2)}},
+			"row 1, column 1: matrix formula overlaps the cell at row 2, column 2",
+		},
+		"overlapping range name": {
+			[][]Cell{{MakeMatrixCell("C3:D3", 1, 2), MakeRangeCell("", "string", "Result")}},
+			"row 1, column 1: matrix formula overlaps the cell at row 1, column 2",
+		},
+		"empty matrix": {
+			[][]Cell{{MakeMatrixCell("A2", 0, 1)}},
+			"row 1, column 1: invalid matrix of 0 rows and 1 columns",
+		},
+		"beyond the sheet": {
+			[][]Cell{{MakeMatrixCell("A2", 1, maxColumns+1)}},
+			"row 1, column 1: matrix formula reaches beyond the 1048576 rows and 16384 columns of a sheet",
+		},
+		"circular reference": {
+			[][]Cell{{MakeMatrixCell("SUM(A1:A2)+{1;2}", 2, 1)}},
+			"row 1, column 1: circular reference A1 -> A1",
+		},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := MakeSpreadsheet(c.cells)
+			if err == nil || !strings.Contains(err.Error(), c.expected) {
+				t.Errorf("expected an error containing %q, got: %v", c.expected, err)
+			}
+		})
+	}
+
+	// Empty cells in the block are covered like missing ones.
+	_, err := MakeSpreadsheet([][]Cell{{MakeMatrixCell("C3:D3", 1, 2), MakeCell("", "string")}})
+	assert(t, err == nil, fmt.Sprintf("expected an empty cell to be covered, got: %v", err))
+}
+
+func TestUnitMatrixCellRoundTrip(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheet(matrixCells())
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	read, err := ReadFlatOds(strings.NewReader(flatOds))
+	if err != nil {
+		t.Fatalf("ReadFlatOds: %v", err)
+	}
+	assert(t, reflect.DeepEqual(read.Tables, spreadsheet.Tables), fmt.Sprintf("expected the matrix formulas to be read back:\n%v\n%v", read.Tables, spreadsheet.Tables))
+
+	cells, err := SheetCells(read, "Sheet1")
+	if err != nil {
+		t.Fatalf("SheetCells: %v", err)
+	}
+	rebuilt, err := MakeSpreadsheet(cells)
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet of the read cells: %v", err)
+	}
+	assert(t, reflect.DeepEqual(rebuilt.Tables, spreadsheet.Tables), "expected the read cells to rebuild the sheet")
+
+	// A matrix formula reaching below the last row written gets its rows
+	// added when the package is closed.
+	written := writeSheet(t, matrixCells())
+	streamed, err := ReadOds(bytes.NewReader(written.Bytes()), int64(written.Len()))
+	if err != nil {
+		t.Fatalf("ReadOds: %v", err)
+	}
+	rows := streamed.Tables[0].Rows
+	assert(t, len(rows) == 4 && rows[3].Cells[0].covered, fmt.Sprintf("expected the streamed sheet to end in a covered cell, got %v", rows))
+}
+
+func TestMatrixCellMatchesOdfSchema(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheet(matrixCells())
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	validateAgainstSchema(t, "flat.fods", flatOds)
+}
+
+func TestMatrixCell(t *testing.T) {
+	expectedThisCsv := map[string][][]string{
+		"en_US.UTF-8": {
+			{"1.00", "2.00", "3.00"},
+			{"1", "2", "4"},
+			{"2", "", ""},
+			{"3", "", ""},
+		},
+		"de_DE.UTF-8": {
+			{"1,00", "2,00", "3,00"},
+			{"1", "2", "4"},
+			{"2", "", ""},
+			{"3", "", ""},
+		},
+	}
+
+	integrationTest(t, "matrix", "ods", matrixCells(), expectedThisCsv)
+	integrationTest(t, "matrix", "fods", matrixCells(), expectedThisCsv)
+}
@@ -0,0 +1,227 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefinedName gives a name to a block of cells or to a formula, so that
+// formulas can refer to it by name. Names are defined by a sheet with
+// [Sheet.WithNames].
+type DefinedName struct {
+	// Name is what formulas refer to. It consists of letters, digits,
+	// underscores, and dots, begins with a letter or an underscore, and must
+	// not look like a cell address in A1 or R1C1 notation ("Q1", "R2C3").
+	Name string
+	// Range is the block of cells the name stands for, in the A1 notation of
+	// formulas: "B2:B13", "B2", "A:A", or "Data!B2:B13" on another sheet than
+	// the one defining the name. It is stored with absolute references,
+	// whether or not they are marked with "$".
+	Range string
+	// Expression is the formula the name stands for instead, written like
+	// those of [MakeCell], such as "0.19" or "Rates!B2*12". References in
+	// it are absolute, as in Range. Exactly one of Range and Expression is
+	// set.
+	Expression string
+	// SheetScope limits the name to the formulas of the sheet defining it.
+	// Other names are shared by the whole workbook. A sheet-scoped name may
+	// reuse the name of a workbook-wide one, which it hides on its sheet.
+	SheetScope bool
+}
+
+// WithNames returns a copy of the sheet that additionally defines names.
+// Unqualified references in their ranges and expressions refer to this
+// sheet. Names must be unique within their scope; workbook-wide names share
+// theirs with the range names of [MakeRangeCell] and
+// [TableOptions.StructuredRefs].
+func (s Sheet) WithNames(names ...DefinedName) Sheet {
+	s.names = append(slices.Clip(s.names), names...)
+	return s
+}
+
+var (
+	// rangeNameSyntax matches the characters a name may consist of.
+	rangeNameSyntax = regexp.MustCompile(`^[\p{L}_][\p{L}\p{N}_.]*$`)
+	// r1c1NameRef matches names that look like a cell, row, or column
+	// reference in R1C1 notation ("R2C3", "R", "C12"), which Excel rejects as
+	// a defined name.
+	r1c1NameRef = regexp.MustCompile(`^(?i:r[0-9]*c?[0-9]*|c[0-9]*)$`)
+)
+
+// validateRangeName checks name against the rules described for
+// [DefinedName.Name]. The range names of [MakeRangeCell] are not checked, as
+// they were accepted as they are before defined names existed.
+func validateRangeName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("range name is empty")
+	case !rangeNameSyntax.MatchString(name):
+		return fmt.Errorf("invalid range name %q, expected letters, digits, underscores, and dots, beginning with a letter or an underscore", name)
+	case structuredRefCellRef.MatchString(name) || r1c1NameRef.MatchString(name):
+		return fmt.Errorf("range name %q looks like a cell address", name)
+	}
+	return nil
+}
+
+// sheetExpression is a named expression together with the sheet defining it
+// and whether it is scoped to that sheet, which decide where the names in it
+// are looked up.
+type sheetExpression struct {
+	namedExpression
+	sheetScope bool
+}
+
+// addNames defines the names of sheet, which the builder added last. It
+// reports invalid and duplicate names, and ranges that do not resolve, as a
+// single joined error; the references of expressions are checked by
+// checkReferences once all sheets are added.
+func (b *workbookBuilder) addNames(sheet string, names []DefinedName) error {
+	var errs []error
+	for _, n := range names {
+		if err := b.addName(sheet, n); err != nil {
+			errs = append(errs, fmt.Errorf("name %q: %w", n.Name, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (b *workbookBuilder) addName(sheet string, n DefinedName) error {
+	if err := validateRangeName(n.Name); err != nil {
+		return err
+	}
+	scope := b.rangeNames
+	if n.SheetScope {
+		if b.sheetRangeNames[sheet] == nil {
+			b.sheetRangeNames[sheet] = map[string]bool{}
+		}
+		scope = b.sheetRangeNames[sheet]
+	}
+	if scope[n.Name] {
+		return fmt.Errorf("duplicate range name %q", n.Name)
+	}
+
+	var nr *namedRange
+	var ne *namedExpression
+	switch {
+	case (n.Range == "") == (n.Expression == ""):
+		return errors.New("exactly one of Range and Expression must be set")
+	case n.Range != "":
+		ref, err := b.definedRange(sheet, n.Range)
+		if err != nil {
+			return err
+		}
+		nr = &namedRange{
+			Name:             n.Name,
+			BaseCellAddress:  absoluteAddress(cellRange{ref.start, ref.start}),
+			CellRangeAddress: absoluteAddress(ref),
+		}
+	default:
+		expression, err := definedExpression(sheet, n.Expression)
+		if err != nil {
+			return err
+		}
+		ne = &namedExpression{
+			Name:            n.Name,
+			BaseCellAddress: absoluteAddress(cellRange{cellAddress{sheet, 1, 1}, cellAddress{sheet, 1, 1}}),
+			Expression:      expression,
+		}
+		if !strings.Contains(n.Expression, ":=") {
+			// Expressions passed through are left to the consumer, as
+			// formulas are.
+			b.sheetExpressions[sheet] = append(b.sheetExpressions[sheet], sheetExpression{*ne, n.SheetScope})
+		}
+	}
+
+	scope[n.Name] = true
+	names := &b.names
+	if n.SheetScope {
+		t := &b.tables[len(b.tables)-1]
+		if t.NamedExpressions == nil {
+			t.NamedExpressions = &namedExpressions{}
+		}
+		names = t.NamedExpressions
+	}
+	if nr != nil {
+		names.NamedRanges = append(names.NamedRanges, *nr)
+	} else {
+		names.NamedExpressions = append(names.NamedExpressions, *ne)
+	}
+	return nil
+}
+
+// definedRange parses the range of a name defined by sheet.
+func (b *workbookBuilder) definedRange(sheet, address string) (cellRange, error) {
+	node, err := parseFormula(strings.TrimPrefix(address, "="), a1Notation)
+	reference, ok := node.(referenceNode)
+	if err != nil || !ok {
+		return cellRange{}, fmt.Errorf("invalid range %q, expected a cell or a block of cells such as B2:B13", address)
+	}
+	ref := reference.ref
+	if ref.start.sheet == "" {
+		ref.start.sheet, ref.end.sheet = sheet, sheet
+	}
+	switch {
+	case ref.start.sheet != ref.end.sheet:
+		return cellRange{}, fmt.Errorf("range %q spans more than one sheet", address)
+	case !b.sheetNames[ref.start.sheet]:
+		return cellRange{}, fmt.Errorf("reference to missing sheet %q", ref.start.sheet)
+	case ref.end.row > maxRows || ref.end.col > maxColumns:
+		return cellRange{}, fmt.Errorf("range %q is beyond the %d rows and %d columns of a sheet", address, maxRows, maxColumns)
+	}
+	return ref, nil
+}
+
+// definedExpression translates the expression of a name defined by sheet
+// into OpenFormula, with all references made absolute and qualified with
+// their sheet. Expressions that already carry a namespace prefix are passed
+// through, as by [MakeCell].
+func definedExpression(sheet, expression string) (string, error) {
+	if strings.Contains(expression, ":=") {
+		return expression, nil
+	}
+	node, err := parseFormula(strings.TrimPrefix(expression, "="), a1Notation)
+	if err != nil {
+		return "", fmt.Errorf("invalid expression %q: %w", expression, err)
+	}
+	node, err = replaceFormula(node, func(node formulaNode) (formulaNode, bool, error) {
+		switch n := node.(type) {
+		case structuredNode:
+			return nil, true, fmt.Errorf("structured reference %s in a named expression", n.text)
+		case referenceNode:
+			if n.ref.start.sheet == "" {
+				n.ref.start.sheet = sheet
+			}
+			if n.ref.end.sheet == "" {
+				n.ref.end.sheet = n.ref.start.sheet
+			}
+			n.address = absoluteAddress(n.ref)
+			return n, true, nil
+		}
+		return nil, false, nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return formulaNamespace + "=" + renderFormula(node), nil
+}
+
+// absoluteAddress returns the address of ref as named ranges spell it, with
+// the sheet and all rows and columns absolute: "$Sheet1.$B$2:.$B$13".
+func absoluteAddress(ref cellRange) string {
+	address := fmt.Sprintf("$%s.$%s$%d", quoteSheetName(ref.start.sheet), columnToLetters(ref.start.col), ref.start.row)
+	if ref.end == ref.start {
+		return address
+	}
+	sheet := "."
+	if ref.end.sheet != ref.start.sheet {
+		sheet = "$" + quoteSheetName(ref.end.sheet) + "."
+	}
+	return fmt.Sprintf("%s:%s$%s$%d", address, sheet, columnToLetters(ref.end.col), ref.end.row)
+}
@@ -0,0 +1,170 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// namedSheets are sheets using a workbook-wide named range and named
+// expression, and a sheet-scoped name each sheet defines differently.
+func namedSheets() []Sheet {
+	return []Sheet{
+		MakeSheet("Summary", [][]Cell{
+			{MakeCell("SUM(Rates)", "formula"), MakeCell("SUM(Rates)*(1+VAT)", "formula"), MakeCell("Factor*A1", "formula")},
+		}).WithNames(DefinedName{Name: "Factor", Expression: "2", SheetScope: true}),
+		MakeSheet("Data", [][]Cell{
+			{MakeCell("100", "float")},
+			{MakeCell("200", "float")},
+			{MakeCell("300", "float")},
+			{MakeCell("Factor*2", "formula")},
+		}).WithNames(
+			DefinedName{Name: "Rates", Range: "A1:A3"},
+			DefinedName{Name: "VAT", Expression: "0.19"},
+			DefinedName{Name: "Factor", Expression: "A1", SheetScope: true},
+		),
+	}
+}
+
+func TestUnitDefinedNames(t *testing.T) {
+	spreadsheet, err := MakeWorkbook(namedSheets()...)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	names := spreadsheet.NamedExpressions
+	assert(t, slices.Equal(names.NamedRanges, []namedRange{{Name: "Rates", BaseCellAddress: "$Data.$A$1", CellRangeAddress: "$Data.$A$1:.$A$3"}}), fmt.Sprintf("unexpected named ranges %v", names.NamedRanges))
+	assert(t, slices.Equal(names.NamedExpressions, []namedExpression{{Name: "VAT", BaseCellAddress: "$Data.$A$1", Expression: "of:=0.19"}}), fmt.Sprintf("unexpected named expressions %v", names.NamedExpressions))
+	for i, expected := range []string{"of:=2", "of:=[$Data.$A$1]"} {
+		local := spreadsheet.Tables[i].NamedExpressions
+		assert(t, local != nil && len(local.NamedExpressions) == 1 && local.NamedExpressions[0].Expression == expected, fmt.Sprintf("sheet %d: expected Factor to stand for %s, got %v", i+1, expected, local))
+	}
+
+	check := func(spreadsheet Spreadsheet) {
+		t.Helper()
+		summary := spreadsheet.Tables[0].Rows[0].Cells
+		for i, expected := range []string{"600", "714", "1200"} {
+			assert(t, summary[i].Value == expected, fmt.Sprintf("column %d: expected %s, got %q", i+1, expected, summary[i].Value))
+		}
+		data := spreadsheet.Tables[1].Rows[3].Cells[0]
+		assert(t, data.Value == "200", "expected the sheet-scoped name of the data sheet to be used, got "+data.Value)
+
+		graph := MakeDependencyGraph(spreadsheet)
+		b1 := CellAddress{Sheet: "Summary", Row: 1, Column: 2}
+		assert(t, slices.Equal(graph.Names(b1), []string{"Rates", "VAT"}), fmt.Sprintf("unexpected names %v", graph.Names(b1)))
+		assert(t, len(graph.Precedents(b1)) == 3, fmt.Sprintf("expected the cells of Rates as precedents, got %v", graph.Precedents(b1)))
+		a4 := CellAddress{Sheet: "Data", Row: 4, Column: 1}
+		assert(t, slices.Equal(graph.Precedents(a4), []CellAddress{{Sheet: "Data", Row: 1, Column: 1}}), fmt.Sprintf("expected the cell of Factor as precedent, got %v", graph.Precedents(a4)))
+	}
+	check(spreadsheet)
+
+	// The names survive a round trip, including those scoped to a sheet.
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	read, err := ReadFlatOds(strings.NewReader(flatOds))
+	if err != nil {
+		t.Fatalf("ReadFlatOds: %v", err)
+	}
+	for _, t := range read.Tables {
+		for _, r := range t.Rows {
+			for c := range r.Cells {
+				if r.Cells[c].Formula != "" {
+					clearResult(&r.Cells[c])
+				}
+			}
+		}
+	}
+	evaluateFormulas(read.Tables, read.NamedExpressions)
+	check(read)
+
+	// Range names of cells are taken as they were before defined names
+	// existed, and generated column names avoid R1C1 addresses.
+	spreadsheet, err = MakeSpreadsheet([][]Cell{{MakeRangeCell("1", "float", "my-name"), MakeRangeCell("2", "float", "R2")}})
+	assert(t, err == nil, fmt.Sprintf("expected the range names of cells to be accepted, got: %v", err))
+	assert(t, err != nil || len(spreadsheet.NamedExpressions.NamedRanges) == 2, "expected both range names to be defined")
+	assert(t, sanitizeRangeName("RC", 0) == "RC_", "expected a column named RC to get an underscore, got "+sanitizeRangeName("RC", 0))
+}
+
+func TestUnitDefinedNameErrors(t *testing.T) {
+	cases := map[string]DefinedName{
+		`name "": range name is empty`:                                                 {Range: "A1"},
+		`name "1st": invalid range name "1st"`:                                         {Name: "1st", Range: "A1"},
+		`name "Q1": range name "Q1" looks like a cell address`:                         {Name: "Q1", Range: "A1"},
+		`name "r2c3": range name "r2c3" looks like a cell address`:                     {Name: "r2c3", Expression: "1"},
+		`name "Both": exactly one of Range and Expression must be set`:                 {Name: "Both", Range: "A1", Expression: "1"},
+		`name "Neither": exactly one of Range and Expression must be set`:              {Name: "Neither"},
+		`name "Input": duplicate range name "Input"`:                                   {Name: "Input", Range: "A1"},
+		`name "Shared": duplicate range name "Shared"`:                                 {Name: "Shared", Expression: "2"},
+		`name "Missing": reference to missing sheet "Other"`:                           {Name: "Missing", Range: "Other!A1:B2"},
+		`name "Sum": invalid range "SUM(A1:A2)"`:                                       {Name: "Sum", Range: "SUM(A1:A2)"},
+		`name "Far": range "A1:A1048577" is beyond`:                                    {Name: "Far", Range: "A1:A1048577"},
+		`name "Bad": invalid expression "1+"`:                                          {Name: "Bad", Expression: "1+"},
+		`name "Structured": structured reference Table1[Amount] in a named expression`: {Name: "Structured", Expression: "SUM(Table1[Amount])"},
+		`name "Unknown": undefined name "Undefined"`:                                   {Name: "Unknown", Expression: "Undefined*2"},
+		`name "Elsewhere": reference to missing sheet "Other"`:                         {Name: "Elsewhere", Expression: "Other!A1"},
+		`name "LocalElsewhere": undefined name "Local"`:                                {Name: "LocalElsewhere", Expression: "Local"},
+		`name "GlobalLocal": undefined name "Local"`:                                   {Name: "GlobalLocal", Expression: "Names!A1+Local"},
+	}
+	for expected, name := range cases {
+		t.Run(expected, func(t *testing.T) {
+			// The first sheet defines a sheet-scoped name, which the
+			// second sheet does not see, and a workbook-wide one.
+			_, err := MakeWorkbook(
+				MakeSheet("Names", nil).WithNames(
+					DefinedName{Name: "Local", Range: "A1", SheetScope: true},
+					DefinedName{Name: "Shared", Expression: "1"},
+				),
+				MakeSheet("Sheet1", [][]Cell{{MakeRangeCell("1", "float", "Input")}}).WithNames(name),
+			)
+			expected := `sheet "Sheet1": ` + expected
+			assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+		})
+	}
+
+	// A sheet-scoped name may hide a workbook-wide one, but is unique on its
+	// sheet.
+	_, err := MakeWorkbook(MakeSheet("Sheet1", [][]Cell{{MakeRangeCell("1", "float", "Input"), MakeCell("Input*2", "formula")}}).WithNames(
+		DefinedName{Name: "Input", Expression: "2", SheetScope: true},
+	))
+	assert(t, err == nil, fmt.Sprintf("expected the sheet-scoped name to hide the workbook-wide one, got: %v", err))
+	_, err = MakeWorkbook(MakeSheet("Sheet1", nil).WithNames(
+		DefinedName{Name: "Rate", Expression: "2", SheetScope: true},
+		DefinedName{Name: "Rate", Range: "A1", SheetScope: true},
+	))
+	assert(t, err != nil && strings.Contains(err.Error(), `name "Rate": duplicate range name "Rate"`), fmt.Sprintf("expected the duplicate to be reported, got: %v", err))
+}
+
+func TestDefinedNamesMatchOdfSchema(t *testing.T) {
+	spreadsheet, err := MakeWorkbook(namedSheets()...)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	validateAgainstSchema(t, "flat.fods", flatOds)
+}
+
+func TestDefinedNames(t *testing.T) {
+	spreadsheet, err := MakeWorkbook(namedSheets()...)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	// LibreOffice converts the first sheet only.
+	expectedThisCsv := make(map[string][][]string)
+	expectedThisCsv["en_US.UTF-8"] = [][]string{{"600", "714", "1200"}}
+	expectedThisCsv["de_DE.UTF-8"] = [][]string{{"600", "714", "1200"}}
+
+	renderAndCompare(t, "names", "ods", spreadsheet, expectedThisCsv)
+	renderAndCompare(t, "names", "fods", spreadsheet, expectedThisCsv)
+}
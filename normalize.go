@@ -0,0 +1,146 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+// volatileMetadata are the elements of office:meta that office applications
+// update whenever they save a document, by their qualified names.
+var volatileMetadata = map[string]bool{
+	"dc:date":                 true,
+	"meta:document-statistic": true,
+	"meta:editing-cycles":     true,
+	"meta:editing-duration":   true,
+	"meta:generator":          true,
+	"meta:print-date":         true,
+	"meta:printed-by":         true,
+}
+
+// NormalizeFlatOds rewrites a flat OpenDocument XML document (.fods), such
+// as one saved by LibreOffice, in the canonical form [WriteCanonicalFlatOds]
+// writes, so that it diffs cleanly against an earlier version of itself, or
+// against the document rechenbrett wrote before LibreOffice saved it. Run
+// through NormalizeFlatOds, a canonical document only loses its generator.
+//
+// Content that changes on every save is stripped: the settings holding the
+// view state, the generator, editing duration and cycles, statistics, and
+// dates of saving and printing in the metadata, the rsid attributes
+// LibreOffice tracks edits with, and calcext:value-type attributes repeating
+// office:value-type. Automatic styles nothing refers to are dropped, and
+// the rest are renamed by their content: styles equal to those rechenbrett
+// generates get their names, such as FLOAT_STYLE, and others a name made of
+// their kind and a short hash of their content, such as
+// CUSTOM_STYLE_1a2b3c4d for cell styles or DATA_STYLE_1a2b3c4d for number
+// formats, which merges styles of equal content. The document is then
+// indented as WriteCanonicalFlatOds does, with each cell on a line of its
+// own.
+//
+// NormalizeFlatOds holds the document in memory as a whole. It does not
+// change the values, formulas, or formatting of the document.
+func NormalizeFlatOds(r io.Reader, w io.Writer) error {
+	root, err := parseXMLTree(r)
+	if err != nil {
+		return fmt.Errorf("reading flat ods document: %w", err)
+	}
+	if name := qualifiedName(root.name); name != "office:document" {
+		return fmt.Errorf("reading flat ods document: root element <%s> is not <office:document>", name)
+	}
+	naming, err := normalizedStyles()
+	if err != nil {
+		return err
+	}
+
+	stripVolatile(root)
+	canonicalizeStyles(root, naming)
+	if err := root.writeDocument(w); err != nil {
+		return fmt.Errorf("writing flat ods document: %w", err)
+	}
+	return nil
+}
+
+// stripVolatile drops the content of a document that office applications
+// change whenever they save it.
+func stripVolatile(root *xmlNode) {
+	root.children = slices.DeleteFunc(root.children, func(n *xmlNode) bool {
+		return qualifiedName(n.name) == "office:settings"
+	})
+	if meta := root.child("office:meta"); meta != nil {
+		meta.children = slices.DeleteFunc(meta.children, func(n *xmlNode) bool {
+			return volatileMetadata[qualifiedName(n.name)]
+		})
+	}
+
+	root.walk(func(e *xmlNode) {
+		valueType := e.attr("office:value-type")
+		e.attrs = slices.DeleteFunc(e.attrs, func(a xml.Attr) bool {
+			return strings.HasSuffix(a.Name.Local, "rsid") ||
+				(qualifiedName(a.Name) == "calcext:value-type" && a.Value == valueType)
+		})
+	})
+	// Properties left without any, as those of the styles LibreOffice
+	// creates to record rsids, set nothing.
+	root.walk(func(e *xmlNode) {
+		e.children = slices.DeleteFunc(e.children, func(c *xmlNode) bool {
+			return c.name.Space == "style" && strings.HasSuffix(c.name.Local, "-properties") &&
+				len(c.attrs) == 0 && len(c.children) == 0
+		})
+	})
+}
+
+// normalizedStyles renames all automatic styles, giving those equal to the
+// ones rechenbrett generates their names.
+func normalizedStyles() (styleNaming, error) {
+	pageStyles, _ := createPageStyles()
+	presets, err := xml.Marshal(automaticStyles{
+		NumberStyles: createNumberStyles(),
+		Styles:       createAutomaticStyles(nil),
+		PageLayout:   &pageStyles.PageLayout,
+	})
+	if err != nil {
+		return styleNaming{}, fmt.Errorf("encoding preset styles: %w", err)
+	}
+	root, err := parseXMLTree(bytes.NewReader(presets))
+	if err != nil {
+		return styleNaming{}, fmt.Errorf("reading preset styles: %w", err)
+	}
+	known := map[string]string{}
+	for _, n := range root.children {
+		known[n.styleContent(nil)] = n.attr("style:name")
+	}
+	return styleNaming{
+		selected: func(string) bool { return true },
+		prefix:   styleNamePrefix,
+		known:    known,
+	}, nil
+}
+
+// styleNamePrefix returns the start of the name of a style, by its kind:
+// CUSTOM_STYLE_ for cell styles, as for generated ones, DATA_STYLE_ for
+// number formats, PAGE_LAYOUT_ for page layouts, and the family followed by
+// _STYLE_ for other styles, as in TABLE_COLUMN_STYLE_.
+func styleNamePrefix(n *xmlNode) string {
+	switch {
+	case n.name.Space == "number":
+		return "DATA_STYLE_"
+	case qualifiedName(n.name) == "style:page-layout":
+		return "PAGE_LAYOUT_"
+	case qualifiedName(n.name) == "style:style":
+		family := n.attr("style:family")
+		if family == "table-cell" {
+			return customStylePrefix
+		}
+		if family != "" {
+			return strings.ToUpper(strings.ReplaceAll(family, "-", "_")) + "_STYLE_"
+		}
+	}
+	return "STYLE_"
+}
@@ -0,0 +1,194 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// savedByLibreOffice is a flat document as LibreOffice saves one written by
+// rechenbrett: with metadata and settings of its own, rsids, renamed styles,
+// and one bold style per cell.
+const savedByLibreOffice = `<?xml version="1.0" encoding="UTF-8"?>
+
+<office:document xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0" xmlns:style="urn:oasis:names:tc:opendocument:xmlns:style:1.0" xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0" xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0" xmlns:number="urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0" xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0" xmlns:meta="urn:oasis:names:tc:opendocument:xmlns:meta:1.0" xmlns:dc="http://purl.org/dc/elements/1.1/" xmlns:config="urn:oasis:names:tc:opendocument:xmlns:config:1.0" xmlns:draw="urn:oasis:names:tc:opendocument:xmlns:drawing:1.0" xmlns:svg="urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0" xmlns:officeooo="http://openoffice.org/2009/office" xmlns:calcext="urn:org:documentfoundation:names:experimental:calc:xmlns:calcext:1.0" office:version="1.3" office:mimetype="application/vnd.oasis.opendocument.spreadsheet">
+ <office:meta><meta:creation-date>2026-01-01T10:00:00</meta:creation-date><dc:date>2026-10-17T12:34:56.789000000</dc:date><meta:editing-duration>PT3M12S</meta:editing-duration><meta:editing-cycles>4</meta:editing-cycles><meta:generator>LibreOffice/24.2$Linux_X86_64</meta:generator><meta:document-statistic meta:table-count="1" meta:cell-count="3" meta:object-count="0"/></office:meta>
+ <office:settings>
+  <config:config-item-set config:name="ooo:view-settings">
+   <config:config-item config:name="VisibleAreaTop" config:type="int">0</config:config-item>
+  </config:config-item-set>
+ </office:settings>
+ <office:styles>
+  <style:default-style style:family="table-cell"/>
+  <style:style style:name="Default" style:family="table-cell"/>
+ </office:styles>
+ <office:automatic-styles>
+  <number:number-style style:name="N2P0" style:volatile="true">
+   <number:number number:decimal-places="2" number:min-decimal-places="2" number:min-integer-digits="1" number:grouping="true"/>
+  </number:number-style>
+  <number:number-style style:name="N2">
+   <style:text-properties fo:color="#ff0000"/>
+   <number:text>−</number:text>
+   <number:number number:decimal-places="2" number:min-decimal-places="2" number:min-integer-digits="1" number:grouping="true"/>
+   <style:map style:condition="value()&gt;=0" style:apply-style-name="N2P0"/>
+  </number:number-style>
+  <number:number-style style:name="N3">
+   <number:number number:decimal-places="0"/>
+  </number:number-style>
+  <style:style style:name="ta1" style:family="table" style:master-page-name="Default">
+   <style:table-properties table:display="true"/>
+  </style:style>
+  <style:style style:name="ce1" style:family="table-cell" style:parent-style-name="Default" style:data-style-name="N2"/>
+  <style:style style:name="ce2" style:family="table-cell" style:parent-style-name="Default">
+   <style:text-properties fo:font-weight="bold"/>
+  </style:style>
+  <style:style style:name="ce3" style:family="table-cell" style:parent-style-name="Default">
+   <style:text-properties fo:font-weight="bold"/>
+  </style:style>
+  <style:style style:name="T1" style:family="text">
+   <style:text-properties officeooo:rsid="00123abc"/>
+  </style:style>
+  <style:style style:name="gr1" style:family="graphic">
+   <style:graphic-properties draw:fill="solid" draw:fill-color="#ffffc0"/>
+  </style:style>
+  <style:style style:name="P1" style:family="paragraph">
+   <style:paragraph-properties fo:text-align="start"/>
+  </style:style>
+  <style:page-layout style:name="pm1">
+   <style:page-layout-properties fo:page-width="21.0cm" fo:page-height="29.7cm" style:print-orientation="portrait" fo:margin-top="2cm" fo:margin-bottom="2cm" fo:margin-left="2cm" fo:margin-right="2cm"/>
+  </style:page-layout>
+ </office:automatic-styles>
+ <office:master-styles>
+  <style:master-page style:name="Default" style:page-layout-name="pm1"/>
+ </office:master-styles>
+ <office:body>
+  <office:spreadsheet>
+   <table:table table:name="Sheet1" table:style-name="ta1">
+    <table:table-column table:number-columns-repeated="3"/>
+    <table:table-row>
+     <table:table-cell office:value-type="float" office:value="1.5" calcext:value-type="float" table:style-name="ce1">
+      <text:p>1.50</text:p>
+     </table:table-cell>
+     <table:table-cell table:style-name="ce2" office:value-type="string" calcext:value-type="string"><text:p><text:span text:style-name="T1">Total</text:span> due</text:p></table:table-cell>
+     <table:table-cell table:style-name="ce3" office:value-type="string" calcext:value-type="string">
+      <office:annotation draw:style-name="gr1" draw:text-style-name="P1" svg:width="3cm" svg:height="1cm" svg:x="5cm" svg:y="0cm">
+       <dc:date>2026-10-17T00:00:00</dc:date>
+       <text:p text:style-name="P1">Checked</text:p>
+      </office:annotation>
+      <text:p>  spaced  </text:p>
+     </table:table-cell>
+    </table:table-row>
+   </table:table>
+  </office:spreadsheet>
+ </office:body>
+</office:document>
+`
+
+func normalize(t *testing.T, document string) string {
+	t.Helper()
+
+	var b strings.Builder
+	if err := NormalizeFlatOds(strings.NewReader(document), &b); err != nil {
+		t.Fatalf("NormalizeFlatOds: %v", err)
+	}
+	return b.String()
+}
+
+func TestUnitNormalizeFlatOds(t *testing.T) {
+	actual := normalize(t, savedByLibreOffice)
+
+	meta := actual[strings.Index(actual, "<office:meta>"):strings.Index(actual, "</office:meta>")]
+	assert(t, !strings.Contains(meta, "dc:date"), "expected the date of saving to be stripped")
+	assert(t, strings.Contains(actual, "<dc:date>2026-10-17T00:00:00</dc:date>"), "expected the date of the comment to be kept")
+	for _, volatile := range []string{"office:settings", "meta:editing-duration", "meta:editing-cycles", "meta:generator", "meta:document-statistic", "rsid", "calcext:value-type"} {
+		assert(t, !strings.Contains(actual, volatile), fmt.Sprintf("expected %s to be stripped", volatile))
+	}
+	assert(t, strings.Contains(actual, "<meta:creation-date>2026-01-01T10:00:00</meta:creation-date>"), "expected the creation date to be kept")
+
+	for _, name := range []string{"FLOAT_DATA_STYLE_POSITIVE", "FLOAT_DATA_STYLE", "FLOAT_STYLE", "TABLE_STYLE", "PAGE_LAYOUT"} {
+		assert(t, strings.Contains(actual, fmt.Sprintf(`style:name="%s"`, name)), fmt.Sprintf("expected the style equal to %s to get its name", name))
+	}
+	assert(t, strings.Index(actual, `style:name="FLOAT_DATA_STYLE_POSITIVE"`) < strings.Index(actual, `style:name="FLOAT_DATA_STYLE"`), "expected the style a style:map applies to before the style:map")
+	assert(t, strings.Count(actual, "<number:number-style") == 2, "expected the unused number style to be dropped")
+	assert(t, strings.Contains(actual, `<style:master-page style:name="Default" style:page-layout-name="PAGE_LAYOUT"/>`), "expected references to renamed styles to follow them")
+
+	assert(t, strings.Count(actual, `style:name="CUSTOM_STYLE_`) == 1, "expected the two equal bold styles to be merged")
+	assert(t, strings.Count(actual, `table:style-name="CUSTOM_STYLE_`) == 2, "expected both bold cells to refer to the merged style")
+	assert(t, strings.Contains(actual, `<style:style style:family="text" style:name="TEXT_STYLE_`), "expected the text style to be named by its family")
+	assert(t, strings.Contains(actual, "\n          <table:table-cell office:value=\"1.5\" office:value-type=\"float\" table:style-name=\"FLOAT_STYLE\"><text:p>1.50</text:p></table:table-cell>\n"), "expected a re-indented cell on one line")
+	assert(t, strings.Contains(actual, "</text:span> due</text:p>") && strings.Contains(actual, "<text:p>  spaced  </text:p>"), "expected the whitespace of paragraphs to be kept")
+
+	// Cell comments refer to styles by attributes rechenbrett does not
+	// write.
+	for _, attribute := range []string{"draw:style-name", "draw:text-style-name"} {
+		match := regexp.MustCompile(attribute + `="([^"]+)"`).FindStringSubmatch(actual)
+		assert(t, match != nil && match[1] != "gr1" && match[1] != "P1", fmt.Sprintf("expected the %s of the comment to be renamed", attribute))
+		if match != nil {
+			assert(t, strings.Contains(actual, fmt.Sprintf(`style:name="%s"`, match[1])), fmt.Sprintf("expected the style %s of the comment to be kept", match[1]))
+		}
+	}
+	assert(t, strings.Contains(actual, `style:name="PARAGRAPH_STYLE_`) && strings.Contains(actual, `style:name="GRAPHIC_STYLE_`), "expected the styles of the comment to be named by their family")
+
+	assert(t, normalize(t, actual) == actual, "expected a normalized document to stay as it is")
+}
+
+func TestUnitNormalizeFlatOdsCanonical(t *testing.T) {
+	for name, spreadsheet := range roundTripSpreadsheets(t) {
+		t.Run(name, func(t *testing.T) {
+			canonical, err := MakeCanonicalFlatOds(spreadsheet)
+			if err != nil {
+				t.Fatalf("MakeCanonicalFlatOds: %v", err)
+			}
+			expected := strings.Replace(canonical, "  <office:meta>\n    <meta:generator>"+generator+"</meta:generator>\n  </office:meta>\n", "  <office:meta/>\n", 1)
+			actual := normalize(t, canonical)
+			assert(t, actual == expected, fmt.Sprintf("expected a canonical document to lose only its generator, changed lines: %q", changedLines(expected, actual)))
+
+			flat, err := MakeFlatOds(spreadsheet)
+			if err != nil {
+				t.Fatalf("MakeFlatOds: %v", err)
+			}
+			assert(t, normalize(t, flat) == expected, "expected a document of MakeFlatOds to normalize as its canonical form does")
+		})
+	}
+}
+
+func TestUnitNormalizeFlatOdsErrors(t *testing.T) {
+	var b strings.Builder
+	err := NormalizeFlatOds(strings.NewReader("<office:document><table:table>"), &b)
+	assert(t, err != nil && strings.Contains(err.Error(), "unexpected end of document in <table:table>"), fmt.Sprintf("expected an error for a truncated document, got %v", err))
+	err = NormalizeFlatOds(strings.NewReader("<office:document-content/>"), &b)
+	assert(t, err != nil && strings.Contains(err.Error(), "root element <office:document-content> is not <office:document>"), fmt.Sprintf("expected an error for a document that is not flat, got %v", err))
+}
+
+func TestNormalizeFlatOds(t *testing.T) {
+	cells := [][]Cell{
+		{MakeCell("-1234.56", "float"), MakeCell("100", "currency-eur"), MakeCell("0.125", "percentage"), MakeCell("2026-03-01", "date")},
+		{MakeStyledCell("Total", "string", CellStyle{Bold: true}), MakeCell("SUM(B1)", "formula")},
+	}
+	spreadsheet, err := MakeSpreadsheet(cells)
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	flat, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+
+	expectedThisCsv := map[string][][]string{
+		"en_US.UTF-8": {
+			{"−1,234.56", "100.00€", "12.50%", "2026-03-01"},
+			{"Total", "100", "", ""},
+		},
+		"de_DE.UTF-8": {
+			{"−1.234,56", "100.00€", "12,50%", "2026-03-01"},
+			{"Total", "100", "", ""},
+		},
+	}
+
+	renderFileAndCompare(t, "normalize", "fods", []byte(normalize(t, flat)), expectedThisCsv)
+}
@@ -5,11 +5,28 @@
 // Package ods builds OpenDocument spreadsheet documents, both as zipped
 // packages (.ods) and as flat XML documents (.fods).
 //
-// Cells are created with [MakeCell], [MakeRangeCell], or [MakeStyledCell],
+// Cells are created with [MakeCell], [MakeRangeCell], [MakeMatrixCell],
+// [MakeR1C1Cell], or [MakeStyledCell], or from Go values with [CellOf],
 // arranged in rows, and combined into a [Spreadsheet] with [MakeSpreadsheet]
 // or, for an Excel-style table with a header, banded rows, AutoFilter, and a
-// totals row, with [MakeTable]. The spreadsheet is then serialized with
-// [MakeOds], [WriteOds], or [MakeFlatOds].
+// totals row, with [MakeTable].
+// Workbooks of several sheets are combined with [MakeWorkbook], whose sheets
+// may define names for blocks of cells and formulas with [Sheet.WithNames].
+// The spreadsheet is then serialized with [MakeOds], [WriteOds],
+// [MakeFlatOds], or [WriteFlatOds], or, in a canonical form suited to
+// version control, [MakeCanonicalFlatOds] or [WriteCanonicalFlatOds], to
+// which [NormalizeFlatOds] brings documents saved by LibreOffice back. Sheets
+// too large to be held in memory are written row by row with a
+// [SheetWriter], slices of structs are turned into rows with [MarshalSheet],
+// CSV files with [ReadCSV] or [ImportCSV], and database results with
+// [ReadSQLRows] or, streaming, [WriteSQLRows].
+// Spreadsheets are also decoded from and encoded to a JSON document with
+// [encoding/json], see [Spreadsheet.UnmarshalJSON].
+//
+// Existing documents are parsed back into a [Spreadsheet] with [ReadOds] or
+// [ReadFlatOds], and their sheets decoded into slices of structs with
+// [UnmarshalSheet]. Which cells the formulas of a spreadsheet refer to is
+// recorded by a [DependencyGraph].
 package ods
 
 import (
@@ -24,6 +41,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 )
 
 // generator identifies this library in the document metadata (meta:generator).
@@ -45,14 +63,24 @@ var (
 	usDateFormat     = regexp.MustCompile(`^(\d{1,2})/(\d{1,2})/(\d{4})$`)
 	isoDateFormat    = regexp.MustCompile(`^(\d{4})-(\d{1,2})-(\d{1,2})$`)
 	timeFormat       = regexp.MustCompile(`^(\d{1,2}):(\d{2})(?::(\d{2}))?$`)
+	durationFormat   = regexp.MustCompile(`^(\d+):([0-5]\d)(?::([0-5]\d))?$`)
 )
 
 // MakeCell creates a cell holding value interpreted as valueType.
 //
 // Supported value types are "string", "float", "date" (ISO, German, or US
 // format), "time" (HH:MM or HH:MM:SS), "percentage" (fraction, e.g. "0.42"
-// for 42 %), "formula", and "currency" with the variants "currency-eur",
-// "currency-usd", and "currency-gbp" (bare "currency" means EUR).
+// for 42 %), "formula", "currency" with the variants "currency-eur",
+// "currency-usd", and "currency-gbp" (bare "currency" means EUR), "boolean"
+// ("true" or "false", in any case), "datetime" (ISO 8601 date and time, such
+// as "2026-03-01T14:30:00", optionally with a zone like "Z" or "+01:00", in
+// which case it is converted to UTC), and "duration" (H:MM or H:MM:SS with
+// any number of hours, e.g. "36:15:00", shown without wrapping at 24 hours).
+//
+// Formulas are written in A1 notation, and may use Excel's structured
+// references to the tables of [MakeTable] and [MakeTableSheet]
+// (Products[Unit Price], Products[@Qty], [#Totals]), which are rewritten to
+// the cells they denote once the spreadsheet is built.
 //
 // Invalid values or value types are reported by [MakeSpreadsheet].
 func MakeCell(value, valueType string) Cell {
@@ -122,77 +150,18 @@ const (
 // single sheet named "Sheet1".
 //
 // It reports all invalid cells (bad value types, unparseable dates, times, or
-// numbers) and duplicate range names as a single joined error.
+// numbers, formulas with syntax errors), duplicate range names, matrix
+// formulas overlapping other content, formula references that do not resolve
+// (undefined range names, missing sheets, addresses beyond the last row or
+// column), and circular references as a single joined error.
 func MakeSpreadsheet(cells [][]Cell) (Spreadsheet, error) {
 	return MakeSpreadsheetWithName(defaultTableName, cells)
 }
 
 // MakeSpreadsheetWithName is like [MakeSpreadsheet] with a custom sheet name.
+// Unlike [MakeWorkbook], it takes the name as it is given.
 func MakeSpreadsheetWithName(name string, cells [][]Cell) (Spreadsheet, error) {
-	var rows []row
-	var errs []error
-
-	rangeAddresses := map[string]string{}
-	rangeNames := []string{}
-
-	customStyleNames := map[customStyleKey]string{}
-	var customStyles []cellStyle
-
-	maxCols := 1
-	for rowIdx, c := range cells {
-		rows = append(rows, row{Cells: c})
-		maxCols = max(maxCols, len(c))
-		for colIdx, cc := range c {
-			if cc.err != nil {
-				errs = append(errs, fmt.Errorf("row %d, column %d: %w", rowIdx+1, colIdx+1, cc.err))
-			}
-			if cc.rangeName != "" {
-				if _, exists := rangeAddresses[cc.rangeName]; exists {
-					errs = append(errs, fmt.Errorf("row %d, column %d: duplicate range name %q", rowIdx+1, colIdx+1, cc.rangeName))
-				} else {
-					rangeAddresses[cc.rangeName] = fmt.Sprintf("$%s.%s", name, toA1(rowIdx+1, colIdx+1))
-					rangeNames = append(rangeNames, cc.rangeName)
-				}
-			}
-			if cc.style != nil {
-				key := customStyleKey{CellStyle: *cc.style, dataStyleName: dataStyleNameFor(cc.StyleName)}
-				styleName, exists := customStyleNames[key]
-				if !exists {
-					styleName = fmt.Sprintf("CUSTOM_STYLE_%d", len(customStyleNames)+1)
-					customStyleNames[key] = styleName
-					customStyles = append(customStyles, buildCustomCellStyle(styleName, key.dataStyleName, *cc.style))
-				}
-				c[colIdx].StyleName = styleName
-			}
-		}
-	}
-	if len(errs) > 0 {
-		return Spreadsheet{}, errors.Join(errs...)
-	}
-
-	namedRanges := []namedRange{}
-	for _, rangeName := range rangeNames {
-		namedRanges = append(namedRanges, namedRange{
-			Name:             rangeName,
-			BaseCellAddress:  rangeAddresses[rangeName],
-			CellRangeAddress: rangeAddresses[rangeName],
-		})
-	}
-
-	return Spreadsheet{
-		Tables: []table{
-			{
-				Name:      name,
-				StyleName: tableStyleName,
-				// The ODF schema requires at least one table:table-column
-				// before the table rows.
-				Columns: []tableColumn{{NumberColumnsRepeated: strconv.Itoa(maxCols)}},
-				Rows:    rows,
-			},
-		},
-		NamedExpressions: namedExpressions{NamedRanges: namedRanges},
-		customStyles:     customStyles,
-	}, nil
+	return makeSingleSheet(MakeSheet(name, cells))
 }
 
 // EnableAutoFilter turns on AutoFilter dropdown buttons over the used cell
@@ -318,7 +287,8 @@ type TableOptions struct {
 	// (sanitized) header cell and spanning that column's body rows, so
 	// formulas can refer to columns by name (e.g. SUBTOTAL(9;unit_price)).
 	// Requires Header. When set, totals cells reference these column names
-	// instead of raw cell addresses.
+	// instead of raw cell addresses. Excel's structured references, such as
+	// Products[Unit Price], are translated whether or not this is set.
 	StructuredRefs bool
 	// Style selects the color theme. Defaults to TableStyleBlue.
 	Style TableStyle
@@ -334,18 +304,41 @@ type TableOptions struct {
 // the column's body rows, named after the header), so callers can reference
 // columns by name.
 //
-// It reports invalid cells (bad value types, unparseable dates, times, or
-// numbers) and duplicate range names the same way [MakeSpreadsheet] does. The
+// It reports invalid cells, duplicate range names, unresolved formula
+// references, and circular references the same way [MakeSpreadsheet] does. The
 // caller's cells are not modified.
 func MakeTable(cells [][]Cell, opts TableOptions) (Spreadsheet, error) {
+	return makeSingleSheet(MakeTableSheet(defaultTableName, cells, opts))
+}
+
+// tableLayout is a block of cells formatted as a table by formatTable,
+// together with the positions the table's named ranges and database range
+// refer to.
+type tableLayout struct {
+	cells [][]Cell
+	// columnNames holds the generated named-range name for each column when
+	// StructuredRefs is set; it is nil otherwise.
+	columnNames []string
+	maxCols     int
+	// firstDataRow/lastDataRow are the 1-based sheet rows spanned by the body,
+	// used for the column named ranges, the totals SUBTOTAL ranges, and the
+	// AutoFilter range.
+	firstDataRow int
+	lastDataRow  int
+}
+
+func (l tableLayout) hasBody() bool {
+	return l.lastDataRow >= l.firstDataRow
+}
+
+// formatTable applies opts to a copy of cells: it styles the header and body
+// rows and appends the totals row. Generated column names avoid the range
+// names in used, which is updated with them.
+func formatTable(cells [][]Cell, opts TableOptions, used map[string]bool) (tableLayout, error) {
 	if opts.StructuredRefs && !opts.Header {
-		return Spreadsheet{}, errors.New("MakeTable: StructuredRefs requires Header to name the columns")
+		return tableLayout{}, errors.New("table options: StructuredRefs requires Header to name the columns")
 	}
 
-	name := opts.Name
-	if name == "" {
-		name = "Table1"
-	}
 	theme := themeFor(opts.Style)
 
 	// Work on a copy so styling never mutates the caller's cells.
@@ -382,18 +375,14 @@ func MakeTable(cells [][]Cell, opts TableOptions) (Spreadsheet, error) {
 		}
 	}
 
-	// firstDataRow/lastDataRow are the 1-based sheet rows spanned by the body,
-	// used for the column named ranges, the totals SUBTOTAL ranges, and the
-	// AutoFilter range.
-	firstDataRow := bodyStart + 1
-	lastDataRow := len(styled)
-	hasBody := lastDataRow >= firstDataRow
+	layout := tableLayout{
+		maxCols:      maxCols,
+		firstDataRow: bodyStart + 1,
+		lastDataRow:  len(styled),
+	}
 
-	// columnNames holds the generated named-range name for each column when
-	// StructuredRefs is set; it is nil otherwise.
-	var columnNames []string
 	if opts.StructuredRefs && len(styled) > 0 {
-		columnNames = generateColumnNames(styled, maxCols)
+		layout.columnNames = generateColumnNames(styled, maxCols, used)
 	}
 
 	if len(opts.Totals) > 0 {
@@ -402,13 +391,13 @@ func MakeTable(cells [][]Cell, opts TableOptions) (Spreadsheet, error) {
 		for j := range maxCols {
 			cell := createCell(cellData{ValueType: "string"})
 			if j < len(opts.Totals) {
-				if code, ok := opts.Totals[j].Func.subtotalCode(); ok && hasBody {
+				if code, ok := opts.Totals[j].Func.subtotalCode(); ok && layout.hasBody() {
 					ref := ""
-					if columnNames != nil {
-						ref = columnNames[j]
+					if layout.columnNames != nil {
+						ref = layout.columnNames[j]
 					} else {
 						col := columnToLetters(j + 1)
-						ref = fmt.Sprintf("%s%d:%s%d", col, firstDataRow, col, lastDataRow)
+						ref = fmt.Sprintf("%s%d:%s%d", col, layout.firstDataRow, col, layout.lastDataRow)
 					}
 					cell = createCell(cellData{ValueType: "formula", Value: fmt.Sprintf("SUBTOTAL(%d;%s)", code, ref)})
 				}
@@ -419,34 +408,8 @@ func MakeTable(cells [][]Cell, opts TableOptions) (Spreadsheet, error) {
 		styled = append(styled, totalsRow)
 	}
 
-	spreadsheet, err := MakeSpreadsheetWithName(defaultTableName, styled)
-	if err != nil {
-		return Spreadsheet{}, err
-	}
-
-	if columnNames != nil && hasBody {
-		for j := range maxCols {
-			col := columnToLetters(j + 1)
-			base := fmt.Sprintf("$%s.$%s$%d", defaultTableName, col, firstDataRow)
-			spreadsheet.NamedExpressions.NamedRanges = append(spreadsheet.NamedExpressions.NamedRanges, namedRange{
-				Name:             columnNames[j],
-				BaseCellAddress:  base,
-				CellRangeAddress: fmt.Sprintf("%s:.$%s$%d", base, col, lastDataRow),
-			})
-		}
-	}
-
-	if opts.AutoFilter && lastDataRow > 0 {
-		// The filter range covers the header and body but not the totals row,
-		// so filtering and sorting never move the aggregates.
-		spreadsheet.DatabaseRanges = &databaseRanges{Ranges: []databaseRange{{
-			Name:                 name,
-			TargetRangeAddress:   usedRangeAddress(defaultTableName, lastDataRow, maxCols),
-			DisplayFilterButtons: "true",
-		}}}
-	}
-
-	return spreadsheet, nil
+	layout.cells = styled
+	return layout, nil
 }
 
 // structuredRefCellRef matches names that look like a cell reference (e.g.
@@ -454,18 +417,9 @@ func MakeTable(cells [][]Cell, opts TableOptions) (Spreadsheet, error) {
 var structuredRefCellRef = regexp.MustCompile(`^[A-Za-z]{1,3}[0-9]+$`)
 
 // generateColumnNames derives a unique, valid named-range name for each of the
-// maxCols columns from the header row (styled[0]), avoiding collisions with any
-// user-defined range names already present on the cells.
-func generateColumnNames(styled [][]Cell, maxCols int) []string {
-	used := map[string]bool{}
-	for _, r := range styled {
-		for _, c := range r {
-			if c.rangeName != "" {
-				used[c.rangeName] = true
-			}
-		}
-	}
-
+// maxCols columns from the header row (styled[0]), avoiding collisions with the
+// range names in used, which is updated with the generated ones.
+func generateColumnNames(styled [][]Cell, maxCols int, used map[string]bool) []string {
 	names := make([]string, maxCols)
 	for j := range maxCols {
 		header := ""
@@ -486,7 +440,7 @@ func generateColumnNames(styled [][]Cell, maxCols int) []string {
 // sanitizeRangeName turns a column header into a valid ODF named-range name:
 // non-alphanumeric runs become underscores, an empty name falls back to
 // "Column<n>", a leading digit is prefixed, and names that look like a cell
-// reference get a trailing underscore.
+// reference, in A1 or R1C1 notation, get a trailing underscore.
 func sanitizeRangeName(header string, colIndex int) string {
 	var b strings.Builder
 	for _, r := range header {
@@ -504,7 +458,7 @@ func sanitizeRangeName(header string, colIndex int) string {
 	if name[0] >= '0' && name[0] <= '9' {
 		name = "_" + name
 	}
-	if structuredRefCellRef.MatchString(name) {
+	if structuredRefCellRef.MatchString(name) || r1c1NameRef.MatchString(name) {
 		name += "_"
 	}
 	return name
@@ -515,9 +469,22 @@ func sanitizeRangeName(header string, colIndex int) string {
 // "Sheet1.A1:Sheet1.B3". Unlike named ranges, database ranges use unanchored
 // (no dollar sign) cell references.
 func usedRangeAddress(sheet string, rowCount, colCount int) string {
+	sheet = quoteSheetName(sheet)
 	return fmt.Sprintf("%s.A1:%s.%s%d", sheet, sheet, columnToLetters(colCount), rowCount)
 }
 
+// quoteSheetName returns sheet as it is written in a cell address: names
+// made of letters, digits, and underscores as they are, any other enclosed in
+// single quotes, with quotes in the name doubled.
+func quoteSheetName(sheet string) string {
+	for _, r := range sheet {
+		if r != '_' && !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			return "'" + strings.ReplaceAll(sheet, "'", "''") + "'"
+		}
+	}
+	return sheet
+}
+
 // customStyleKey identifies a distinct generated cell style, so cells
 // sharing the same CellStyle and base data style reuse one style definition.
 type customStyleKey struct {
@@ -528,7 +495,7 @@ type customStyleKey struct {
 // dataStyleNameFor maps a preset style name assigned by createCell (e.g.
 // FLOAT_STYLE) to the number-format style it references, so custom styles
 // keep the same numeric formatting. Value types without a preset style
-// (string, time, percentage, formula) return "".
+// (string, formula) return "".
 func dataStyleNameFor(presetStyleName string) string {
 	switch presetStyleName {
 	case "FLOAT_STYLE":
@@ -537,6 +504,12 @@ func dataStyleNameFor(presetStyleName string) string {
 		return "DATE_DATA_STYLE"
 	case "TIME_STYLE":
 		return "TIME_DATA_STYLE"
+	case "DATETIME_STYLE":
+		return "DATETIME_DATA_STYLE"
+	case "DURATION_STYLE":
+		return "DURATION_DATA_STYLE"
+	case "BOOLEAN_STYLE":
+		return "BOOLEAN_DATA_STYLE"
 	case "PERCENTAGE_STYLE":
 		return "PERCENTAGE_DATA_STYLE"
 	case "EUR_STYLE":
@@ -597,8 +570,19 @@ func toA1(row, col int) string {
 }
 
 // MakeFlatOds serializes the spreadsheet as a flat OpenDocument XML document
-// (.fods).
+// (.fods). Implemented as [WriteFlatOds] into a string.
 func MakeFlatOds(spreadsheet Spreadsheet) (string, error) {
+	var b strings.Builder
+	if err := WriteFlatOds(&b, spreadsheet); err != nil {
+		return "", err
+	}
+	return b.String(), nil
+}
+
+// WriteFlatOds writes the spreadsheet as a flat OpenDocument XML document
+// (.fods) to w. The document is encoded straight to w as it is produced,
+// without being held in memory as a whole.
+func WriteFlatOds(w io.Writer, spreadsheet Spreadsheet) error {
 	pageStyles, master := createPageStyles()
 	fods := flatOds{
 		XMLNSOffice:    "urn:oasis:names:tc:opendocument:xmlns:office:1.0",
@@ -625,11 +609,17 @@ func MakeFlatOds(spreadsheet Spreadsheet) (string, error) {
 		},
 	}
 
-	out, err := xml.MarshalIndent(fods, " ", "  ")
-	if err != nil {
-		return "", fmt.Errorf("marshaling flat ods document: %w", err)
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return fmt.Errorf("writing flat ods document: %w", err)
+	}
+	// The encoder buffers only a few kilobytes before writing them to w, and
+	// produces the same bytes as xml.MarshalIndent with the same indentation.
+	encoder := xml.NewEncoder(w)
+	encoder.Indent(" ", "  ")
+	if err := encoder.Encode(fods); err != nil {
+		return fmt.Errorf("encoding flat ods document: %w", err)
 	}
-	return xmlWithHeader(out), nil
+	return nil
 }
 
 // MakeOds serializes the spreadsheet as a zipped OpenDocument package (.ods).
@@ -644,7 +634,54 @@ func MakeOds(spreadsheet Spreadsheet) (*bytes.Buffer, error) {
 // WriteOds writes the spreadsheet as a zipped OpenDocument package (.ods)
 // to w.
 func WriteOds(w io.Writer, spreadsheet Spreadsheet) error {
-	manifestXml := manifest{
+	contentXml := documentContent{
+		XMLNSOffice:   "urn:oasis:names:tc:opendocument:xmlns:office:1.0",
+		XMLNSTable:    "urn:oasis:names:tc:opendocument:xmlns:table:1.0",
+		XMLNSText:     "urn:oasis:names:tc:opendocument:xmlns:text:1.0",
+		XMLNSStyle:    "urn:oasis:names:tc:opendocument:xmlns:style:1.0",
+		XMLNSFo:       "urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0",
+		XMLNSNumber:   "urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0",
+		XMLNSOf:       "urn:oasis:names:tc:opendocument:xmlns:of:1.2",
+		OfficeVersion: odfVersion,
+		AutomaticStyles: automaticStyles{
+			NumberStyles: createNumberStyles(),
+			Styles:       createAutomaticStyles(spreadsheet.customStyles),
+		},
+		Body: documentBody{
+			Spreadsheet: spreadsheet,
+		},
+	}
+
+	zipWriter := zip.NewWriter(w)
+	if err := createMimetypeEntry(zipWriter); err != nil {
+		return err
+	}
+
+	parts := []struct {
+		name    string
+		content any
+	}{
+		{"META-INF/manifest.xml", createManifest()},
+		{"content.xml", contentXml},
+		{"styles.xml", createDocumentStyles(createCommonStyles())},
+		{"meta.xml", createDocumentMeta()},
+	}
+	for _, part := range parts {
+		if err := writePartEntry(zipWriter, part.name, part.content); err != nil {
+			return err
+		}
+	}
+
+	if err := zipWriter.Close(); err != nil {
+		return fmt.Errorf("closing zip archive: %w", err)
+	}
+
+	return nil
+}
+
+// createManifest returns the META-INF/manifest.xml of a package.
+func createManifest() manifest {
+	return manifest{
 		Version: odfVersion,
 		XMLNS:   "urn:oasis:names:tc:opendocument:xmlns:manifest:1.0",
 		Entries: []fileEntry{
@@ -667,27 +704,13 @@ func WriteOds(w io.Writer, spreadsheet Spreadsheet) error {
 			},
 		},
 	}
+}
 
-	contentXml := documentContent{
-		XMLNSOffice:   "urn:oasis:names:tc:opendocument:xmlns:office:1.0",
-		XMLNSTable:    "urn:oasis:names:tc:opendocument:xmlns:table:1.0",
-		XMLNSText:     "urn:oasis:names:tc:opendocument:xmlns:text:1.0",
-		XMLNSStyle:    "urn:oasis:names:tc:opendocument:xmlns:style:1.0",
-		XMLNSFo:       "urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0",
-		XMLNSNumber:   "urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0",
-		XMLNSOf:       "urn:oasis:names:tc:opendocument:xmlns:of:1.2",
-		OfficeVersion: odfVersion,
-		AutomaticStyles: automaticStyles{
-			NumberStyles: createNumberStyles(),
-			Styles:       createAutomaticStyles(spreadsheet.customStyles),
-		},
-		Body: documentBody{
-			Spreadsheet: spreadsheet,
-		},
-	}
-
+// createDocumentStyles returns the styles.xml of a package holding the given
+// common styles and the page setup.
+func createDocumentStyles(styles officeStyles) documentStyles {
 	pageStyles, master := createPageStyles()
-	stylesXml := documentStyles{
+	return documentStyles{
 		XMLNSOffice:     "urn:oasis:names:tc:opendocument:xmlns:office:1.0",
 		XMLNSTable:      "urn:oasis:names:tc:opendocument:xmlns:table:1.0",
 		XMLNSText:       "urn:oasis:names:tc:opendocument:xmlns:text:1.0",
@@ -696,20 +719,25 @@ func WriteOds(w io.Writer, spreadsheet Spreadsheet) error {
 		XMLNSNumber:     "urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0",
 		XMLNSSvg:        "urn:oasis:names:tc:opendocument:xmlns:svg-compatible:1.0",
 		OfficeVersion:   odfVersion,
-		Styles:          createCommonStyles(),
+		Styles:          styles,
 		AutomaticStyles: pageStyles,
 		MasterStyles:    master,
 	}
+}
 
-	metaXml := documentMeta{
+// createDocumentMeta returns the meta.xml of a package.
+func createDocumentMeta() documentMeta {
+	return documentMeta{
 		XMLNSOffice:   "urn:oasis:names:tc:opendocument:xmlns:office:1.0",
 		XMLNSMeta:     "urn:oasis:names:tc:opendocument:xmlns:meta:1.0",
 		OfficeVersion: odfVersion,
 		Meta:          officeMeta{Generator: generator},
 	}
+}
 
-	zipWriter := zip.NewWriter(w)
-
+// createMimetypeEntry writes the mimetype entry, which has to be the first
+// entry of a package.
+func createMimetypeEntry(zipWriter *zip.Writer) error {
 	// The mimetype entry must be stored uncompressed with its size and CRC in
 	// the local file header (no data descriptor), or LibreOffice >= 26.2
 	// refuses to load the file. CreateRaw writes the header as given, unlike
@@ -734,43 +762,40 @@ func WriteOds(w io.Writer, spreadsheet Spreadsheet) error {
 	if _, err := writer.Write(mimetype); err != nil {
 		return fmt.Errorf("writing mimetype zip entry: %w", err)
 	}
+	return nil
+}
 
-	parts := []struct {
-		name    string
-		content any
-	}{
-		{"META-INF/manifest.xml", manifestXml},
-		{"content.xml", contentXml},
-		{"styles.xml", stylesXml},
-		{"meta.xml", metaXml},
-	}
-	for _, part := range parts {
-		marshaled, err := xml.MarshalIndent(part.content, "", "  ")
-		if err != nil {
-			return fmt.Errorf("marshaling %s: %w", part.name, err)
-		}
-		writer, err := zipWriter.CreateHeader(&zip.FileHeader{
-			Name:   part.name,
-			Method: zip.Deflate,
-			// Without an explicit timestamp the entries carry a zero time,
-			// which the MS-DOS date fields of a zip archive cannot express;
-			// they end up dated 1979-12-31, and strict readers reject that.
-			// The timestamp is fixed rather than time.Now() to keep the
-			// output byte-for-byte reproducible.
-			Modified: zipEntryTime,
-		})
-		if err != nil {
-			return fmt.Errorf("creating zip entry %s: %w", part.name, err)
-		}
-		if _, err := io.WriteString(writer, xmlWithHeader(marshaled)); err != nil {
-			return fmt.Errorf("writing zip entry %s: %w", part.name, err)
-		}
+// createPartEntry starts a compressed entry of a package.
+func createPartEntry(zipWriter *zip.Writer, name string) (io.Writer, error) {
+	writer, err := zipWriter.CreateHeader(&zip.FileHeader{
+		Name:   name,
+		Method: zip.Deflate,
+		// Without an explicit timestamp the entries carry a zero time,
+		// which the MS-DOS date fields of a zip archive cannot express;
+		// they end up dated 1979-12-31, and strict readers reject that.
+		// The timestamp is fixed rather than time.Now() to keep the
+		// output byte-for-byte reproducible.
+		Modified: zipEntryTime,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("creating zip entry %s: %w", name, err)
 	}
+	return writer, nil
+}
 
-	if err := zipWriter.Close(); err != nil {
-		return fmt.Errorf("closing zip archive: %w", err)
+// writePartEntry writes content as an XML entry of a package.
+func writePartEntry(zipWriter *zip.Writer, name string, content any) error {
+	marshaled, err := xml.MarshalIndent(content, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling %s: %w", name, err)
+	}
+	writer, err := createPartEntry(zipWriter, name)
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(writer, xmlWithHeader(marshaled)); err != nil {
+		return fmt.Errorf("writing zip entry %s: %w", name, err)
 	}
-
 	return nil
 }
 
@@ -792,6 +817,66 @@ func timeString(input string) (string, error) {
 	return fmt.Sprintf("PT%sH%sM%sS", matches[1], matches[2], seconds), nil
 }
 
+// datetimeLayouts are the ISO 8601 forms accepted for "datetime" cells, with
+// or without seconds, zone, and the "T" between date and time.
+var datetimeLayouts = []string{
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05Z07:00",
+	"2006-01-02 15:04Z07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+}
+
+// datetimeString converts an ISO 8601 date and time to the form used by
+// office:date-value, which carries no zone: times with a zone are converted
+// to UTC, those without are kept as they are.
+func datetimeString(input string) (string, error) {
+	for _, layout := range datetimeLayouts {
+		t, err := time.Parse(layout, input)
+		if err != nil {
+			continue
+		}
+		if strings.HasSuffix(layout, "Z07:00") {
+			t = t.UTC()
+		}
+		return t.Format("2006-01-02T15:04:05.999999999"), nil
+	}
+	return "", fmt.Errorf("invalid datetime %q, expected YYYY-MM-DDTHH:MM:SS, optionally with a zone", input)
+}
+
+// durationString converts an elapsed time of H:MM or H:MM:SS, with any
+// number of hours, to the form used by office:time-value.
+func durationString(input string) (string, error) {
+	matches := durationFormat.FindStringSubmatch(input)
+	if matches == nil {
+		return "", fmt.Errorf("invalid duration %q, expected H:MM or H:MM:SS", input)
+	}
+	hours, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return "", fmt.Errorf("invalid duration %q: %w", input, err)
+	}
+	seconds := matches[3]
+	if seconds == "" {
+		seconds = "00"
+	}
+	return fmt.Sprintf("PT%02dH%sM%sS", hours, matches[2], seconds), nil
+}
+
+// booleanString converts "true" or "false", in any case, to the form used by
+// office:boolean-value.
+func booleanString(input string) (string, error) {
+	switch strings.ToLower(input) {
+	case "true":
+		return "true", nil
+	case "false":
+		return "false", nil
+	}
+	return "", fmt.Errorf("invalid boolean %q, expected true or false", input)
+}
+
 // dateString converts German (DD.MM.YYYY), US (MM/DD/YYYY), or ISO
 // (YYYY-MM-DD) dates to the ISO format used by office:date-value.
 func dateString(date string) (string, error) {
@@ -837,12 +922,28 @@ func createCell(data cellData) Cell {
 	case "time":
 		cell.StyleName = "TIME_STYLE"
 		cell.TimeValue, cell.err = timeString(data.Value)
+	case "datetime":
+		// office:value-type knows dates only, which may carry a time.
+		cell.ValueType = "date"
+		cell.StyleName = "DATETIME_STYLE"
+		cell.DateValue, cell.err = datetimeString(data.Value)
+	case "duration":
+		cell.ValueType = "time"
+		cell.StyleName = "DURATION_STYLE"
+		cell.TimeValue, cell.err = durationString(data.Value)
+	case "boolean":
+		cell.StyleName = "BOOLEAN_STYLE"
+		cell.BooleanValue, cell.err = booleanString(data.Value)
 	case "percentage":
 		cell.StyleName = "PERCENTAGE_STYLE"
 		cell.err = parseNumber(data.Value, data.ValueType)
 		cell.Value = data.Value
 	case "formula":
-		cell.Formula = toOpenFormula(data.Value)
+		cell.Formula, cell.err = toOpenFormula(data.Value)
+		cell.verbatim = strings.Contains(data.Value, ":=")
+		if errors.Is(cell.err, errStructuredReference) {
+			cell.Formula, cell.err, cell.structuredSource = "", nil, data.Value
+		}
 		cell.ValueType = ""
 	case "currency", "currency-eur", "currency-usd", "currency-gbp":
 		// office:value-type only allows "currency"; the concrete currency is
@@ -924,6 +1025,36 @@ func createNumberStyles() []any {
 				timeSeconds{Style: "long"},
 			},
 		},
+		dateStyle{
+			Name: "DATETIME_DATA_STYLE",
+			Parts: []any{
+				dateYear{Style: "long"},
+				textElement{Content: "-"},
+				dateMonth{Style: "long"},
+				textElement{Content: "-"},
+				dateDay{Style: "long"},
+				textElement{Content: " "},
+				timeHours{Style: "long"},
+				textElement{Content: ":"},
+				timeMinutes{Style: "long"},
+				textElement{Content: ":"},
+				timeSeconds{Style: "long"},
+			},
+		},
+		// Durations count the hours on past 24 rather than wrapping around
+		// to the time of day, as [HH]:MM:SS does in Excel.
+		timeStyle{
+			Name:               "DURATION_DATA_STYLE",
+			TruncateOnOverflow: "false",
+			Parts: []any{
+				timeHours{Style: "long"},
+				textElement{Content: ":"},
+				timeMinutes{Style: "long"},
+				textElement{Content: ":"},
+				timeSeconds{Style: "long"},
+			},
+		},
+		booleanStyle{Name: "BOOLEAN_DATA_STYLE"},
 		percentageStyle{
 			Name: "PERCENTAGE_DATA_STYLE",
 			Number: numberElement{
@@ -1028,12 +1159,18 @@ func createAutomaticStyles(customStyles []cellStyle) []any {
 	for _, style := range append(createStyles(), customStyles...) {
 		styles = append(styles, style)
 	}
-	return append(styles, tableStyle{
+	return append(styles, createTableStyle())
+}
+
+// createTableStyle returns the automatic style binding every sheet to the
+// master page.
+func createTableStyle() tableStyle {
+	return tableStyle{
 		Name:           tableStyleName,
 		Family:         "table",
 		MasterPageName: masterPageName,
 		Properties:     tableProperties{Display: "true"},
-	})
+	}
 }
 
 func createStyles() []cellStyle {
@@ -1041,6 +1178,9 @@ func createStyles() []cellStyle {
 		{Name: "FLOAT_STYLE", Family: "table-cell", ParentStyleName: "Default", DataStyleName: "FLOAT_DATA_STYLE"},
 		{Name: "DATE_STYLE", Family: "table-cell", ParentStyleName: "Default", DataStyleName: "DATE_DATA_STYLE"},
 		{Name: "TIME_STYLE", Family: "table-cell", ParentStyleName: "Default", DataStyleName: "TIME_DATA_STYLE"},
+		{Name: "DATETIME_STYLE", Family: "table-cell", ParentStyleName: "Default", DataStyleName: "DATETIME_DATA_STYLE"},
+		{Name: "DURATION_STYLE", Family: "table-cell", ParentStyleName: "Default", DataStyleName: "DURATION_DATA_STYLE"},
+		{Name: "BOOLEAN_STYLE", Family: "table-cell", ParentStyleName: "Default", DataStyleName: "BOOLEAN_DATA_STYLE"},
 		{Name: "PERCENTAGE_STYLE", Family: "table-cell", ParentStyleName: "Default", DataStyleName: "PERCENTAGE_DATA_STYLE"},
 		{Name: "EUR_STYLE", Family: "table-cell", ParentStyleName: "Default", DataStyleName: "EUR_DATA_STYLE"},
 		{Name: "USD_STYLE", Family: "table-cell", ParentStyleName: "Default", DataStyleName: "USD_DATA_STYLE"},
@@ -1048,22 +1188,40 @@ func createStyles() []cellStyle {
 	}
 }
 
-// Cell is one spreadsheet cell. Create cells with [MakeCell] or
-// [MakeRangeCell].
+// Cell is one spreadsheet cell. Create cells with [MakeCell],
+// [MakeRangeCell], [MakeMatrixCell], [MakeR1C1Cell], or from a Go value with
+// [CellOf].
 type Cell struct {
-	XMLName   xml.Name `xml:"table:table-cell"`
-	Text      string   `xml:"text:p,omitempty"`
-	ValueType string   `xml:"office:value-type,attr,omitempty"`
-	Value     string   `xml:"office:value,attr,omitempty"`
-	DateValue string   `xml:"office:date-value,attr,omitempty"`
-	TimeValue string   `xml:"office:time-value,attr,omitempty"`
-	Currency  string   `xml:"office:currency,attr,omitempty"`
-	StyleName string   `xml:"table:style-name,attr,omitempty"`
-	Formula   string   `xml:"table:formula,attr,omitempty"`
+	XMLName      xml.Name `xml:"table:table-cell"`
+	Text         string   `xml:"text:p,omitempty"`
+	ValueType    string   `xml:"office:value-type,attr,omitempty"`
+	Value        string   `xml:"office:value,attr,omitempty"`
+	DateValue    string   `xml:"office:date-value,attr,omitempty"`
+	TimeValue    string   `xml:"office:time-value,attr,omitempty"`
+	BooleanValue string   `xml:"office:boolean-value,attr,omitempty"`
+	Currency     string   `xml:"office:currency,attr,omitempty"`
+	StyleName    string   `xml:"table:style-name,attr,omitempty"`
+	Formula      string   `xml:"table:formula,attr,omitempty"`
+	// The extent of the block a matrix formula fills, set on the cell
+	// holding it only; see [MakeMatrixCell].
+	NumberMatrixColumnsSpanned string `xml:"table:number-matrix-columns-spanned,attr,omitempty"`
+	NumberMatrixRowsSpanned    string `xml:"table:number-matrix-rows-spanned,attr,omitempty"`
 
 	rangeName string
 	style     *CellStyle
 	err       error
+	// structuredSource is the formula of a cell holding structured
+	// references, which the builder translates once the tables are known.
+	structuredSource string
+	// r1c1Source is the formula of a cell made by [MakeR1C1Cell], which the
+	// builder translates once the cell's position is known.
+	r1c1Source string
+	// covered is set on the cells of the block of a matrix formula other
+	// than the one holding it.
+	covered bool
+	// verbatim is set on cells whose formula was given with a namespace
+	// prefix and passed through unchecked.
+	verbatim bool
 }
 
 // Spreadsheet is a collection of tables ready for serialization. Create
@@ -1109,6 +1267,9 @@ type table struct {
 	StyleName string        `xml:"table:style-name,attr,omitempty"`
 	Columns   []tableColumn `xml:"table:table-column"`
 	Rows      []row         `xml:"table:table-row"`
+	// NamedExpressions holds the names scoped to the sheet, see
+	// [DefinedName.SheetScope].
+	NamedExpressions *namedExpressions `xml:"table:named-expressions,omitempty"`
 }
 
 // Field order matters throughout the document types: the ODF schema
@@ -1195,6 +1356,9 @@ type automaticStyles struct {
 type officeStyles struct {
 	XMLName      xml.Name    `xml:"office:styles"`
 	DefaultStyle defaultCell `xml:"style:default-style"`
+	// NumberStyles is set only by [SheetWriter], which writes all cell
+	// styles as common styles, see there.
+	NumberStyles []any       `xml:"number:number-style"`
 	Styles       []cellStyle `xml:"style:style"`
 }
 
@@ -1362,9 +1526,18 @@ type dateStyle struct {
 }
 
 type timeStyle struct {
-	XMLName xml.Name `xml:"number:time-style"`
+	XMLName            xml.Name `xml:"number:time-style"`
+	Name               string   `xml:"style:name,attr"`
+	TruncateOnOverflow string   `xml:"number:truncate-on-overflow,attr,omitempty"`
+	Parts              []any    `xml:"number:text"`
+}
+
+// booleanStyle shows boolean values as the words for TRUE and FALSE of the
+// consumer's language.
+type booleanStyle struct {
+	XMLName xml.Name `xml:"number:boolean-style"`
 	Name    string   `xml:"style:name,attr"`
-	Parts   []any    `xml:"number:text"`
+	Boolean struct{} `xml:"number:boolean"`
 }
 
 type timeHours struct {
@@ -1419,7 +1592,8 @@ type databaseRange struct {
 }
 
 type namedExpressions struct {
-	NamedRanges []namedRange `xml:"table:named-range"`
+	NamedRanges      []namedRange      `xml:"table:named-range"`
+	NamedExpressions []namedExpression `xml:"table:named-expression"`
 }
 
 type namedRange struct {
@@ -1427,3 +1601,11 @@ type namedRange struct {
 	BaseCellAddress  string `xml:"table:base-cell-address,attr"`
 	CellRangeAddress string `xml:"table:cell-range-address,attr"`
 }
+
+// namedExpression is a name standing for a formula, which office
+// applications store with its namespace prefix like cell formulas.
+type namedExpression struct {
+	Name            string `xml:"table:name,attr"`
+	BaseCellAddress string `xml:"table:base-cell-address,attr"`
+	Expression      string `xml:"table:expression,attr"`
+}
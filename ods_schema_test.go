@@ -58,7 +58,11 @@ var schemaTestCases = map[string][][]Cell{
 	"time hh:mm":             {{MakeCell("19:03", "time")}},
 	"time hh:mm:ss":          {{MakeCell("19:03:00", "time")}},
 	"percentage":             {{MakeCell("0.4223", "percentage")}},
-	"formula":                {{MakeCell("A1+B1", "formula")}},
+	"boolean":                {{MakeCell("true", "boolean"), MakeCell("FALSE", "boolean")}},
+	"datetime":               {{MakeCell("2026-03-01T14:30:00", "datetime")}},
+	"datetime with zone":     {{MakeCell("2026-03-01T14:30:00.5+01:00", "datetime")}},
+	"duration":               {{MakeCell("36:15:00", "duration")}},
+	"formula":                {{MakeCell("B1+C1", "formula"), MakeCell("1", "float"), MakeCell("2", "float")}},
 	"currency default (eur)": {{MakeCell("2.22", "currency")}},
 	"currency eur negative":  {{MakeCell("-2.22", "currency-eur")}},
 	"currency usd":           {{MakeCell("2.22", "currency-usd")}},
@@ -81,6 +85,9 @@ var schemaTestCases = map[string][][]Cell{
 			MakeCell("2.22", "currency-usd"),
 			MakeCell("2.22", "currency-gbp"),
 			MakeCell("0.4223", "percentage"),
+			MakeCell("true", "boolean"),
+			MakeCell("2026-03-01T14:30:00", "datetime"),
+			MakeCell("36:15:00", "duration"),
 			MakeCell("A1+B1", "formula"),
 			MakeRangeCell("42", "float", "answer"),
 		},
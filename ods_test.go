@@ -5,8 +5,10 @@
 package ods
 
 import (
+	"bytes"
 	"encoding/csv"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -76,6 +78,28 @@ func tableTest(t *testing.T, testName, format string, inputCells [][]Cell, expec
 func renderAndCompare(t *testing.T, testName, format string, spreadsheet Spreadsheet, expectedCsv map[string][][]string) {
 	t.Helper()
 
+	var content []byte
+	if format == "ods" {
+		buff, err := MakeOds(spreadsheet)
+		if err != nil {
+			t.Fatalf("MakeOds: %v", err)
+		}
+		content = buff.Bytes()
+	} else {
+		actual, err := MakeFlatOds(spreadsheet)
+		if err != nil {
+			t.Fatalf("MakeFlatOds: %v", err)
+		}
+		content = []byte(actual)
+	}
+	renderFileAndCompare(t, testName, format, content, expectedCsv)
+}
+
+// renderFileAndCompare is like renderAndCompare but takes the content of the
+// file, for documents not built from a Spreadsheet.
+func renderFileAndCompare(t *testing.T, testName, format string, content []byte, expectedCsv map[string][][]string) {
+	t.Helper()
+
 	lang := os.Getenv("LANG")
 	expected, ok := expectedCsv[lang]
 	if !ok {
@@ -88,22 +112,8 @@ func renderAndCompare(t *testing.T, testName, format string, spreadsheet Spreads
 	}
 
 	filename := fmt.Sprintf("%s/%s-%s.%s", tempDir, testName, lang, format)
-	if format == "ods" {
-		buff, err := MakeOds(spreadsheet)
-		if err != nil {
-			t.Fatalf("MakeOds: %v", err)
-		}
-		if err := os.WriteFile(filename, buff.Bytes(), 0o644); err != nil {
-			t.Fatal(err)
-		}
-	} else {
-		actual, err := MakeFlatOds(spreadsheet)
-		if err != nil {
-			t.Fatalf("MakeFlatOds: %v", err)
-		}
-		if err := os.WriteFile(filename, []byte(actual), 0o644); err != nil {
-			t.Fatal(err)
-		}
+	if err := os.WriteFile(filename, content, 0o644); err != nil {
+		t.Fatal(err)
 	}
 
 	loCmd := exec.Command("libreoffice", "--headless",
@@ -213,6 +223,30 @@ func TestCommonDataTypes(t *testing.T) {
 	integrationTest(t, "common-data-types", "fods", givenThoseCells, expectedThisCsv)
 }
 
+func TestBooleanDatetimeDuration(t *testing.T) {
+	givenThoseCells := [][]Cell{
+		{
+			MakeCell("true", "boolean"),
+			MakeCell("false", "boolean"),
+			MakeCell("2026-03-01T14:30:15", "datetime"),
+			MakeCell("2026-03-01T14:30:15+01:00", "datetime"),
+			MakeCell("36:15:00", "duration"),
+			MakeCell("0:05", "duration"),
+		},
+	}
+
+	expectedThisCsv := make(map[string][][]string)
+	expectedThisCsv["en_US.UTF-8"] = [][]string{
+		{"TRUE", "FALSE", "2026-03-01 14:30:15", "2026-03-01 13:30:15", "36:15:00", "00:05:00"},
+	}
+	expectedThisCsv["de_DE.UTF-8"] = [][]string{
+		{"WAHR", "FALSCH", "2026-03-01 14:30:15", "2026-03-01 13:30:15", "36:15:00", "00:05:00"},
+	}
+
+	integrationTest(t, "boolean-datetime-duration", "ods", givenThoseCells, expectedThisCsv)
+	integrationTest(t, "boolean-datetime-duration", "fods", givenThoseCells, expectedThisCsv)
+}
+
 func TestCurrencyFormatting(t *testing.T) {
 	givenThoseCells := [][]Cell{
 		{
@@ -396,6 +430,48 @@ func TestUnitNoAutoFilterByDefault(t *testing.T) {
 	assert(t, !strings.Contains(actual, "database-range"), "expected no database-range without EnableAutoFilter")
 }
 
+// failingWriter accepts limit bytes and fails every write after that.
+type failingWriter struct {
+	limit int
+}
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.limit {
+		n := w.limit
+		w.limit = 0
+		return n, errors.New("disk full")
+	}
+	w.limit -= len(p)
+	return len(p), nil
+}
+
+func TestUnitWriteFlatOds(t *testing.T) {
+	spreadsheet, err := MakeTable([][]Cell{
+		{MakeCell("Name", "string"), MakeCell("Age", "string")},
+		{MakeStyledCell("Alice", "string", CellStyle{Italic: true}), MakeRangeCell("30", "float", "Age")},
+	}, TableOptions{Header: true, AutoFilter: true})
+	if err != nil {
+		t.Fatalf("MakeTable: %v", err)
+	}
+
+	expected, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	var actual bytes.Buffer
+	if err := WriteFlatOds(&actual, spreadsheet); err != nil {
+		t.Fatalf("WriteFlatOds: %v", err)
+	}
+	assert(t, actual.String() == expected, "expected WriteFlatOds to write what MakeFlatOds returns")
+	assert(t, strings.HasPrefix(expected, xml.Header+` <office:document xmlns:office=`), "expected the document to start with the XML header and the indented root element")
+
+	for _, limit := range []int{0, len(xml.Header) + 100, len(expected) - 100} {
+		if err := WriteFlatOds(&failingWriter{limit: limit}, spreadsheet); err == nil || !strings.Contains(err.Error(), "disk full") {
+			t.Errorf("expected the error of the writer failing after %d bytes, got: %v", limit, err)
+		}
+	}
+}
+
 func TestUnitTable(t *testing.T) {
 	cells := [][]Cell{
 		{MakeCell("Product", "string"), MakeCell("Price", "string")},
@@ -640,6 +716,73 @@ func TestUnitDateParse(t *testing.T) {
 	}
 }
 
+func TestUnitDatetimeParse(t *testing.T) {
+	cases := map[string]string{
+		"2026-03-01T14:30:00":       "2026-03-01T14:30:00",
+		"2026-03-01T14:30":          "2026-03-01T14:30:00",
+		"2026-03-01 14:30:15":       "2026-03-01T14:30:15",
+		"2026-03-01T14:30:15.250":   "2026-03-01T14:30:15.25",
+		"2026-03-01T14:30:00Z":      "2026-03-01T14:30:00",
+		"2026-03-01T00:30:00+01:00": "2026-02-28T23:30:00",
+		"2026-03-01 14:30-05:00":    "2026-03-01T19:30:00",
+	}
+
+	for candidate, expected := range cases {
+		actual, err := datetimeString(candidate)
+		if err != nil {
+			t.Errorf("datetimeString(%q): %v", candidate, err)
+		}
+		assert(t, actual == expected, fmt.Sprintf("Expected %s to be formatted as %s, got %s", candidate, expected, actual))
+	}
+}
+
+func TestUnitDurationParse(t *testing.T) {
+	cases := map[string]string{
+		"36:15:00": "PT36H15M00S",
+		"36:15":    "PT36H15M00S",
+		"0:05:30":  "PT00H05M30S",
+		"1234:00":  "PT1234H00M00S",
+	}
+
+	for candidate, expected := range cases {
+		actual, err := durationString(candidate)
+		if err != nil {
+			t.Errorf("durationString(%q): %v", candidate, err)
+		}
+		assert(t, actual == expected, fmt.Sprintf("Expected %s to be parsed as %s, got %s", candidate, expected, actual))
+	}
+}
+
+func TestUnitBooleanAndDurationCells(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheet([][]Cell{{
+		MakeCell("true", "boolean"),
+		MakeCell("FALSE", "boolean"),
+		MakeCell("A1", "formula"),
+		MakeCell("36:15:00", "duration"),
+	}})
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+
+	cells := spreadsheet.Tables[0].Rows[0].Cells
+	assert(t, cells[0].ValueType == "boolean" && cells[0].BooleanValue == "true" && cells[0].StyleName == "BOOLEAN_STYLE", fmt.Sprintf("unexpected boolean cell %+v", cells[0]))
+	assert(t, cells[1].BooleanValue == "false", "expected FALSE to be stored as false, got "+cells[1].BooleanValue)
+	assert(t, cells[2].BooleanValue == "true", "expected the formula to evaluate to the boolean, got "+cells[2].BooleanValue)
+	assert(t, cells[3].ValueType == "time" && cells[3].StyleName == "DURATION_STYLE", fmt.Sprintf("unexpected duration cell %+v", cells[3]))
+
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	for _, expected := range []string{
+		`<number:boolean-style style:name="BOOLEAN_DATA_STYLE">`,
+		`<number:boolean></number:boolean>`,
+		`<number:time-style style:name="DURATION_DATA_STYLE" number:truncate-on-overflow="false">`,
+	} {
+		assert(t, strings.Contains(flatOds, expected), "expected the document to contain "+expected)
+	}
+}
+
 func TestUnitInvalidInput(t *testing.T) {
 	invalidCells := map[string]Cell{
 		"unknown value type":  MakeCell("42", "number"),
@@ -648,6 +791,11 @@ func TestUnitInvalidInput(t *testing.T) {
 		"malformed time":      MakeCell("25 o'clock", "time"),
 		"non-numeric float":   MakeCell("fourtytwo", "float"),
 		"non-numeric percent": MakeCell("42 %", "percentage"),
+		"malformed boolean":   MakeCell("yes", "boolean"),
+		"date as datetime":    MakeCell("2026-03-01", "datetime"),
+		"malformed datetime":  MakeCell("01.03.2026 14:30", "datetime"),
+		"minutes past 59":     MakeCell("36:75:00", "duration"),
+		"negative duration":   MakeCell("-1:00:00", "duration"),
 	}
 
 	for name, cell := range invalidCells {
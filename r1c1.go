@@ -0,0 +1,231 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// MakeR1C1Cell creates a cell holding a formula written in R1C1 notation,
+// which gives references by row and column number instead of letters:
+// absolute (R5C2 for $B$5), or relative to the cell of the formula in
+// brackets (R[-1]C[2]), with an omitted number standing for the formula's own
+// row or column (RC[-2]*RC[-1] multiplies the two cells to its left). A row
+// (R2, R[1]) or column (C3, C[-1]) on its own denotes all of it.
+//
+// As the same formula refers to different cells depending on where it is, it
+// is translated into an A1-style formula, and further into OpenFormula like
+// those of [MakeCell], once [MakeSpreadsheet] knows the cell's row and
+// column. Apart from references, the notation is that of [MakeCell]. Syntax
+// errors and references above the first row or left of the first column are
+// reported by [MakeSpreadsheet].
+func MakeR1C1Cell(formula string) Cell {
+	return Cell{r1c1Source: formula}
+}
+
+// r1c1Part is the row or the column of a reference in R1C1 notation.
+type r1c1Part struct {
+	// present is unset if the reference omits the part, as a whole row
+	// ("R2") omits the column.
+	present bool
+	// relative is set if value is an offset from the cell of the formula.
+	relative bool
+	value    int
+}
+
+// r1c1Endpoint is one end of a reference in R1C1 notation.
+type r1c1Endpoint struct {
+	row, col r1c1Part
+}
+
+func (e r1c1Endpoint) kind() endpointKind {
+	switch {
+	case !e.col.present:
+		return rowEndpoint
+	case !e.row.present:
+		return columnEndpoint
+	}
+	return cellEndpoint
+}
+
+// scanR1C1Reference scans a cell or range reference in R1C1 notation and
+// translates it into the bracketed OpenFormula form, relative to p.origin:
+//
+//	R1C1:R2C2         .$A$1:.$B$2
+//	RC[-1]            .C5, in a formula in D5
+//	Sheet2!R[1]C      Sheet2.D6
+//	'Q1 2026'!C2      'Q1 2026'.$B:.$B
+//	R[-1]:R           .4:.5
+//
+// It reports false if there is no reference at p.pos, as for names, function
+// names, and numbers.
+func (p *formulaParser) scanR1C1Reference() (token, bool) {
+	start := p.pos
+	sheet := p.scanR1C1Sheet()
+	first, ok := p.scanR1C1Endpoint()
+	if !ok {
+		return token{}, false
+	}
+	last := first
+	if p.peekIs(0, ':') {
+		colon := p.pos
+		p.pos++
+		second, ok := p.scanR1C1Endpoint()
+		if ok && second.kind() == first.kind() {
+			last = second
+		} else {
+			// A name after the colon makes the colon the range operator.
+			p.pos = colon
+		}
+	}
+
+	text := string(p.input[start:p.pos])
+	from, err := p.resolveR1C1(first, text)
+	if err != nil {
+		return token{kind: tokenInvalid, text: text, err: err}, true
+	}
+	to, err := p.resolveR1C1(last, text)
+	if err != nil {
+		return token{kind: tokenInvalid, text: text, err: err}, true
+	}
+	address := "."
+	if sheet != "" {
+		address = quoteSheetName(sheet) + "."
+	}
+	address += from
+	if from != to || first.kind() != cellEndpoint {
+		address += ":." + to
+	}
+	ref, err := parseRangeAddress(address)
+	if err != nil {
+		return token{kind: tokenInvalid, text: text, err: err}, true
+	}
+	return token{kind: tokenReference, text: text, address: address, ref: ref}, true
+}
+
+// scanR1C1Sheet scans the sheet qualifying a reference, quoted or not, and
+// the "!" after it. It returns "" and leaves p.pos alone if there is none.
+func (p *formulaParser) scanR1C1Sheet() string {
+	start := p.pos
+	var name string
+	if p.peekIs(0, '\'') {
+		quoted, ok := p.scanQuotedSheetName()
+		if !ok {
+			p.pos = start
+			return ""
+		}
+		name = quoted
+	} else {
+		name = p.scanWord(false)
+	}
+	if name == "" || !p.peekIs(0, '!') {
+		p.pos = start
+		return ""
+	}
+	p.pos++
+	return name
+}
+
+// scanR1C1Endpoint scans one end of a reference: a row, a column, or both.
+// The reference must not continue into an identifier or a function call, as
+// in the names "Rate" or "Cost".
+func (p *formulaParser) scanR1C1Endpoint() (r1c1Endpoint, bool) {
+	var e r1c1Endpoint
+	var ok bool
+	if e.row, ok = p.scanR1C1Part('R'); !ok {
+		return e, false
+	}
+	if e.col, ok = p.scanR1C1Part('C'); !ok {
+		return e, false
+	}
+	if !e.row.present && !e.col.present {
+		return e, false
+	}
+	if p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c == '_' || c == '.' || c == '(' || unicode.IsLetter(c) || unicode.IsDigit(c) {
+			return e, false
+		}
+	}
+	return e, true
+}
+
+// scanR1C1Part scans the row or column of a reference, introduced by letter
+// in either case: a number, a signed offset in brackets, or nothing for the
+// row or column of the formula.
+func (p *formulaParser) scanR1C1Part(letter rune) (r1c1Part, bool) {
+	if p.pos >= len(p.input) || unicode.ToUpper(p.input[p.pos]) != letter {
+		return r1c1Part{}, true
+	}
+	p.pos++
+
+	if p.peekIs(0, '[') {
+		end := p.pos + 1
+		if end < len(p.input) && (p.input[end] == '-' || p.input[end] == '+') {
+			end++
+		}
+		for end < len(p.input) && isDigit(p.input[end]) {
+			end++
+		}
+		if end >= len(p.input) || p.input[end] != ']' {
+			// Not an offset, but maybe the brackets of a structured
+			// reference to a table named R or C.
+			return r1c1Part{}, false
+		}
+		offset, err := strconv.Atoi(string(p.input[p.pos+1 : end]))
+		if err != nil {
+			return r1c1Part{}, false
+		}
+		p.pos = end + 1
+		return r1c1Part{present: true, relative: true, value: offset}, true
+	}
+
+	start := p.pos
+	for p.pos < len(p.input) && isDigit(p.input[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		return r1c1Part{present: true, relative: true}, true
+	}
+	n, err := strconv.Atoi(string(p.input[start:p.pos]))
+	if err != nil || n < 1 {
+		return r1c1Part{}, false
+	}
+	return r1c1Part{present: true, value: n}, true
+}
+
+// resolveR1C1 returns the A1 spelling of an end of the reference text, with
+// absolute parts marked as such and relative ones taken from p.origin.
+func (p *formulaParser) resolveR1C1(e r1c1Endpoint, text string) (string, error) {
+	var b strings.Builder
+	if e.col.present {
+		col := e.col.value
+		if e.col.relative {
+			col += p.origin.col
+		} else {
+			b.WriteString("$")
+		}
+		if col < 1 {
+			return "", fmt.Errorf("reference %s from column %d lies left of the first column", text, p.origin.col)
+		}
+		b.WriteString(columnToLetters(col))
+	}
+	if e.row.present {
+		row := e.row.value
+		if e.row.relative {
+			row += p.origin.row
+		} else {
+			b.WriteString("$")
+		}
+		if row < 1 {
+			return "", fmt.Errorf("reference %s from row %d lies above the first row", text, p.origin.row)
+		}
+		b.WriteString(strconv.Itoa(row))
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,105 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// ledgerCells are the rows of a ledger whose every row holds the same R1C1
+// formulas.
+func ledgerCells() [][]Cell {
+	var cells [][]Cell
+	for _, r := range [][2]string{{"2", "1.5"}, {"3", "4"}, {"1", "10"}} {
+		cells = append(cells, []Cell{MakeCell(r[0], "float"), MakeCell(r[1], "float"), MakeR1C1Cell("RC[-2]*RC[-1]")})
+	}
+	return append(cells, []Cell{{}, {}, MakeR1C1Cell("SUM(R1C:R[-1]C)")})
+}
+
+func TestUnitR1C1Cell(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheet(ledgerCells())
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	rows := spreadsheet.Tables[0].Rows
+	for i, expected := range []string{"of:=[.A1]*[.B1]", "of:=[.A2]*[.B2]", "of:=[.A3]*[.B3]", "of:=SUM([.C$1:.C3])"} {
+		assert(t, rows[i].Cells[2].Formula == expected, fmt.Sprintf("row %d: expected %s, got %s", i+1, expected, rows[i].Cells[2].Formula))
+	}
+	assert(t, rows[3].Cells[2].Value == "25", "expected the total to be evaluated, got "+rows[3].Cells[2].Value)
+
+	cases := map[string]string{
+		"R1C1+R[1]C[1]":               "of:=[.$A$1]+[.D6]",
+		"SUM(R1C1:R2C3)":              "of:=SUM([.$A$1:.$C$2])",
+		"SUM(C, R[-1])":               "of:=SUM([.C:.C];[.4:.4])",
+		"SUM(C1:C[1])":                "of:=SUM([.$A:.D])",
+		"Sheet2!RC+'Q1 2026'!R1C[-2]": "of:=[Sheet2.C5]+['Q1 2026'.A$1]",
+		"rc[-1]*Rate":                 "of:=[.B5]*Rate",
+		"ROUND(RC[-1],2)":             "of:=ROUND([.B5];2)",
+		"IF(R[-4]C>0,{1,2},R)":        "of:=IF([.C1]>0;{1;2};[.5:.5])",
+		"R2D2+Cost":                   "of:=R2D2+Cost",
+	}
+	for formula, expected := range cases {
+		t.Run(formula, func(t *testing.T) {
+			cells := [][]Cell{{}, {}, {}, {}, {MakeCell("1", "float"), MakeCell("2", "float"), MakeR1C1Cell(formula)}}
+			b := newWorkbookBuilder(nil)
+			if err := b.addCells("Sheet1", cells); err != nil {
+				t.Fatalf("addCells: %v", err)
+			}
+			if err := b.resolveFormulas(b.tables[0]); err != nil {
+				t.Fatalf("resolveFormulas: %v", err)
+			}
+			actual := b.tables[0].Rows[4].Cells[2].Formula
+			assert(t, actual == expected, fmt.Sprintf("expected %s, got %s", expected, actual))
+		})
+	}
+}
+
+func TestUnitR1C1CellErrors(t *testing.T) {
+	cases := map[string]string{
+		"R[-1]C":      `row 1, column 1: invalid formula "R[-1]C": reference R[-1]C from row 1 lies above the first row`,
+		"RC[-2]+1":    `row 1, column 1: invalid formula "RC[-2]+1": reference RC[-2] from column 1 lies left of the first column`,
+		"SUM(RC[1]":   `row 1, column 1: invalid formula "SUM(RC[1]": missing closing parenthesis`,
+		"R0C1":        `row 1, column 1: undefined name "R0C1"`,
+		"Undefined*2": `row 1, column 1: undefined name "Undefined"`,
+	}
+	for formula, expected := range cases {
+		t.Run(formula, func(t *testing.T) {
+			_, err := MakeSpreadsheet([][]Cell{{MakeR1C1Cell(formula)}})
+			assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+		})
+	}
+
+	// Streamed rows know their position too.
+	written := writeSheet(t, ledgerCells())
+	streamed, err := ReadOds(bytes.NewReader(written.Bytes()), int64(written.Len()))
+	if err != nil {
+		t.Fatalf("ReadOds: %v", err)
+	}
+	formula := streamed.Tables[0].Rows[1].Cells[2].Formula
+	assert(t, formula == "of:=[.A2]*[.B2]", "expected the streamed formula to refer to its own row, got "+formula)
+}
+
+func TestR1C1Cell(t *testing.T) {
+	expectedThisCsv := map[string][][]string{
+		"en_US.UTF-8": {
+			{"2.00", "1.50", "3"},
+			{"3.00", "4.00", "12"},
+			{"1.00", "10.00", "10"},
+			{"", "", "25"},
+		},
+		"de_DE.UTF-8": {
+			{"2,00", "1,50", "3"},
+			{"3,00", "4,00", "12"},
+			{"1,00", "10,00", "10"},
+			{"", "", "25"},
+		},
+	}
+
+	integrationTest(t, "r1c1", "ods", ledgerCells(), expectedThisCsv)
+	integrationTest(t, "r1c1", "fods", ledgerCells(), expectedThisCsv)
+}
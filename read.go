@@ -0,0 +1,533 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strconv"
+	"strings"
+)
+
+// Namespaces of the elements and attributes the reader looks at. The decoder
+// resolves prefixes, so documents using other prefixes than the ones this
+// package writes are read all the same.
+const (
+	nsOffice = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	nsTable  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	nsText   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+	nsStyle  = "urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+	nsFo     = "urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0"
+)
+
+// Limits of the sheet size, as in LibreOffice and Excel. Repeated rows and
+// columns beyond them are not expanded.
+const (
+	maxRows    = 1048576
+	maxColumns = 16384
+)
+
+// maxSpaces limits the spaces text:s elements expand to in a paragraph, so
+// that a hostile count does not exhaust memory. Excel holds no more
+// characters in a cell.
+const maxSpaces = 32767
+
+// ReadOds parses a zipped OpenDocument spreadsheet (.ods) of the given size
+// into a [Spreadsheet].
+//
+// Cell values, formulas, the styles generated for [MakeStyledCell] and
+// [MakeTable], named ranges, and database ranges are read back, so that
+// writing a document created by this package after reading it produces the
+// same bytes. Documents created elsewhere are read as far as the model of this
+// package reaches: number formats other than the ones it generates, column
+// widths, and other formatting are dropped.
+func ReadOds(r io.ReaderAt, size int64) (Spreadsheet, error) {
+	archive, err := zip.NewReader(r, size)
+	if err != nil {
+		return Spreadsheet{}, fmt.Errorf("opening zip archive: %w", err)
+	}
+
+	reader := newDocumentReader()
+	// styles.xml goes first: the common styles defined there are referenced
+	// from content.xml.
+	for _, name := range []string{"styles.xml", "content.xml"} {
+		f, err := archive.Open(name)
+		if err != nil {
+			if name == "styles.xml" && errors.Is(err, fs.ErrNotExist) {
+				continue
+			}
+			return Spreadsheet{}, fmt.Errorf("opening %s: %w", name, err)
+		}
+		err = reader.read(f)
+		f.Close()
+		if err != nil {
+			return Spreadsheet{}, fmt.Errorf("reading %s: %w", name, err)
+		}
+	}
+	return reader.spreadsheet(), nil
+}
+
+// ReadFlatOds parses a flat OpenDocument spreadsheet (.fods) into a
+// [Spreadsheet]. See [ReadOds] for what is read.
+func ReadFlatOds(r io.Reader) (Spreadsheet, error) {
+	reader := newDocumentReader()
+	if err := reader.read(r); err != nil {
+		return Spreadsheet{}, fmt.Errorf("reading flat ods document: %w", err)
+	}
+	return reader.spreadsheet(), nil
+}
+
+// SheetCells returns the cells of the named sheet of spreadsheet, in the form
+// they had when they were passed to [MakeSpreadsheet]: a cell that refers to a
+// generated style carries the corresponding [CellStyle] again, and a cell that
+// a single-cell named range points to carries the range name. Appending rows
+// to the result and passing it to [MakeSpreadsheetWithName] rebuilds the
+// sheet.
+//
+// Named ranges spanning more than one cell and AutoFilter settings belong to
+// the sheet rather than to its cells and are not part of the result. Cells
+// covered by a matrix formula are empty, as building the sheet covers them
+// again.
+func SheetCells(spreadsheet Spreadsheet, sheet string) ([][]Cell, error) {
+	for _, t := range spreadsheet.Tables {
+		if t.Name != sheet {
+			continue
+		}
+
+		styles := map[string]cellStyle{}
+		for _, style := range spreadsheet.customStyles {
+			styles[style.Name] = style
+		}
+		rangeNames := map[string]string{}
+		for _, nr := range spreadsheet.NamedExpressions.NamedRanges {
+			if nr.BaseCellAddress == nr.CellRangeAddress {
+				rangeNames[nr.CellRangeAddress] = nr.Name
+			}
+		}
+
+		cells := make([][]Cell, len(t.Rows))
+		for i, r := range t.Rows {
+			cells[i] = make([]Cell, len(r.Cells))
+			for j, c := range r.Cells {
+				if c.covered {
+					// The results stored in them would overlap the
+					// matrix formula.
+					continue
+				}
+				c.rangeName = rangeNames[fmt.Sprintf("$%s.%s", quoteSheetName(t.Name), toA1(i+1, j+1))]
+				if style, ok := styles[c.StyleName]; ok {
+					c.style = cellStyleOf(style)
+					c.StyleName = presetStyleNameFor(style.DataStyleName)
+				}
+				cells[i][j] = c
+			}
+		}
+		return cells, nil
+	}
+	return nil, fmt.Errorf("no sheet named %q", sheet)
+}
+
+// cellStyleOf is the inverse of buildCustomCellStyle.
+func cellStyleOf(style cellStyle) *CellStyle {
+	cs := &CellStyle{}
+	if p := style.TableCellProperties; p != nil {
+		cs.BackgroundColor = p.BackgroundColor
+		cs.Border = p.Border
+	}
+	if p := style.TextProperties; p != nil {
+		cs.FontColor = p.Color
+		cs.Bold = p.FontWeight == "bold"
+		cs.Italic = p.FontStyle == "italic"
+	}
+	return cs
+}
+
+// presetStyleNameFor is the inverse of dataStyleNameFor.
+func presetStyleNameFor(dataStyleName string) string {
+	for _, preset := range createStyles() {
+		if preset.DataStyleName == dataStyleName && dataStyleName != "" {
+			return preset.Name
+		}
+	}
+	return ""
+}
+
+// documentReader collects the parts of a document that make up a
+// Spreadsheet. A package spreads them over styles.xml and content.xml, a flat
+// document has them all in one; both are fed through read.
+type documentReader struct {
+	tables         []table
+	names          namedExpressions
+	databaseRanges []databaseRange
+	customStyles   []cellStyle
+
+	// known holds the names of the styles the reader keeps, so that a style
+	// defined both as a common and as an automatic style is kept once.
+	known map[string]bool
+	// dataStyles holds the number formats this package generates; references
+	// to any other are dropped, since the reader does not keep their
+	// definitions.
+	dataStyles map[string]bool
+}
+
+func newDocumentReader() *documentReader {
+	known := map[string]bool{defaultCellStyleName: true, tableStyleName: true}
+	dataStyles := map[string]bool{}
+	for _, preset := range createStyles() {
+		known[preset.Name] = true
+		dataStyles[preset.DataStyleName] = true
+	}
+	return &documentReader{known: known, dataStyles: dataStyles}
+}
+
+func (dr *documentReader) spreadsheet() Spreadsheet {
+	spreadsheet := Spreadsheet{
+		Tables:           dr.tables,
+		NamedExpressions: dr.names,
+		customStyles:     dr.customStyles,
+	}
+	if len(dr.databaseRanges) > 0 {
+		spreadsheet.DatabaseRanges = &databaseRanges{Ranges: dr.databaseRanges}
+	}
+	return spreadsheet
+}
+
+func (dr *documentReader) read(r io.Reader) error {
+	d := xml.NewDecoder(r)
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch {
+		case start.Name.Space == nsStyle && start.Name.Local == "style":
+			if err := dr.readStyle(d, start); err != nil {
+				return err
+			}
+		case start.Name.Space == nsTable && start.Name.Local == "table":
+			t, err := readTable(d, start)
+			if err != nil {
+				return err
+			}
+			dr.tables = append(dr.tables, t)
+		case start.Name.Space == nsTable && (start.Name.Local == "named-range" || start.Name.Local == "named-expression"):
+			dr.names.add(start)
+		case start.Name.Space == nsTable && start.Name.Local == "database-range":
+			dr.databaseRanges = append(dr.databaseRanges, databaseRange{
+				Name:                 attr(start, nsTable, "name"),
+				TargetRangeAddress:   attr(start, nsTable, "target-range-address"),
+				DisplayFilterButtons: attr(start, nsTable, "display-filter-buttons"),
+			})
+		}
+	}
+}
+
+// add adds the table:named-range or table:named-expression element start.
+func (n *namedExpressions) add(start xml.StartElement) {
+	if start.Name.Local == "named-range" {
+		n.NamedRanges = append(n.NamedRanges, namedRange{
+			Name:             attr(start, nsTable, "name"),
+			BaseCellAddress:  attr(start, nsTable, "base-cell-address"),
+			CellRangeAddress: attr(start, nsTable, "cell-range-address"),
+		})
+		return
+	}
+	n.NamedExpressions = append(n.NamedExpressions, namedExpression{
+		Name:            attr(start, nsTable, "name"),
+		BaseCellAddress: attr(start, nsTable, "base-cell-address"),
+		Expression:      attr(start, nsTable, "expression"),
+	})
+}
+
+// readStyle reads a style:style element. Cell styles other than the preset
+// ones are kept as generated styles; everything else is written anew by
+// [MakeFlatOds] and [WriteOds] and skipped.
+func (dr *documentReader) readStyle(d *xml.Decoder, start xml.StartElement) error {
+	name := attr(start, nsStyle, "name")
+	if attr(start, nsStyle, "family") != "table-cell" || dr.known[name] {
+		return d.Skip()
+	}
+
+	style := cellStyle{
+		Name:            name,
+		Family:          "table-cell",
+		ParentStyleName: attr(start, nsStyle, "parent-style-name"),
+		DataStyleName:   attr(start, nsStyle, "data-style-name"),
+	}
+	if style.ParentStyleName != "" {
+		// Other parents are not kept, and a parent that is defined nowhere
+		// costs consumers the whole style.
+		style.ParentStyleName = defaultCellStyleName
+	}
+	if !dr.dataStyles[style.DataStyleName] {
+		style.DataStyleName = ""
+	}
+
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == nsStyle && t.Name.Local == "table-cell-properties":
+				p := &tableCellProperties{
+					BackgroundColor: attr(t, nsFo, "background-color"),
+					Border:          attr(t, nsFo, "border"),
+				}
+				if *p != (tableCellProperties{}) {
+					style.TableCellProperties = p
+				}
+			case t.Name.Space == nsStyle && t.Name.Local == "text-properties":
+				p := &textProperties{
+					Color:      attr(t, nsFo, "color"),
+					FontWeight: attr(t, nsFo, "font-weight"),
+					FontStyle:  attr(t, nsFo, "font-style"),
+				}
+				if *p != (textProperties{}) {
+					style.TextProperties = p
+				}
+			}
+			if err := d.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			dr.known[name] = true
+			dr.customStyles = append(dr.customStyles, style)
+			return nil
+		}
+	}
+}
+
+// readTable reads a table:table element, expanding repeated rows and cells.
+//
+// Applications pad a sheet to its full size with repeated empty rows and
+// cells; those are dropped at the end of a row and at the end of the sheet.
+func readTable(d *xml.Decoder, start xml.StartElement) (table, error) {
+	t := table{Name: attr(start, nsTable, "name"), StyleName: tableStyleName}
+
+	maxCols := 1
+	// trailing counts the empty rows read since the last non-empty one. They
+	// are only added to the table once a non-empty row follows.
+	trailing := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return table{}, err
+		}
+		switch tt := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case tt.Name.Space == nsTable && tt.Name.Local == "table-row":
+				cells, err := readRow(d)
+				if err != nil {
+					return table{}, err
+				}
+				repeat := repeated(tt, "number-rows-repeated")
+				if len(cells) == 0 && repeat > 1 {
+					trailing = min(trailing+repeat, maxRows)
+					continue
+				}
+				for range min(trailing, maxRows-len(t.Rows)) {
+					t.Rows = append(t.Rows, row{})
+				}
+				trailing = 0
+				for range min(repeat, maxRows-len(t.Rows)) {
+					t.Rows = append(t.Rows, row{Cells: append([]Cell(nil), cells...)})
+				}
+				maxCols = max(maxCols, len(cells))
+			case tt.Name.Space == nsTable && (tt.Name.Local == "table-header-rows" ||
+				tt.Name.Local == "table-row-group" || tt.Name.Local == "table-rows"):
+				// Grouping elements: their rows belong to the table as well.
+			case tt.Name.Space == nsTable && tt.Name.Local == "named-expressions":
+				// The names scoped to the sheet.
+				if t.NamedExpressions == nil {
+					t.NamedExpressions = &namedExpressions{}
+				}
+			case tt.Name.Space == nsTable && (tt.Name.Local == "named-range" || tt.Name.Local == "named-expression"):
+				if t.NamedExpressions != nil {
+					t.NamedExpressions.add(tt)
+				}
+				if err := d.Skip(); err != nil {
+					return table{}, err
+				}
+			default:
+				if err := d.Skip(); err != nil {
+					return table{}, err
+				}
+			}
+		case xml.EndElement:
+			if tt.Name == start.Name {
+				t.Columns = []tableColumn{{NumberColumnsRepeated: strconv.Itoa(maxCols)}}
+				return t, nil
+			}
+		}
+	}
+}
+
+// readRow reads the cells of a table:table-row element.
+func readRow(d *xml.Decoder) ([]Cell, error) {
+	var cells []Cell
+	// trailing counts the empty cells read since the last non-empty one, see
+	// readTable.
+	trailing := 0
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space != nsTable || (t.Name.Local != "table-cell" && t.Name.Local != "covered-table-cell") {
+				if err := d.Skip(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			cell, err := readCell(d, t)
+			if err != nil {
+				return nil, err
+			}
+			repeat := repeated(t, "number-columns-repeated")
+			if isEmptyCell(cell) && !cell.covered && repeat > 1 {
+				trailing = min(trailing+repeat, maxColumns)
+				continue
+			}
+			for range min(trailing, maxColumns-len(cells)) {
+				cells = append(cells, Cell{})
+			}
+			trailing = 0
+			for range min(repeat, maxColumns-len(cells)) {
+				cells = append(cells, cell)
+			}
+		case xml.EndElement:
+			return cells, nil
+		}
+	}
+}
+
+// readCell reads a table:table-cell or table:covered-table-cell element. The
+// paragraphs of its text content are joined by line breaks.
+func readCell(d *xml.Decoder, start xml.StartElement) (Cell, error) {
+	cell := Cell{
+		ValueType:    attr(start, nsOffice, "value-type"),
+		Value:        attr(start, nsOffice, "value"),
+		DateValue:    attr(start, nsOffice, "date-value"),
+		TimeValue:    attr(start, nsOffice, "time-value"),
+		BooleanValue: attr(start, nsOffice, "boolean-value"),
+		Currency:     attr(start, nsOffice, "currency"),
+		StyleName:    attr(start, nsTable, "style-name"),
+		Formula:      attr(start, nsTable, "formula"),
+
+		NumberMatrixColumnsSpanned: attr(start, nsTable, "number-matrix-columns-spanned"),
+		NumberMatrixRowsSpanned:    attr(start, nsTable, "number-matrix-rows-spanned"),
+		covered:                    start.Name.Local == "covered-table-cell",
+	}
+
+	var paragraphs []string
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return Cell{}, err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			if t.Name.Space == nsText && t.Name.Local == "p" {
+				text, err := readParagraph(d)
+				if err != nil {
+					return Cell{}, err
+				}
+				paragraphs = append(paragraphs, text)
+				continue
+			}
+			if err := d.Skip(); err != nil {
+				return Cell{}, err
+			}
+		case xml.EndElement:
+			cell.Text = strings.Join(paragraphs, "\n")
+			return cell, nil
+		}
+	}
+}
+
+// isEmptyCell reports whether a cell read from a document holds nothing but,
+// possibly, a style.
+func isEmptyCell(c Cell) bool {
+	return c.ValueType == "" && c.Value == "" && c.DateValue == "" && c.TimeValue == "" &&
+		c.BooleanValue == "" && c.Text == "" && c.Formula == ""
+}
+
+// readParagraph returns the text of a text:p element, including that of
+// nested spans and links, with text:s, text:tab, and text:line-break turned
+// back into the characters they stand for.
+func readParagraph(d *xml.Decoder) (string, error) {
+	var b strings.Builder
+	spaces := 0
+	depth := 1
+	for depth > 0 {
+		tok, err := d.Token()
+		if err != nil {
+			return "", err
+		}
+		switch t := tok.(type) {
+		case xml.CharData:
+			b.Write(t)
+		case xml.StartElement:
+			depth++
+			if t.Name.Space != nsText {
+				continue
+			}
+			switch t.Name.Local {
+			case "s":
+				n := min(repeated(t, "c"), maxSpaces-spaces)
+				spaces += n
+				b.WriteString(strings.Repeat(" ", n))
+			case "tab":
+				b.WriteString("\t")
+			case "line-break":
+				b.WriteString("\n")
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+	return b.String(), nil
+}
+
+// repeated returns the value of a repetition count attribute in the table
+// namespace (or, for text:s, the text namespace), which defaults to one.
+func repeated(start xml.StartElement, local string) int {
+	value := attr(start, nsTable, local)
+	if local == "c" {
+		value = attr(start, nsText, local)
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// attr returns the value of the attribute space:local of start, or "".
+func attr(start xml.StartElement, space, local string) string {
+	for _, a := range start.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
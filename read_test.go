@@ -0,0 +1,266 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// roundTripSpreadsheets covers everything the reader has to restore for a
+// document to be written back unchanged.
+func roundTripSpreadsheets(t *testing.T) map[string]Spreadsheet {
+	t.Helper()
+
+	plain, err := MakeSpreadsheet([][]Cell{
+		{
+			MakeRangeCell("42.3324", "float", "InputA"),
+			MakeCell("ABBA & \"friends\" <3", "string"),
+			MakeCell("2022-02-02", "date"),
+			MakeCell("19:03:00", "time"),
+			MakeCell("0.4223", "percentage"),
+			MakeCell("-2.22", "currency-usd"),
+			MakeCell("InputA*2", "formula"),
+		},
+		{},
+		{
+			MakeStyledCell("Navy", "string", CellStyle{BackgroundColor: ColorNavy, FontColor: ColorWhite}),
+			MakeStyledCell("42", "float", CellStyle{Bold: true, Italic: true, Border: "0.5pt solid #000000"}),
+			MakeCell("multi\nline", "string"),
+		},
+	})
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+
+	table, err := MakeTable([][]Cell{
+		{MakeCell("Product", "string"), MakeCell("Unit Price", "string")},
+		{MakeCell("Pen", "string"), MakeCell("1.49", "currency")},
+		{MakeCell("Desk", "string"), MakeCell("189.00", "currency")},
+	}, TableOptions{
+		Name:           "Products",
+		Header:         true,
+		AutoFilter:     true,
+		BandedRows:     true,
+		StructuredRefs: true,
+		Totals:         []Total{{TotalCount}, {TotalSum}},
+	})
+	if err != nil {
+		t.Fatalf("MakeTable: %v", err)
+	}
+
+	return map[string]Spreadsheet{
+		"plain":       plain,
+		"table":       table,
+		"auto filter": EnableAutoFilter(plain),
+	}
+}
+
+func TestUnitReadFlatOdsRoundTrip(t *testing.T) {
+	for name, spreadsheet := range roundTripSpreadsheets(t) {
+		t.Run(name, func(t *testing.T) {
+			written, err := MakeFlatOds(spreadsheet)
+			if err != nil {
+				t.Fatalf("MakeFlatOds: %v", err)
+			}
+
+			read, err := ReadFlatOds(strings.NewReader(written))
+			if err != nil {
+				t.Fatalf("ReadFlatOds: %v", err)
+			}
+			rewritten, err := MakeFlatOds(read)
+			if err != nil {
+				t.Fatalf("MakeFlatOds: %v", err)
+			}
+
+			if rewritten != written {
+				t.Errorf("the document changed in a round trip:\n%s\nbecame\n%s", written, rewritten)
+			}
+		})
+	}
+}
+
+func TestUnitReadOdsRoundTrip(t *testing.T) {
+	for name, spreadsheet := range roundTripSpreadsheets(t) {
+		t.Run(name, func(t *testing.T) {
+			written, err := MakeOds(spreadsheet)
+			if err != nil {
+				t.Fatalf("MakeOds: %v", err)
+			}
+
+			read, err := ReadOds(bytes.NewReader(written.Bytes()), int64(written.Len()))
+			if err != nil {
+				t.Fatalf("ReadOds: %v", err)
+			}
+			rewritten, err := MakeOds(read)
+			if err != nil {
+				t.Fatalf("MakeOds: %v", err)
+			}
+
+			if !bytes.Equal(rewritten.Bytes(), written.Bytes()) {
+				t.Error("the package changed in a round trip")
+			}
+		})
+	}
+}
+
+func TestUnitSheetCellsRebuildsSheet(t *testing.T) {
+	spreadsheet := roundTripSpreadsheets(t)["plain"]
+	written, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	read, err := ReadFlatOds(strings.NewReader(written))
+	if err != nil {
+		t.Fatalf("ReadFlatOds: %v", err)
+	}
+
+	cells, err := SheetCells(read, "Sheet1")
+	if err != nil {
+		t.Fatalf("SheetCells: %v", err)
+	}
+	rebuilt, err := MakeSpreadsheet(cells)
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	rewritten, err := MakeFlatOds(rebuilt)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	assert(t, rewritten == written, "expected the cells of a sheet to rebuild the same document")
+
+	cells = append(cells, []Cell{MakeStyledCell("added", "string", CellStyle{BackgroundColor: ColorNavy, FontColor: ColorWhite})})
+	appended, err := MakeSpreadsheet(cells)
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	actual, err := MakeFlatOds(appended)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	assert(t, strings.Count(actual, `style:name="CUSTOM_STYLE_`) == 2, "expected an appended cell to reuse the style of an identically styled cell")
+
+	if _, err := SheetCells(read, "Sheet2"); err == nil {
+		t.Error("expected an error for a sheet that does not exist")
+	}
+}
+
+func TestUnitReadFlatOdsForeignDocument(t *testing.T) {
+	// Shaped like what LibreOffice saves: other prefixes, repeated rows and
+	// cells padding the sheet, header rows, formatted text, and styles this
+	// package does not generate.
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<o:document xmlns:o="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+  xmlns:t="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+  xmlns:x="urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+  xmlns:s="urn:oasis:names:tc:opendocument:xmlns:style:1.0"
+  xmlns:fo="urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0">
+ <o:automatic-styles>
+  <s:style s:name="ce1" s:family="table-cell" s:parent-style-name="Heading" s:data-style-name="N2">
+   <s:text-properties fo:font-weight="bold"/>
+  </s:style>
+ </o:automatic-styles>
+ <o:body>
+  <o:spreadsheet>
+   <t:table t:name="Data">
+    <t:table-column t:number-columns-repeated="1024"/>
+    <t:table-header-rows>
+     <t:table-row>
+      <t:table-cell t:style-name="ce1" o:value-type="string"><x:p>Name</x:p></t:table-cell>
+      <t:table-cell t:number-columns-repeated="1023"/>
+     </t:table-row>
+    </t:table-header-rows>
+    <t:table-row t:number-rows-repeated="2">
+     <t:table-cell o:value-type="float" o:value="1"><x:p>1</x:p></t:table-cell>
+     <t:table-cell t:number-columns-repeated="2"/>
+     <t:table-cell o:value-type="string"><x:p>a<x:s x:c="2"/><x:span>b</x:span></x:p><x:p>c</x:p></t:table-cell>
+     <t:table-cell t:number-columns-repeated="1020"/>
+    </t:table-row>
+    <t:table-row t:number-rows-repeated="1048573">
+     <t:table-cell t:number-columns-repeated="1024"/>
+    </t:table-row>
+   </t:table>
+   <t:named-expressions>
+    <t:named-range t:name="First" t:base-cell-address="$Data.$A$2" t:cell-range-address="$Data.$A$2"/>
+   </t:named-expressions>
+  </o:spreadsheet>
+ </o:body>
+</o:document>`
+
+	spreadsheet, err := ReadFlatOds(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("ReadFlatOds: %v", err)
+	}
+
+	assert(t, len(spreadsheet.Tables) == 1, fmt.Sprintf("expected one sheet, got %d", len(spreadsheet.Tables)))
+	sheet := spreadsheet.Tables[0]
+	assert(t, len(sheet.Rows) == 3, fmt.Sprintf("expected the padding rows to be dropped, got %d rows", len(sheet.Rows)))
+	for i, r := range sheet.Rows[1:] {
+		assert(t, len(r.Cells) == 4, fmt.Sprintf("row %d: expected the padding cells to be dropped, got %d cells", i+2, len(r.Cells)))
+	}
+	assert(t, sheet.Rows[2].Cells[3].Text == "a  b\nc", fmt.Sprintf("expected formatted text to be read as plain text, got %q", sheet.Rows[2].Cells[3].Text))
+	assert(t, sheet.Columns[0].NumberColumnsRepeated == "4", "expected the columns to be counted from the cells read")
+
+	assert(t, len(spreadsheet.customStyles) == 1, "expected the cell style to be kept")
+	style := spreadsheet.customStyles[0]
+	assert(t, style.ParentStyleName == defaultCellStyleName, "expected the parent style to be replaced by the default style")
+	assert(t, style.DataStyleName == "", "expected the reference to an unknown number format to be dropped")
+
+	cells, err := SheetCells(spreadsheet, "Data")
+	if err != nil {
+		t.Fatalf("SheetCells: %v", err)
+	}
+	assert(t, cells[1][0].rangeName == "First", "expected the named range to be restored on its cell")
+	assert(t, cells[0][0].style != nil && cells[0][0].style.Bold, "expected the style to be restored on its cell")
+
+	if _, err := MakeFlatOds(spreadsheet); err != nil {
+		t.Errorf("MakeFlatOds: %v", err)
+	}
+}
+
+func TestUnitReadFlatOdsHostileCounts(t *testing.T) {
+	// Counts far beyond the size of a sheet are expanded no further than
+	// its limits, and spaces no further than a cell holds.
+	document := `<?xml version="1.0" encoding="UTF-8"?>
+<office:document xmlns:office="urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+  xmlns:table="urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+  xmlns:text="urn:oasis:names:tc:opendocument:xmlns:text:1.0">
+ <office:body>
+  <office:spreadsheet>
+   <table:table table:name="Data">
+    <table:table-row>
+     <table:table-cell office:value-type="string"><text:p>a<text:s text:c="2000000000"/><text:s text:c="2000000000"/>b</text:p></table:table-cell>
+     <table:table-cell table:number-columns-repeated="2000000000"/>
+     <table:table-cell office:value-type="float" office:value="1"><text:p>1</text:p></table:table-cell>
+    </table:table-row>
+    <table:table-row table:number-rows-repeated="2000000000"/>
+    <table:table-row>
+     <table:table-cell office:value-type="float" office:value="2"><text:p>2</text:p></table:table-cell>
+    </table:table-row>
+   </table:table>
+  </office:spreadsheet>
+ </office:body>
+</office:document>`
+
+	spreadsheet, err := ReadFlatOds(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("ReadFlatOds: %v", err)
+	}
+	sheet := spreadsheet.Tables[0]
+	assert(t, len(sheet.Rows) == maxRows, fmt.Sprintf("expected %d rows, got %d", maxRows, len(sheet.Rows)))
+	assert(t, len(sheet.Rows[0].Cells) == maxColumns, fmt.Sprintf("expected %d cells, got %d", maxColumns, len(sheet.Rows[0].Cells)))
+	assert(t, len(sheet.Rows[0].Cells[0].Text) == maxSpaces+2, fmt.Sprintf("expected %d spaces, got %d characters", maxSpaces, len(sheet.Rows[0].Cells[0].Text)))
+}
+
+func TestUnitReadFlatOdsInvalidDocument(t *testing.T) {
+	if _, err := ReadFlatOds(strings.NewReader("<office:document><table:table>")); err == nil {
+		t.Error("expected an error for a truncated document")
+	}
+	if _, err := ReadOds(strings.NewReader("not a zip"), 9); err == nil {
+		t.Error("expected an error for a package that is not a zip archive")
+	}
+}
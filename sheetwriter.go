@@ -0,0 +1,238 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strconv"
+)
+
+// SheetWriter writes a zipped OpenDocument package (.ods) holding a single
+// sheet row by row, for sheets too large to be built with [MakeSpreadsheet]
+// and held in memory as a whole. Create one with [NewSheetWriter], add rows
+// with [SheetWriter.WriteRow], and finish the package with
+// [SheetWriter.Close].
+//
+// Rows are encoded into content.xml as they are written, so only the current
+// row, the generated styles, and the named ranges are held in memory. Since
+// content.xml lists its automatic styles before the sheet, all cell styles are
+// written as common styles into styles.xml, which is the last entry of the
+// package. Formulas are written without the results [MakeSpreadsheet]
+// stores in them, as the earlier rows they refer to are not kept in memory.
+type SheetWriter struct {
+	zip     *zip.Writer
+	encoder *xml.Encoder
+	builder *workbookBuilder
+	name    string
+	rows    int
+	// matrices holds the cells of the rows still to come that matrix
+	// formulas cover.
+	matrices matrixCover
+
+	// err is the first error of encoding or writing the package. Once set,
+	// the package is broken and every further call returns it.
+	err error
+}
+
+// NewSheetWriter starts a package with a single sheet named name on w. The
+// name must be a legal sheet name as described for [MakeWorkbook].
+func NewSheetWriter(w io.Writer, name string) (*SheetWriter, error) {
+	if err := validateSheetName(name); err != nil {
+		return nil, err
+	}
+
+	sw := &SheetWriter{
+		zip:     zip.NewWriter(w),
+		builder: newWorkbookBuilder(nil),
+		name:    name,
+	}
+	if err := sw.start(); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+// start writes everything up to the first row: the entries that do not depend
+// on the rows, and the beginning of content.xml.
+func (sw *SheetWriter) start() error {
+	if err := createMimetypeEntry(sw.zip); err != nil {
+		return err
+	}
+	if err := writePartEntry(sw.zip, "META-INF/manifest.xml", createManifest()); err != nil {
+		return err
+	}
+	if err := writePartEntry(sw.zip, "meta.xml", createDocumentMeta()); err != nil {
+		return err
+	}
+
+	writer, err := createPartEntry(sw.zip, "content.xml")
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(writer, xml.Header); err != nil {
+		return fmt.Errorf("writing zip entry content.xml: %w", err)
+	}
+	sw.encoder = xml.NewEncoder(writer)
+	sw.encoder.Indent("", "  ")
+
+	document := startElement("office:document-content",
+		"xmlns:office", "urn:oasis:names:tc:opendocument:xmlns:office:1.0",
+		"xmlns:table", "urn:oasis:names:tc:opendocument:xmlns:table:1.0",
+		"xmlns:text", "urn:oasis:names:tc:opendocument:xmlns:text:1.0",
+		"xmlns:style", "urn:oasis:names:tc:opendocument:xmlns:style:1.0",
+		"xmlns:fo", "urn:oasis:names:tc:opendocument:xmlns:xsl-fo-compatible:1.0",
+		"xmlns:number", "urn:oasis:names:tc:opendocument:xmlns:datastyle:1.0",
+		"xmlns:of", "urn:oasis:names:tc:opendocument:xmlns:of:1.2",
+		"office:version", odfVersion,
+	)
+	if err := sw.encoder.EncodeToken(document); err != nil {
+		return fmt.Errorf("encoding content.xml: %w", err)
+	}
+	// The table style is the only automatic style; the cell styles are
+	// common styles, see SheetWriter.
+	if err := sw.encoder.Encode(automaticStyles{Styles: []any{createTableStyle()}}); err != nil {
+		return fmt.Errorf("encoding content.xml: %w", err)
+	}
+	for _, start := range []xml.StartElement{
+		startElement("office:body"),
+		startElement("office:spreadsheet"),
+		startElement("table:table", "table:name", sw.name, "table:style-name", tableStyleName),
+	} {
+		if err := sw.encoder.EncodeToken(start); err != nil {
+			return fmt.Errorf("encoding content.xml: %w", err)
+		}
+	}
+	return nil
+}
+
+// WriteRow appends a row of cells to the sheet. It reports invalid cells,
+// duplicate range names, and matrix formulas overlapping content like
+// [MakeSpreadsheet] does. Such a row is checked before anything of it is
+// written, so it is left out as if it had never been passed, and the next
+// row takes its place. Errors of encoding or writing the package, however,
+// are final: the row cannot be taken back, so the package is left broken.
+//
+// The number of columns the sheet declares is taken from the first row.
+// Consumers show cells in later, longer rows all the same. Cells covered by
+// a matrix formula are filled in as the rows they are in are written; Close
+// adds the rows of matrix formulas reaching below the last row.
+func (sw *SheetWriter) WriteRow(cells []Cell) error {
+	if sw.err != nil {
+		return sw.err
+	}
+
+	// A row left out takes back the matrix formulas and range names it
+	// added.
+	pending := maps.Clone(sw.matrices.pending)
+	namedRanges := len(sw.builder.names.NamedRanges)
+
+	// Covering the cells of matrix formulas and styleRow change the cells,
+	// which must not show on the caller's.
+	cells, errs := sw.matrices.cover(sw.rows, slices.Clone(cells))
+	errs = append(errs, sw.builder.addRow(sw.name, sw.rows, cells)...)
+	// The sheet holds no tables, so structured references do not resolve;
+	// formulas in R1C1 notation do, as the position of the row is known.
+	errs = append(errs, sw.builder.resolveFormulaRow(sw.name, sw.rows, cells)...)
+	if len(errs) > 0 {
+		sw.matrices.pending = pending
+		sw.builder.dropNamedRanges(namedRanges)
+		return errors.Join(errs...)
+	}
+	sw.builder.styleRow(cells)
+
+	if sw.rows == 0 {
+		columns := tableColumn{NumberColumnsRepeated: strconv.Itoa(max(1, len(cells)))}
+		if err := sw.encoder.Encode(columns); err != nil {
+			sw.err = fmt.Errorf("encoding content.xml: %w", err)
+			return sw.err
+		}
+	}
+	if err := sw.encoder.Encode(row{Cells: cells}); err != nil {
+		sw.err = fmt.Errorf("encoding content.xml: %w", err)
+		return sw.err
+	}
+	sw.rows++
+	return nil
+}
+
+// Close finishes content.xml, writes styles.xml, and closes the package. It
+// does not close the underlying writer.
+func (sw *SheetWriter) Close() error {
+	if sw.err != nil {
+		return sw.err
+	}
+	sw.err = sw.finish()
+	if sw.err == nil {
+		// Further calls fail rather than writing past the end of the
+		// package.
+		sw.err = errors.New("SheetWriter is closed")
+		return nil
+	}
+	return sw.err
+}
+
+func (sw *SheetWriter) finish() error {
+	// Rows holding nothing but cells covered by matrix formulas above them.
+	for !sw.matrices.done() {
+		if err := sw.WriteRow(nil); err != nil {
+			return err
+		}
+	}
+	if sw.rows == 0 {
+		// The ODF schema requires at least one table:table-column, and a
+		// table:table-row after it.
+		if err := sw.encoder.Encode(tableColumn{NumberColumnsRepeated: "1"}); err != nil {
+			return fmt.Errorf("encoding content.xml: %w", err)
+		}
+		if err := sw.encoder.Encode(row{}); err != nil {
+			return fmt.Errorf("encoding content.xml: %w", err)
+		}
+	}
+	if err := sw.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: "table:table"}}); err != nil {
+		return fmt.Errorf("encoding content.xml: %w", err)
+	}
+	names := sw.builder.names
+	if err := sw.encoder.EncodeElement(names, startElement("table:named-expressions")); err != nil {
+		return fmt.Errorf("encoding content.xml: %w", err)
+	}
+	for _, name := range []string{"office:spreadsheet", "office:body", "office:document-content"} {
+		if err := sw.encoder.EncodeToken(xml.EndElement{Name: xml.Name{Local: name}}); err != nil {
+			return fmt.Errorf("encoding content.xml: %w", err)
+		}
+	}
+	if err := sw.encoder.Close(); err != nil {
+		return fmt.Errorf("encoding content.xml: %w", err)
+	}
+
+	styles := createCommonStyles()
+	styles.NumberStyles = createNumberStyles()
+	styles.Styles = append(styles.Styles, createStyles()...)
+	styles.Styles = append(styles.Styles, sw.builder.customStyles...)
+	if err := writePartEntry(sw.zip, "styles.xml", createDocumentStyles(styles)); err != nil {
+		return err
+	}
+
+	if err := sw.zip.Close(); err != nil {
+		return fmt.Errorf("closing zip archive: %w", err)
+	}
+	return nil
+}
+
+// startElement returns the start tag of the element name with the given
+// attribute names and values, spelled with their prefixes as the struct tags
+// of the document types are.
+func startElement(name string, attrs ...string) xml.StartElement {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	for i := 0; i+1 < len(attrs); i += 2 {
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: attrs[i]}, Value: attrs[i+1]})
+	}
+	return start
+}
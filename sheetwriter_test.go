@@ -0,0 +1,192 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func sheetWriterRows() [][]Cell {
+	return [][]Cell{
+		{MakeCell("Name", "string"), MakeCell("Amount", "string"), MakeCell("Booked", "string")},
+		{MakeRangeCell("Rent", "string", "First"), MakeStyledCell("-900", "currency", CellStyle{FontColor: ColorRed}), MakeCell("2026-01-01", "date")},
+		{MakeCell("Salary", "string"), MakeCell("3000", "currency"), MakeCell("2026-01-28", "date")},
+		{},
+		{MakeStyledCell("Total", "string", CellStyle{Bold: true}), MakeCell("SUM(B2:B3)", "formula")},
+	}
+}
+
+func writeSheet(t *testing.T, rows [][]Cell) *bytes.Buffer {
+	t.Helper()
+
+	buf := new(bytes.Buffer)
+	sw, err := NewSheetWriter(buf, "Ledger")
+	if err != nil {
+		t.Fatalf("NewSheetWriter: %v", err)
+	}
+	for i, r := range rows {
+		if err := sw.WriteRow(r); err != nil {
+			t.Fatalf("WriteRow %d: %v", i+1, err)
+		}
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	return buf
+}
+
+func TestUnitSheetWriter(t *testing.T) {
+	rows := sheetWriterRows()
+	written := writeSheet(t, rows)
+
+	streamed, err := ReadOds(bytes.NewReader(written.Bytes()), int64(written.Len()))
+	if err != nil {
+		t.Fatalf("ReadOds: %v", err)
+	}
+	spreadsheet, err := MakeSpreadsheetWithName("Ledger", sheetWriterRows())
+	if err != nil {
+		t.Fatalf("MakeSpreadsheetWithName: %v", err)
+	}
+	buff, err := MakeOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeOds: %v", err)
+	}
+	built, err := ReadOds(bytes.NewReader(buff.Bytes()), int64(buff.Len()))
+	if err != nil {
+		t.Fatalf("ReadOds: %v", err)
+	}
+
+	// The streamed sheet holds the same cells, named ranges, and styles as
+	// one built in memory; only where the styles are defined differs, and
+	// that its formulas carry no results.
+	for _, r := range built.Tables[0].Rows {
+		for i := range r.Cells {
+			if r.Cells[i].Formula != "" {
+				clearResult(&r.Cells[i])
+			}
+		}
+	}
+	assert(t, reflect.DeepEqual(streamed.Tables, built.Tables), fmt.Sprintf("expected the streamed sheet to equal the built one:\n%v\n%v", streamed.Tables, built.Tables))
+	assert(t, reflect.DeepEqual(streamed.NamedExpressions.NamedRanges, built.NamedExpressions.NamedRanges), "expected the named ranges of the built sheet")
+	assert(t, reflect.DeepEqual(streamed.customStyles, built.customStyles), "expected the generated styles of the built sheet")
+
+	for i, r := range rows {
+		for j, c := range r {
+			if strings.HasPrefix(c.StyleName, "CUSTOM_STYLE_") {
+				t.Errorf("WriteRow changed the style name of the caller's cell at row %d, column %d", i+1, j+1)
+			}
+		}
+	}
+}
+
+func TestUnitSheetWriterErrors(t *testing.T) {
+	if _, err := NewSheetWriter(io.Discard, "a/b"); err == nil {
+		t.Error("expected an error for an illegal sheet name")
+	}
+
+	written := new(bytes.Buffer)
+	sw, err := NewSheetWriter(written, "Sheet1")
+	if err != nil {
+		t.Fatalf("NewSheetWriter: %v", err)
+	}
+	if err := sw.WriteRow([]Cell{MakeRangeCell("1", "float", "Input")}); err != nil {
+		t.Fatalf("WriteRow: %v", err)
+	}
+	err = sw.WriteRow([]Cell{MakeCell("x", "float"), MakeRangeCell("2", "float", "Input"), MakeRangeCell("3", "float", "Other"), MakeMatrixCell("TRANSPOSE(A1:B1)", 2, 1)})
+	if err == nil || !strings.Contains(err.Error(), "row 2, column 1: invalid float") || !strings.Contains(err.Error(), `row 2, column 2: duplicate range name "Input"`) {
+		t.Errorf("expected both errors of the second row, got: %v", err)
+	}
+
+	// The row left out takes back its range name and matrix formula.
+	if err := sw.WriteRow([]Cell{MakeCell("4", "float"), MakeCell("5", "float"), MakeCell("6", "float"), MakeRangeCell("7", "float", "Other")}); err != nil {
+		t.Fatalf("expected the row after an invalid one to be written, got: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	spreadsheet, err := ReadOds(bytes.NewReader(written.Bytes()), int64(written.Len()))
+	if err != nil {
+		t.Fatalf("ReadOds: %v", err)
+	}
+	cells, err := SheetCells(spreadsheet, "Sheet1")
+	if err != nil {
+		t.Fatalf("SheetCells: %v", err)
+	}
+	if len(cells) != 2 || len(cells[1]) != 4 || cells[1][3].Value != "7" {
+		t.Errorf("expected the valid rows only, got: %+v", cells)
+	}
+	if names := spreadsheet.NamedExpressions.NamedRanges; len(names) != 2 || names[1].Name != "Other" || !strings.HasSuffix(names[1].CellRangeAddress, ".$D$2") {
+		t.Errorf("expected the range name of the row written, got: %+v", names)
+	}
+}
+
+func TestUnitSheetWriterEncodingErrorIsFinal(t *testing.T) {
+	sw, err := NewSheetWriter(&failingWriter{}, "Sheet1")
+	if err != nil {
+		t.Fatalf("NewSheetWriter: %v", err)
+	}
+	for range 10000 {
+		if err = sw.WriteRow([]Cell{MakeCell(strings.Repeat("x", 100), "string")}); err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = sw.Close()
+	}
+	if err == nil {
+		t.Fatal("expected an error writing to a failing writer")
+	}
+	if again := sw.WriteRow([]Cell{MakeCell("1", "float")}); again == nil {
+		t.Error("expected an error of writing the package to be final")
+	}
+}
+
+func TestSheetWriterMatchesOdfSchema(t *testing.T) {
+	for name, rows := range map[string][][]Cell{"rows": sheetWriterRows(), "empty": nil} {
+		t.Run(name, func(t *testing.T) {
+			written := writeSheet(t, rows)
+			reader, err := zip.NewReader(bytes.NewReader(written.Bytes()), int64(written.Len()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, partName := range []string{"content.xml", "styles.xml", "meta.xml"} {
+				rc, err := reader.Open(partName)
+				if err != nil {
+					t.Fatalf("package does not contain %s", partName)
+				}
+				content, err := io.ReadAll(rc)
+				if err != nil {
+					t.Fatal(err)
+				}
+				rc.Close()
+				t.Run(partName, func(t *testing.T) {
+					validateAgainstSchema(t, partName, string(content))
+				})
+			}
+		})
+	}
+}
+
+func TestSheetWriter(t *testing.T) {
+	written := writeSheet(t, sheetWriterRows())
+
+	expectedThisCsv := make(map[string][][]string)
+	expectedThisCsv["en_US.UTF-8"] = [][]string{
+		{"Name", "Amount", "Booked"},
+		{"Rent", "−900.00€", "2026-01-01"},
+		{"Salary", "3000.00€", "2026-01-28"},
+		{"", "", ""},
+		{"Total", "2100", ""},
+	}
+	expectedThisCsv["de_DE.UTF-8"] = expectedThisCsv["en_US.UTF-8"]
+
+	renderFileAndCompare(t, "sheet-writer", "ods", written.Bytes(), expectedThisCsv)
+}
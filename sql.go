@@ -0,0 +1,262 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"cmp"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SQLOptions configures how [ReadSQLRows] and [WriteSQLRows] turn the
+// columns of a result set into cells.
+type SQLOptions struct {
+	// Decimal is the value type of DECIMAL and NUMERIC columns. Defaults to
+	// "float"; a currency type such as "currency-eur" suits amounts of
+	// money.
+	Decimal string
+	// Types gives the value types of [MakeCell] for columns, by name,
+	// overriding those chosen by their database types.
+	Types map[string]string
+}
+
+// ReadSQLRows reads a result set into rows of cells, ready for
+// [MakeSpreadsheet] or [MakeTable]: a header row of the column names, and a
+// row of cells per row of the result. The value types of the columns are
+// chosen by their database types, as reported by [sql.Rows.ColumnTypes]:
+//
+//   - DECIMAL, NUMERIC, and MONEY: opts.Decimal, "float" by default
+//   - integer and floating-point types: "float"
+//   - DATE: "date"; TIMESTAMP and DATETIME: "datetime"; TIME: "time"
+//   - BOOL and BOOLEAN: "boolean"
+//
+// Columns of other database types are chosen by the Go type the driver
+// scans them into, and hold strings if that is no number, time, or
+// boolean. NULL becomes an empty cell. Values are converted without a
+// detour through floats where the driver gives them as text, so that
+// decimals keep all their digits.
+//
+// ReadSQLRows reads rows to the end, but leaves closing them to the caller.
+// Values that do not fit the value type of their column are reported
+// together as a single joined error, with their row and column; errors of
+// the result set are returned as they are. To write a result set too large
+// to be held in memory, use [WriteSQLRows].
+func ReadSQLRows(rows *sql.Rows, opts SQLOptions) ([][]Cell, error) {
+	var cells [][]Cell
+	var errs []error
+	err := scanSQLRows(rows, opts, func(row []Cell) error {
+		errs = append(errs, sqlRowErrors(len(cells), row)...)
+		cells = append(cells, row)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return cells, nil
+}
+
+// WriteSQLRows writes a result set to sw row by row, as [ReadSQLRows] would
+// read it, so that only the current row is held in memory. Values that do
+// not fit the value type of their column are written as empty cells, so
+// that every row of the result set is a row of the sheet; the values are
+// reported once the result set is read to the end, as a single joined error with the row of the
+// result set, counting the header, and the column of each, so that sw can
+// still be closed. Errors of the result set and of writing the package stop
+// it, the latter leaving the package of sw broken, as described for
+// [SheetWriter.WriteRow]. Closing the rows and sw is left to the caller.
+func WriteSQLRows(sw *SheetWriter, rows *sql.Rows, opts SQLOptions) error {
+	var errs []error
+	read := 0
+	err := scanSQLRows(rows, opts, func(row []Cell) error {
+		rowErrs := sqlRowErrors(read, row)
+		read++
+		if len(rowErrs) > 0 {
+			errs = append(errs, rowErrs...)
+			// The values that do not fit are left empty, so that the rows
+			// of the sheet stay those of the result set.
+			for c := range row {
+				if row[c].err != nil {
+					row[c] = Cell{}
+				}
+			}
+		}
+		return sw.WriteRow(row)
+	})
+	return errors.Join(append(errs, err)...)
+}
+
+// sqlRowErrors returns the errors of the values of the rowIdx-th row read
+// from a result set that do not fit their column.
+func sqlRowErrors(rowIdx int, row []Cell) []error {
+	var errs []error
+	for c, cell := range row {
+		if cell.err != nil {
+			errs = append(errs, fmt.Errorf("row %d, column %d: %w", rowIdx+1, c+1, cell.err))
+		}
+	}
+	return errs
+}
+
+// scanSQLRows passes the header and then each row of a result set to emit,
+// as cells, stopping at the first error.
+func scanSQLRows(rows *sql.Rows, opts SQLOptions, emit func([]Cell) error) error {
+	columnTypes, err := rows.ColumnTypes()
+	if err != nil {
+		return err
+	}
+	decimal := cmp.Or(opts.Decimal, "float")
+	if _, ok := valueKind(decimal); !ok {
+		return fmt.Errorf("unknown value type %q for decimals", decimal)
+	}
+
+	header := make([]Cell, len(columnTypes))
+	valueTypes := make([]string, len(columnTypes))
+	var errs []error
+	for i, ct := range columnTypes {
+		header[i] = MakeCell(ct.Name(), "string")
+		valueTypes[i] = sqlValueType(ct, decimal)
+		if valueType, ok := opts.Types[ct.Name()]; ok {
+			if _, known := valueKind(valueType); !known {
+				errs = append(errs, fmt.Errorf("unknown value type %q for column %q", valueType, ct.Name()))
+			}
+			valueTypes[i] = valueType
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	if err := emit(header); err != nil {
+		return err
+	}
+
+	values := make([]any, len(columnTypes))
+	destinations := make([]any, len(columnTypes))
+	for i := range values {
+		destinations[i] = &values[i]
+	}
+	for rows.Next() {
+		if err := rows.Scan(destinations...); err != nil {
+			return err
+		}
+		row := make([]Cell, len(values))
+		for i, v := range values {
+			row[i] = sqlCell(v, valueTypes[i])
+		}
+		if err := emit(row); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// sqlValueType chooses the value type of a column by its database type, or
+// else by the Go type it is scanned into.
+func sqlValueType(ct *sql.ColumnType, decimal string) string {
+	name, _, _ := strings.Cut(strings.ToUpper(ct.DatabaseTypeName()), "(")
+	switch strings.TrimSpace(name) {
+	case "DECIMAL", "NUMERIC", "NUMBER", "MONEY":
+		return decimal
+	case "INT", "INTEGER", "SMALLINT", "BIGINT", "TINYINT", "MEDIUMINT", "INT2", "INT4", "INT8",
+		"SERIAL", "BIGSERIAL", "FLOAT", "FLOAT4", "FLOAT8", "REAL", "DOUBLE", "DOUBLE PRECISION":
+		return "float"
+	case "DATE":
+		return "date"
+	case "TIMESTAMP", "TIMESTAMPTZ", "DATETIME", "DATETIME2", "TIMESTAMP WITH TIME ZONE", "TIMESTAMP WITHOUT TIME ZONE":
+		return "datetime"
+	case "TIME", "TIME WITHOUT TIME ZONE":
+		return "time"
+	case "BOOL", "BOOLEAN":
+		return "boolean"
+	}
+
+	t := ct.ScanType()
+	if t == nil {
+		return "string"
+	}
+	switch t {
+	case reflect.TypeFor[sql.NullInt64](), reflect.TypeFor[sql.NullInt32](), reflect.TypeFor[sql.NullInt16](),
+		reflect.TypeFor[sql.NullByte](), reflect.TypeFor[sql.NullFloat64]():
+		return "float"
+	case reflect.TypeFor[sql.NullBool]():
+		return "boolean"
+	case timeType, reflect.TypeFor[sql.NullTime]():
+		return "datetime"
+	}
+	switch {
+	case isNumberKind(t.Kind()):
+		return "float"
+	case t.Kind() == reflect.Bool:
+		return "boolean"
+	}
+	return "string"
+}
+
+// sqlCell makes the cell of a value scanned from a column of valueType.
+// Values the typed constructors take are passed to them; all others are
+// given to [MakeCell] as text, which reports those that do not fit.
+func sqlCell(v any, valueType string) Cell {
+	switch v := v.(type) {
+	case nil:
+		return Cell{}
+	case time.Time:
+		switch valueType {
+		case "date":
+			return MakeDateCell(v)
+		case "datetime":
+			return MakeDateTimeCell(v)
+		case "time":
+			return MakeCell(v.Format("15:04:05"), "time")
+		}
+	case bool:
+		if valueType == "boolean" {
+			return MakeBoolCell(v)
+		}
+	case int64:
+		if valueType == "boolean" {
+			return MakeBoolCell(v != 0)
+		}
+	case float64:
+		if cell := MakeFloatCell(v); cell.err != nil {
+			return cell
+		}
+	case []byte:
+		return sqlCell(string(v), valueType)
+	case string:
+		if b, err := strconv.ParseBool(v); valueType == "boolean" && err == nil {
+			// Drivers give booleans as "t", "1", and so on.
+			return MakeBoolCell(b)
+		}
+		if _, err := datetimeString(v); valueType == "date" && err == nil {
+			// Drivers give dates as timestamps at midnight.
+			return MakeCell(v[:len("2006-01-02")], "date")
+		}
+	}
+	return MakeCell(sqlText(v), valueType)
+}
+
+// sqlText returns the text of a value scanned from a column.
+func sqlText(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	}
+	return fmt.Sprint(v)
+}
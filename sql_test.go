@@ -0,0 +1,254 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"bytes"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The stand-in driver answers two queries: "ledger", a fixed result set of
+// every kind of column, and "count <n>", which generates n rows as they are
+// read.
+func init() {
+	sql.Register("ods-test", testDriver{})
+}
+
+type testDriver struct{}
+
+func (testDriver) Open(string) (driver.Conn, error) { return testConn{}, nil }
+
+type testConn struct{}
+
+func (testConn) Prepare(query string) (driver.Stmt, error) { return testStmt{query}, nil }
+func (testConn) Close() error                              { return nil }
+func (testConn) Begin() (driver.Tx, error)                 { return nil, errors.New("transactions are not supported") }
+
+type testStmt struct{ query string }
+
+func (testStmt) Close() error  { return nil }
+func (testStmt) NumInput() int { return 0 }
+func (testStmt) Exec([]driver.Value) (driver.Result, error) {
+	return nil, errors.New("exec is not supported")
+}
+
+func (s testStmt) Query([]driver.Value) (driver.Rows, error) {
+	if n, ok := strings.CutPrefix(s.query, "count "); ok {
+		count, err := strconv.Atoi(n)
+		if err != nil {
+			return nil, err
+		}
+		return &testRows{
+			columns: []testColumn{{"n", "BIGINT", reflect.TypeFor[int64]()}, {"label", "TEXT", reflect.TypeFor[string]()}},
+			generate: func(i int) []driver.Value {
+				if i >= count {
+					return nil
+				}
+				return []driver.Value{int64(i + 1), fmt.Sprintf("row %d", i+1)}
+			},
+		}, nil
+	}
+	if s.query != "ledger" {
+		return nil, fmt.Errorf("unknown query %q", s.query)
+	}
+	rows := [][]driver.Value{
+		{int64(1), []byte("-1234.56"), time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), time.Date(2026, 3, 1, 14, 30, 15, 0, time.UTC), true, "Rent", 0.25, "08:15:00"},
+		{int64(2), []byte("300.10"), "2026-03-05 00:00:00", "2026-03-05T09:00:00Z", int64(0), []byte("Salary"), nil, nil},
+		{int64(3), nil, nil, nil, "t", nil, 1.5, nil},
+	}
+	return &testRows{
+		columns: []testColumn{
+			{"id", "INTEGER", reflect.TypeFor[int64]()},
+			{"amount", "DECIMAL(10,2)", reflect.TypeFor[[]byte]()},
+			{"booked", "DATE", reflect.TypeFor[time.Time]()},
+			{"created", "TIMESTAMP", reflect.TypeFor[time.Time]()},
+			{"cleared", "BOOL", reflect.TypeFor[bool]()},
+			{"purpose", "VARCHAR(100)", reflect.TypeFor[string]()},
+			{"rate", "", reflect.TypeFor[sql.NullFloat64]()},
+			{"opens", "TIME", reflect.TypeFor[string]()},
+		},
+		generate: func(i int) []driver.Value {
+			if i >= len(rows) {
+				return nil
+			}
+			return rows[i]
+		},
+	}, nil
+}
+
+type testColumn struct {
+	name, databaseType string
+	scanType           reflect.Type
+}
+
+type testRows struct {
+	columns  []testColumn
+	generate func(i int) []driver.Value
+	next     int
+}
+
+func (r *testRows) Columns() []string {
+	names := make([]string, len(r.columns))
+	for i, c := range r.columns {
+		names[i] = c.name
+	}
+	return names
+}
+
+func (r *testRows) Close() error { return nil }
+
+func (r *testRows) Next(dest []driver.Value) error {
+	values := r.generate(r.next)
+	if values == nil {
+		return io.EOF
+	}
+	r.next++
+	copy(dest, values)
+	return nil
+}
+
+func (r *testRows) ColumnTypeDatabaseTypeName(i int) string { return r.columns[i].databaseType }
+func (r *testRows) ColumnTypeScanType(i int) reflect.Type   { return r.columns[i].scanType }
+
+func queryTestDB(t *testing.T, query string) *sql.Rows {
+	t.Helper()
+
+	db, err := sql.Open("ods-test", "")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("Query: %v", err)
+	}
+	t.Cleanup(func() { rows.Close() })
+	return rows
+}
+
+func TestUnitReadSQLRows(t *testing.T) {
+	cells, err := ReadSQLRows(queryTestDB(t, "ledger"), SQLOptions{Decimal: "currency-eur"})
+	if err != nil {
+		t.Fatalf("ReadSQLRows: %v", err)
+	}
+
+	header := make([]string, len(cells[0]))
+	for i, c := range cells[0] {
+		header[i] = c.Text
+	}
+	assert(t, strings.Join(header, "|") == "id|amount|booked|created|cleared|purpose|rate|opens", "unexpected header "+strings.Join(header, "|"))
+	expected := [][]Cell{
+		{MakeIntCell(1), MakeCell("-1234.56", "currency-eur"), MakeCell("2026-03-01", "date"), MakeCell("2026-03-01T14:30:15", "datetime"), MakeBoolCell(true), MakeCell("Rent", "string"), MakeFloatCell(0.25), MakeCell("08:15:00", "time")},
+		{MakeIntCell(2), MakeCell("300.10", "currency-eur"), MakeCell("2026-03-05", "date"), MakeCell("2026-03-05T09:00:00", "datetime"), MakeBoolCell(false), MakeCell("Salary", "string"), {}, {}},
+		{MakeIntCell(3), {}, {}, {}, MakeBoolCell(true), {}, MakeFloatCell(1.5), {}},
+	}
+	assert(t, len(cells) == len(expected)+1, fmt.Sprintf("expected a header and %d rows, got %d rows", len(expected), len(cells)))
+	for r, row := range expected {
+		for c, e := range row {
+			assert(t, cells[r+1][c] == e, fmt.Sprintf("row %d, column %d: expected %+v, got %+v", r+2, c+1, e, cells[r+1][c]))
+		}
+	}
+
+	spreadsheet, err := MakeTable(cells, TableOptions{Header: true, Totals: []Total{{TotalCount}, {TotalSum}}})
+	if err != nil {
+		t.Fatalf("MakeTable: %v", err)
+	}
+	total := spreadsheet.Tables[0].Rows[4].Cells[1]
+	assert(t, total.Value == "-934.46", fmt.Sprintf("expected the amounts to be summed, got %q", total.Value))
+}
+
+func TestUnitReadSQLRowsErrors(t *testing.T) {
+	_, err := ReadSQLRows(queryTestDB(t, "ledger"), SQLOptions{Types: map[string]string{"purpose": "float", "id": "date"}})
+	for _, expected := range []string{
+		`row 2, column 1: invalid date "1"`,
+		`row 2, column 6: invalid float value "Rent"`,
+		`row 3, column 6: invalid float value "Salary"`,
+	} {
+		assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+	}
+
+	_, err = ReadSQLRows(queryTestDB(t, "ledger"), SQLOptions{Types: map[string]string{"purpose": "text"}})
+	assert(t, err != nil && strings.Contains(err.Error(), `unknown value type "text" for column "purpose"`), fmt.Sprintf("expected the unknown value type, got: %v", err))
+	_, err = ReadSQLRows(queryTestDB(t, "ledger"), SQLOptions{Decimal: "money"})
+	assert(t, err != nil && strings.Contains(err.Error(), `unknown value type "money" for decimals`), fmt.Sprintf("expected the unknown value type, got: %v", err))
+}
+
+func TestUnitWriteSQLRows(t *testing.T) {
+	const count = 5000
+	buf := new(bytes.Buffer)
+	sw, err := NewSheetWriter(buf, "Numbers")
+	if err != nil {
+		t.Fatalf("NewSheetWriter: %v", err)
+	}
+	if err := WriteSQLRows(sw, queryTestDB(t, fmt.Sprintf("count %d", count)), SQLOptions{}); err != nil {
+		t.Fatalf("WriteSQLRows: %v", err)
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	spreadsheet, err := ReadOds(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("ReadOds: %v", err)
+	}
+	rows := spreadsheet.Tables[0].Rows
+	assert(t, len(rows) == count+1, fmt.Sprintf("expected a header and %d rows, got %d rows", count, len(rows)))
+	last := rows[count].Cells
+	assert(t, last[0].Value == strconv.Itoa(count) && last[1].Text == fmt.Sprintf("row %d", count), fmt.Sprintf("unexpected last row %+v", last))
+}
+
+func TestUnitWriteSQLRowsErrors(t *testing.T) {
+	buf := new(bytes.Buffer)
+	sw, err := NewSheetWriter(buf, "Ledger")
+	if err != nil {
+		t.Fatalf("NewSheetWriter: %v", err)
+	}
+	err = WriteSQLRows(sw, queryTestDB(t, "ledger"), SQLOptions{Types: map[string]string{"purpose": "float"}})
+	for _, expected := range []string{
+		`row 2, column 6: invalid float value "Rent"`,
+		`row 3, column 6: invalid float value "Salary"`,
+	} {
+		assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+	}
+	if err := sw.Close(); err != nil {
+		t.Fatalf("expected the package to stay intact, got: %v", err)
+	}
+
+	spreadsheet, err := ReadOds(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("ReadOds: %v", err)
+	}
+	rows := spreadsheet.Tables[0].Rows
+	assert(t, len(rows) == 4 && rows[1].Cells[0].Value == "1" && rows[3].Cells[0].Value == "3", fmt.Sprintf("expected the header and every row, got %+v", rows))
+	for _, r := range rows[1:3] {
+		assert(t, len(r.Cells) < 6 || r.Cells[5].ValueType == "" && r.Cells[5].Text == "", fmt.Sprintf("expected the purpose that does not fit to be empty, got %+v", r.Cells))
+	}
+}
+
+func TestReadSQLRowsMatchesOdfSchema(t *testing.T) {
+	cells, err := ReadSQLRows(queryTestDB(t, "ledger"), SQLOptions{Decimal: "currency-eur"})
+	if err != nil {
+		t.Fatalf("ReadSQLRows: %v", err)
+	}
+	spreadsheet, err := MakeTable(cells, TableOptions{Header: true, AutoFilter: true})
+	if err != nil {
+		t.Fatalf("MakeTable: %v", err)
+	}
+
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	validateAgainstSchema(t, "flat.fods", flatOds)
+}
@@ -0,0 +1,252 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package statement
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// The elements of a CAMT.053 document the parser reads. Their names carry
+// no namespace, so that they match those of every version of the message,
+// from camt.053.001.02 on.
+type camtDocument struct {
+	Statements []camtStatement `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtStatement struct {
+	Account struct {
+		IBAN  string `xml:"Id>IBAN"`
+		Other string `xml:"Id>Othr>Id"`
+		Ccy   string `xml:"Ccy"`
+	} `xml:"Acct"`
+	Balances []camtBalance `xml:"Bal"`
+	Entries  []camtEntry   `xml:"Ntry"`
+}
+
+type camtBalance struct {
+	Code      string     `xml:"Tp>CdOrPrtry>Cd"`
+	Amount    camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	Date      camtDate   `xml:"Dt"`
+}
+
+type camtAmount struct {
+	Value    string `xml:",chardata"`
+	Currency string `xml:"Ccy,attr"`
+}
+
+// camtDate is a date given as a date or as a date and time.
+type camtDate struct {
+	Dt   string `xml:"Dt"`
+	DtTm string `xml:"DtTm"`
+}
+
+type camtEntry struct {
+	Amount    camtAmount `xml:"Amt"`
+	CdtDbtInd string     `xml:"CdtDbtInd"`
+	// Status is a code in camt.053.001.02 and an element holding one later.
+	Status struct {
+		Text string `xml:",chardata"`
+		Cd   string `xml:"Cd"`
+	} `xml:"Sts"`
+	BookingDate camtDate        `xml:"BookgDt"`
+	ValueDate   camtDate        `xml:"ValDt"`
+	Reference   string          `xml:"AcctSvcrRef"`
+	Details     []camtTxDetails `xml:"NtryDtls>TxDtls"`
+	Info        string          `xml:"AddtlNtryInf"`
+}
+
+type camtTxDetails struct {
+	Debtor        camtParty `xml:"RltdPties>Dbtr"`
+	Creditor      camtParty `xml:"RltdPties>Cdtr"`
+	Unstructured  []string  `xml:"RmtInf>Ustrd"`
+	AdditionalInf string    `xml:"AddtlTxInf"`
+}
+
+// camtParty is a party, whose name is nested in a Pty element from
+// camt.053.001.08 on.
+type camtParty struct {
+	Name      string `xml:"Nm"`
+	PartyName string `xml:"Pty>Nm"`
+}
+
+// ParseCAMT053 parses the statements of an ISO 20022 CAMT.053 document
+// (BankToCustomerStatement) of any version. Only booked entries are taken;
+// pending and informational ones, whose status is not BOOK, are left out,
+// and entries without a value date (ValDt) keep a zero [Entry.ValueDate].
+// The opening balance is the opening booked balance (OPBD) or, failing
+// that, the previous closing balance (PRCD), and the closing balance the
+// closing booked one (CLBD).
+//
+// ParseCAMT053 reports malformed documents, and statements whose entries do
+// not add up to the difference between their balances, as a single joined
+// error with the number of each statement and entry.
+func ParseCAMT053(r io.Reader) ([]Statement, error) {
+	var document camtDocument
+	if err := xml.NewDecoder(r).Decode(&document); err != nil {
+		return nil, fmt.Errorf("parsing CAMT.053: %w", err)
+	}
+	if len(document.Statements) == 0 {
+		return nil, errNoStatement
+	}
+
+	statements := make([]Statement, len(document.Statements))
+	var errs []error
+	for i, cs := range document.Statements {
+		s, err := cs.statement()
+		if err == nil {
+			err = checkBalances(s)
+		}
+		if err != nil {
+			errs = append(errs, prefixErrors(fmt.Sprintf("statement %d", i+1), err)...)
+		}
+		statements[i] = s
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return statements, nil
+}
+
+func (cs camtStatement) statement() (Statement, error) {
+	s := Statement{Account: strings.TrimSpace(cs.Account.IBAN), Currency: cs.Account.Ccy}
+	if s.Account == "" {
+		s.Account = strings.TrimSpace(cs.Account.Other)
+	}
+
+	var errs []error
+	var opening *camtBalance
+	for i, b := range cs.Balances {
+		switch b.Code {
+		case "OPBD":
+			opening = &cs.Balances[i]
+		case "PRCD":
+			if opening == nil {
+				opening = &cs.Balances[i]
+			}
+		case "CLBD":
+			balance, err := camtSigned(b.Amount.Value, b.CdtDbtInd)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("closing balance: %w", err))
+			}
+			s.ClosingBalance = balance
+			if s.Currency == "" {
+				s.Currency = b.Amount.Currency
+			}
+		}
+	}
+	if opening != nil {
+		balance, err := camtSigned(opening.Amount.Value, opening.CdtDbtInd)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("opening balance: %w", err))
+		}
+		s.OpeningBalance = balance
+		if s.OpeningDate, err = opening.Date.time(); err != nil {
+			errs = append(errs, fmt.Errorf("opening balance: %w", err))
+		}
+		if s.Currency == "" {
+			s.Currency = opening.Amount.Currency
+		}
+	}
+
+	for i, ce := range cs.Entries {
+		status := strings.TrimSpace(ce.Status.Text)
+		if ce.Status.Cd != "" {
+			status = ce.Status.Cd
+		}
+		if status != "" && status != "BOOK" {
+			continue
+		}
+		e, err := ce.entry()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("entry %d: %w", i+1, err))
+			continue
+		}
+		if s.Currency == "" {
+			s.Currency = ce.Amount.Currency
+		}
+		s.Entries = append(s.Entries, e)
+	}
+	return s, errors.Join(errs...)
+}
+
+func (ce camtEntry) entry() (Entry, error) {
+	amount, err := camtSigned(ce.Amount.Value, ce.CdtDbtInd)
+	if err != nil {
+		return Entry{}, err
+	}
+	e := Entry{Amount: amount, Reference: strings.TrimSpace(ce.Reference)}
+	if e.BookingDate, err = ce.BookingDate.time(); err != nil {
+		return Entry{}, fmt.Errorf("booking date: %w", err)
+	}
+	// The value date is optional and left zero if it is missing.
+	if ce.ValueDate.given() {
+		if e.ValueDate, err = ce.ValueDate.time(); err != nil {
+			return Entry{}, fmt.Errorf("value date: %w", err)
+		}
+	}
+
+	var purposes []string
+	for _, d := range ce.Details {
+		party := d.Debtor
+		if ce.CdtDbtInd == "DBIT" {
+			party = d.Creditor
+		}
+		if e.Counterparty == "" {
+			e.Counterparty = strings.TrimSpace(party.Name + party.PartyName)
+		}
+		for _, u := range d.Unstructured {
+			purposes = append(purposes, strings.TrimSpace(u))
+		}
+		if len(d.Unstructured) == 0 && d.AdditionalInf != "" {
+			purposes = append(purposes, strings.TrimSpace(d.AdditionalInf))
+		}
+	}
+	e.Purpose = strings.Join(purposes, " ")
+	if e.Purpose == "" {
+		e.Purpose = strings.TrimSpace(ce.Info)
+	}
+	return e, nil
+}
+
+// given reports whether d holds a date or a date and time.
+func (d camtDate) given() bool {
+	return strings.TrimSpace(d.Dt) != "" || strings.TrimSpace(d.DtTm) != ""
+}
+
+// time returns the date of d, dropping the time of day of a DtTm.
+func (d camtDate) time() (time.Time, error) {
+	value := strings.TrimSpace(d.Dt)
+	if value == "" {
+		value, _, _ = strings.Cut(strings.TrimSpace(d.DtTm), "T")
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", value)
+	}
+	return t, nil
+}
+
+// camtSigned returns an amount of a CAMT.053 document, which is never
+// negative, with the sign of its credit or debit indicator.
+func camtSigned(amount, cdtDbtInd string) (string, error) {
+	amount = strings.TrimSpace(amount)
+	if _, ok := new(big.Rat).SetString(amount); !ok || strings.HasPrefix(amount, "-") {
+		return "", fmt.Errorf("invalid amount %q", amount)
+	}
+	switch cdtDbtInd {
+	case "CRDT":
+		return amount, nil
+	case "DBIT":
+		return "-" + amount, nil
+	}
+	return "", fmt.Errorf("invalid credit or debit indicator %q, expected CRDT or DBIT", cdtDbtInd)
+}
@@ -0,0 +1,168 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package statement
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// camtMarch is a camt.053.001.08 statement of March 2025, holding a pending
+// entry that is not booked yet.
+const camtMarch = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.08">
+  <BkToCstmrStmt>
+    <GrpHdr><MsgId>0001</MsgId><CreDtTm>2025-04-01T06:00:00</CreDtTm></GrpHdr>
+    <Stmt>
+      <Id>2025-03</Id>
+      <Acct><Id><IBAN>DE02120300000000202051</IBAN></Id><Ccy>EUR</Ccy></Acct>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>OPBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">1000.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2025-02-28</Dt></Dt>
+      </Bal>
+      <Bal>
+        <Tp><CdOrPrtry><Cd>CLBD</Cd></CdOrPrtry></Tp>
+        <Amt Ccy="EUR">2487.50</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Dt><Dt>2025-03-31</Dt></Dt>
+      </Bal>
+      <Ntry>
+        <Amt Ccy="EUR">12.50</Amt><CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><Dt>2025-03-03</Dt></BookgDt>
+        <ValDt><Dt>2025-03-01</Dt></ValDt>
+        <AcctSvcrRef>REF-1</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RltdPties><Cdtr><Pty><Nm>Cafe am Markt</Nm></Pty></Cdtr></RltdPties>
+          <RmtInf><Ustrd>Coffee</Ustrd><Ustrd>and cake</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">1500.00</Amt><CdtDbtInd>CRDT</CdtDbtInd>
+        <Sts><Cd>BOOK</Cd></Sts>
+        <BookgDt><DtTm>2025-03-01T09:30:00</DtTm></BookgDt>
+        <ValDt><Dt>2025-03-01</Dt></ValDt>
+        <AcctSvcrRef>REF-2</AcctSvcrRef>
+        <NtryDtls><TxDtls>
+          <RltdPties><Dbtr><Pty><Nm>ACME GmbH</Nm></Pty></Dbtr></RltdPties>
+          <RmtInf><Ustrd>Salary March</Ustrd></RmtInf>
+        </TxDtls></NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="EUR">99.00</Amt><CdtDbtInd>DBIT</CdtDbtInd>
+        <Sts><Cd>PDNG</Cd></Sts>
+        <BookgDt><Dt>2025-03-31</Dt></BookgDt>
+        <ValDt><Dt>2025-04-01</Dt></ValDt>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestUnitParseCAMT053(t *testing.T) {
+	statements, err := ParseCAMT053(strings.NewReader(camtMarch))
+	if err != nil {
+		t.Fatalf("ParseCAMT053: %v", err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("got %d statements, want 1", len(statements))
+	}
+	s := statements[0]
+	if s.Account != "DE02120300000000202051" || s.Currency != "EUR" {
+		t.Errorf("got account %q in %q", s.Account, s.Currency)
+	}
+	if s.OpeningBalance != "1000.00" || s.ClosingBalance != "2487.50" || !s.OpeningDate.Equal(date(2025, 2, 28)) {
+		t.Errorf("got balances %q on %v and %q", s.OpeningBalance, s.OpeningDate, s.ClosingBalance)
+	}
+	want := []Entry{
+		{date(2025, 3, 3), date(2025, 3, 1), "Cafe am Markt", "Coffee and cake", "-12.50", "REF-1"},
+		{date(2025, 3, 1), date(2025, 3, 1), "ACME GmbH", "Salary March", "1500.00", "REF-2"},
+	}
+	if len(s.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(s.Entries), len(want))
+	}
+	for i, e := range s.Entries {
+		if e != want[i] {
+			t.Errorf("entry %d: got %+v, want %+v", i+1, e, want[i])
+		}
+	}
+}
+
+func TestUnitParseCAMT053Version2(t *testing.T) {
+	// camt.053.001.02 gives the status as text and names parties directly.
+	const document = `<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02"><BkToCstmrStmt><Stmt>
+<Acct><Id><Othr><Id>4711</Id></Othr></Id></Acct>
+<Ntry><Amt Ccy="CHF">20</Amt><CdtDbtInd>CRDT</CdtDbtInd><Sts>BOOK</Sts>
+<BookgDt><Dt>2025-01-02</Dt></BookgDt><ValDt><Dt>2025-01-02</Dt></ValDt>
+<NtryDtls><TxDtls><RltdPties><Dbtr><Nm>Jane Doe</Nm></Dbtr></RltdPties></TxDtls></NtryDtls>
+<AddtlNtryInf>Gift</AddtlNtryInf></Ntry>
+</Stmt></BkToCstmrStmt></Document>`
+	statements, err := ParseCAMT053(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("ParseCAMT053: %v", err)
+	}
+	s := statements[0]
+	want := Entry{date(2025, 1, 2), date(2025, 1, 2), "Jane Doe", "Gift", "20", ""}
+	if s.Account != "4711" || s.Currency != "CHF" || len(s.Entries) != 1 || s.Entries[0] != want {
+		t.Errorf("got %+v", s)
+	}
+}
+
+func TestUnitParseCAMT053WithoutValueDate(t *testing.T) {
+	document := strings.Replace(camtMarch, "<ValDt><Dt>2025-03-01</Dt></ValDt>", "", 1)
+	statements, err := ParseCAMT053(strings.NewReader(document))
+	if err != nil {
+		t.Fatalf("ParseCAMT053: %v", err)
+	}
+	e := statements[0].Entries[0]
+	if !e.BookingDate.Equal(date(2025, 3, 3)) || !e.ValueDate.IsZero() {
+		t.Errorf("got booking date %v and value date %v, want a zero value date", e.BookingDate, e.ValueDate)
+	}
+	cells := Ledger(statements[0])
+	if got := cells[3][1]; got.ValueType != "" || got.DateValue != "" {
+		t.Errorf("got value date cell %+v, want an empty cell", got)
+	}
+}
+
+func TestUnitParseCAMT053Errors(t *testing.T) {
+	tests := map[string]struct {
+		document string
+		want     []string
+	}{
+		"not xml":      {"camt", []string{"parsing CAMT.053"}},
+		"no statement": {"<Document><BkToCstmrStmt/></Document>", []string{"no statement found"}},
+		"entries": {
+			`<Document><BkToCstmrStmt><Stmt>
+<Ntry><Amt>1.00</Amt><CdtDbtInd>CREDIT</CdtDbtInd></Ntry>
+<Ntry><Amt>1.00</Amt><CdtDbtInd>CRDT</CdtDbtInd><BookgDt><Dt>01.02.2025</Dt></BookgDt></Ntry>
+</Stmt></BkToCstmrStmt></Document>`,
+			[]string{
+				`statement 1: entry 1: invalid credit or debit indicator "CREDIT"`,
+				`statement 1: entry 2: booking date: invalid date "01.02.2025"`,
+			},
+		},
+		"balances": {
+			strings.Replace(camtMarch, "2487.50", "2488.50", 1),
+			[]string{"statement 1: closing balance 2488.50 does not match the opening balance 1000.00 and the entries, which add up to 2487.50"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseCAMT053(strings.NewReader(test.document))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
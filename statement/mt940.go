@@ -0,0 +1,239 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package statement
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	// mt940Tag matches the start of a field, such as ":61:" or ":60F:".
+	mt940Tag = regexp.MustCompile(`^:(\d\d[A-Z]?):`)
+	// mt940Balance matches the fields of balances: the debit or credit mark,
+	// the date, the currency, and the amount.
+	mt940Balance = regexp.MustCompile(`^([CD])(\d{6})([A-Z]{3})(\d+,\d*)$`)
+	// mt940Line matches a statement line: the value date, the booking date,
+	// the debit or credit mark, the funds code, the amount, the transaction
+	// type, the customer reference, and the reference of the bank.
+	mt940Line = regexp.MustCompile(`^(\d{6})(\d{4})?(RC|RD|C|D)([A-Z])?(\d+,\d*)([NFS][A-Z0-9]{3})([^/]*)(?://(.*))?$`)
+	// mt940Structured matches information to the account owner that is
+	// divided into subfields, as German banks do.
+	mt940Structured = regexp.MustCompile(`^\d{3}\?`)
+)
+
+// mt940Field is a field of an MT940 message and the line it starts on.
+type mt940Field struct {
+	tag, value string
+	line       int
+}
+
+// ParseMT940 parses the statements of a SWIFT MT940 file, as banks export
+// them. Each message, starting with its transaction reference (:20:), is a
+// statement; the pages of a statement that continues over several messages
+// can be combined with [Merge]. Lines of SWIFT headers and trailers are
+// ignored.
+//
+// The purpose and counterparty of an entry are read from the subfields of
+// its information to the account owner (:86:) where these are structured,
+// as by German banks: ?20 to ?29 and ?60 to ?63 are the purpose, and ?32 and
+// ?33 the name of the counterparty. Otherwise, the whole information is the
+// purpose.
+//
+// ParseMT940 reports malformed fields, and statements whose entries do not
+// add up to the difference between their balances, as a single joined
+// error with the number of each statement and the line of each field.
+func ParseMT940(r io.Reader) ([]Statement, error) {
+	var messages [][]mt940Field
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimRight(scanner.Text(), " \r")
+		if line == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		if strings.HasPrefix(text, "{") || text == "-" || strings.HasPrefix(text, "-}") || text == "" {
+			continue
+		}
+		if match := mt940Tag.FindStringSubmatch(text); match != nil {
+			if match[1] == "20" || len(messages) == 0 {
+				messages = append(messages, nil)
+			}
+			fields := &messages[len(messages)-1]
+			*fields = append(*fields, mt940Field{tag: match[1], value: text[len(match[0]):], line: line})
+			continue
+		}
+		if len(messages) == 0 {
+			return nil, fmt.Errorf("line %d: expected a field, got %q", line, text)
+		}
+		fields := messages[len(messages)-1]
+		last := &fields[len(fields)-1]
+		last.value += "\n" + text
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("parsing MT940: %w", err)
+	}
+	if len(messages) == 0 {
+		return nil, errNoStatement
+	}
+
+	statements := make([]Statement, len(messages))
+	var errs []error
+	for i, fields := range messages {
+		s, err := mt940Statement(fields)
+		if err == nil {
+			err = checkBalances(s)
+		}
+		if err != nil {
+			errs = append(errs, prefixErrors(fmt.Sprintf("statement %d", i+1), err)...)
+		}
+		statements[i] = s
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+	return statements, nil
+}
+
+// mt940Statement makes the statement of the fields of a message.
+func mt940Statement(fields []mt940Field) (Statement, error) {
+	var s Statement
+	var errs []error
+	for i, f := range fields {
+		var err error
+		switch f.tag {
+		case "25":
+			s.Account = strings.TrimSpace(f.value)
+		case "60F", "60M":
+			s.OpeningDate, s.Currency, s.OpeningBalance, err = mt940ParseBalance(f.value)
+		case "62F", "62M":
+			_, _, s.ClosingBalance, err = mt940ParseBalance(f.value)
+		case "61":
+			var e Entry
+			e, err = mt940Entry(f.value)
+			if err == nil && i+1 < len(fields) && fields[i+1].tag == "86" {
+				e.Counterparty, e.Purpose = mt940Information(fields[i+1].value)
+			}
+			s.Entries = append(s.Entries, e)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("line %d: field :%s: %w", f.line, f.tag, err))
+		}
+	}
+	return s, errors.Join(errs...)
+}
+
+// mt940ParseBalance parses the field of a balance.
+func mt940ParseBalance(value string) (date time.Time, currency, amount string, err error) {
+	match := mt940Balance.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return time.Time{}, "", "", fmt.Errorf("invalid balance %q", value)
+	}
+	date, err = time.Parse("060102", match[2])
+	if err != nil {
+		return time.Time{}, "", "", fmt.Errorf("invalid date %q", match[2])
+	}
+	return date, match[3], mt940Signed(match[4], match[1] == "D"), nil
+}
+
+// mt940Entry parses a statement line. Supplementary details on its second
+// line are ignored.
+func mt940Entry(value string) (Entry, error) {
+	first, _, _ := strings.Cut(value, "\n")
+	match := mt940Line.FindStringSubmatch(strings.TrimSpace(first))
+	if match == nil {
+		return Entry{}, fmt.Errorf("invalid statement line %q", first)
+	}
+	valueDate, err := time.Parse("060102", match[1])
+	if err != nil {
+		return Entry{}, fmt.Errorf("invalid value date %q", match[1])
+	}
+	bookingDate := valueDate
+	if match[2] != "" {
+		if bookingDate, err = mt940BookingDate(match[2], valueDate); err != nil {
+			return Entry{}, err
+		}
+	}
+
+	e := Entry{
+		BookingDate: bookingDate,
+		ValueDate:   valueDate,
+		// A reversal of a credit (RC) is a debit, and that of a debit (RD) a
+		// credit.
+		Amount:    mt940Signed(match[5], match[3] == "D" || match[3] == "RC"),
+		Reference: strings.TrimSpace(match[8]),
+	}
+	if customer := strings.TrimSpace(match[7]); e.Reference == "" && customer != "NONREF" {
+		e.Reference = customer
+	}
+	return e, nil
+}
+
+// mt940BookingDate returns the booking date of a statement line, which is
+// given without a year, in the year that puts it closest to the value date,
+// so that bookings around the turn of the year get theirs right.
+func mt940BookingDate(monthDay string, valueDate time.Time) (time.Time, error) {
+	var closest time.Time
+	for _, year := range []int{valueDate.Year() - 1, valueDate.Year(), valueDate.Year() + 1} {
+		date, err := time.Parse("20060102", fmt.Sprintf("%04d%s", year, monthDay))
+		if err != nil {
+			// February 29 of a year that is no leap year.
+			continue
+		}
+		if closest.IsZero() || date.Sub(valueDate).Abs() < closest.Sub(valueDate).Abs() {
+			closest = date
+		}
+	}
+	if closest.IsZero() {
+		return time.Time{}, fmt.Errorf("invalid booking date %q", monthDay)
+	}
+	return closest, nil
+}
+
+// mt940Information returns the counterparty and purpose of the information
+// to the account owner of an entry. Lines are joined without separators,
+// as banks break them at a fixed width, in the middle of words.
+func mt940Information(value string) (counterparty, purpose string) {
+	value = strings.ReplaceAll(value, "\n", "")
+	if !mt940Structured.MatchString(value) {
+		return "", strings.TrimSpace(value)
+	}
+	var purposes, names []string
+	for _, subfield := range strings.Split(value, "?")[1:] {
+		if len(subfield) < 2 {
+			continue
+		}
+		code, text := subfield[:2], subfield[2:]
+		switch {
+		case code >= "20" && code <= "29", code >= "60" && code <= "63":
+			purposes = append(purposes, text)
+		case code == "32", code == "33":
+			names = append(names, text)
+		}
+	}
+	return strings.TrimSpace(strings.Join(names, "")), strings.TrimSpace(strings.Join(purposes, ""))
+}
+
+// mt940Signed converts an amount with a decimal comma to a decimal number
+// with a point, negative for debits.
+func mt940Signed(amount string, debit bool) string {
+	whole, fraction, _ := strings.Cut(amount, ",")
+	whole = strings.TrimLeft(whole, "0")
+	if whole == "" {
+		whole = "0"
+	}
+	number := whole
+	if fraction != "" {
+		number += "." + fraction
+	}
+	if debit && strings.Trim(number, "0.") != "" {
+		number = "-" + number
+	}
+	return number
+}
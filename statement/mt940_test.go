@@ -0,0 +1,110 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package statement
+
+import (
+	"strings"
+	"testing"
+)
+
+// mt940Turn is an MT940 statement over the turn of the year 2024, with a
+// rent booked in 2025 whose value date is in 2024.
+const mt940Turn = `{1:F01BANKDEFFXXXX0000000000}{2:O940BANKDEFFXXXX}{4:
+:20:STARTUMSE
+:25:12030000/0000202051
+:28C:00001/001
+:60F:C241230EUR1000,00
+:61:2412301230DR12,50NMSCNONREF//REF-1
+:86:106?00Kartenzahlung?20Coffee and ?21cake?32Cafe am Markt
+:61:2501020102CR1500,NTRFNONREF
+:86:153?00Gehalt?20Salary January?32ACME?33 GmbH
+:61:2412310102D87,50NTRFRENT-2025-01
+:86:Rent for Janu
+ary
+:62F:C250102EUR2400,00
+-}`
+
+func TestUnitParseMT940(t *testing.T) {
+	statements, err := ParseMT940(strings.NewReader(mt940Turn))
+	if err != nil {
+		t.Fatalf("ParseMT940: %v", err)
+	}
+	if len(statements) != 1 {
+		t.Fatalf("got %d statements, want 1", len(statements))
+	}
+	s := statements[0]
+	if s.Account != "12030000/0000202051" || s.Currency != "EUR" {
+		t.Errorf("got account %q in %q", s.Account, s.Currency)
+	}
+	if s.OpeningBalance != "1000.00" || s.ClosingBalance != "2400.00" || !s.OpeningDate.Equal(date(2024, 12, 30)) {
+		t.Errorf("got balances %q on %v and %q", s.OpeningBalance, s.OpeningDate, s.ClosingBalance)
+	}
+	want := []Entry{
+		{date(2024, 12, 30), date(2024, 12, 30), "Cafe am Markt", "Coffee and cake", "-12.50", "REF-1"},
+		{date(2025, 1, 2), date(2025, 1, 2), "ACME GmbH", "Salary January", "1500", ""},
+		{date(2025, 1, 2), date(2024, 12, 31), "", "Rent for January", "-87.50", "RENT-2025-01"},
+	}
+	if len(s.Entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(s.Entries), len(want))
+	}
+	for i, e := range s.Entries {
+		if e != want[i] {
+			t.Errorf("entry %d: got %+v, want %+v", i+1, e, want[i])
+		}
+	}
+}
+
+func TestUnitMT940Signed(t *testing.T) {
+	tests := []struct {
+		amount string
+		debit  bool
+		want   string
+	}{
+		{"12,50", false, "12.50"},
+		{"12,50", true, "-12.50"},
+		{"0012,", false, "12"},
+		{"0,00", true, "0.00"},
+	}
+	for _, test := range tests {
+		if got := mt940Signed(test.amount, test.debit); got != test.want {
+			t.Errorf("mt940Signed(%q, %v) = %q, want %q", test.amount, test.debit, got, test.want)
+		}
+	}
+}
+
+func TestUnitParseMT940Errors(t *testing.T) {
+	tests := map[string]struct {
+		file string
+		want []string
+	}{
+		"no statement": {"{1:F01BANKDEFFXXXX0000000000}\n-}", []string{"no statement found"}},
+		"no field":     {"STARTUMSE", []string{`line 1: expected a field, got "STARTUMSE"`}},
+		"fields": {
+			":20:STARTUMSE\n:60F:C241230EUR1000.00\n:61:2412301230X12,50NMSCNONREF\n:61:2412301332D12,50NMSCNONREF",
+			[]string{
+				`statement 1: line 2: field :60F: invalid balance "C241230EUR1000.00"`,
+				`statement 1: line 3: field :61: invalid statement line "2412301230X12,50NMSCNONREF"`,
+				`statement 1: line 4: field :61: invalid booking date "1332"`,
+			},
+		},
+		"balances": {
+			strings.Replace(mt940Turn, ":62F:C250102EUR2400,00", ":62F:D250102EUR2400,00", 1),
+			[]string{"statement 1: closing balance -2400.00 does not match the opening balance 1000.00 and the entries, which add up to 2400.00"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := ParseMT940(strings.NewReader(test.file))
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range test.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err, want)
+				}
+			}
+		})
+	}
+}
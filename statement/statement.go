@@ -0,0 +1,289 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+// Package statement imports bank statements into ledger sheets: ISO 20022
+// CAMT.053 documents with [ParseCAMT053], and SWIFT MT940 files with
+// [ParseMT940]. Statements of one account, such as those of consecutive or
+// overlapping periods, are combined with [Merge], and turned into the rows
+// of a ledger sheet with [Ledger]:
+//
+//	statements, err := statement.ParseCAMT053(file)
+//	// ...
+//	merged, err := statement.Merge(statements...)
+//	// ...
+//	spreadsheet, err := rb.MakeSpreadsheetWithName("Ledger", statement.Ledger(merged))
+//
+// The rows of a ledger are sorted by booking date, keeping the order of the
+// bank within a day, so that the ledger of a longer period starts with the
+// rows of a shorter one, and importing statements again after new ones have
+// arrived only adds rows to the flat document [rb.MakeFlatOds] writes.
+package statement
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+	"time"
+
+	rb "github.com/fwilhe2/rechenbrett"
+)
+
+// Statement is a bank statement of an account: its balances and the
+// entries booked between them.
+type Statement struct {
+	// Account identifies the account, by its IBAN where the statement gives
+	// one.
+	Account string
+	// Currency is the ISO 4217 code of the account's currency, such as
+	// "EUR".
+	Currency string
+	// OpeningDate is the date of the opening balance. OpeningBalance and
+	// ClosingBalance are decimal numbers with a point, such as "-12.50", and
+	// empty if the statement does not give them.
+	OpeningDate    time.Time
+	OpeningBalance string
+	ClosingBalance string
+	Entries        []Entry
+}
+
+// Entry is a booking on an account.
+type Entry struct {
+	BookingDate time.Time
+	// ValueDate is zero if the statement does not give it.
+	ValueDate time.Time
+	// Counterparty names the other party of the booking: the debtor of a
+	// credit, and the creditor of a debit.
+	Counterparty string
+	Purpose      string
+	// Amount is a decimal number with a point, negative for debits, such as
+	// "-12.50". Amounts are kept as text so that they are not rounded.
+	Amount string
+	// Reference is the reference the bank gave the booking, if any.
+	Reference string
+}
+
+// Merge combines statements of one account into one, as for statements of
+// consecutive or overlapping periods. The opening balance is that of the
+// statement opening first, and the closing balance that of the statement
+// opening last.
+//
+// A statement that opens after the last booking day of the ones before it,
+// with the closing balance of the one before it, follows on from them, and
+// all its entries are kept, even those equal to earlier ones, such as a
+// booking the bank dated back. Otherwise, the statement overlaps the ones
+// before it, as does a statement imported twice whose entries add up to
+// zero, and its entries booked up to the last booking day those cover are
+// kept as often as the statement holding them most often has them, so that
+// bookings found in several statements are kept once, while those which
+// happen to be equal survive. Entries booked later are all kept.
+//
+// Merge reports statements of different accounts or currencies, and merged
+// statements whose entries do not add up to the difference between their
+// balances.
+func Merge(statements ...Statement) (Statement, error) {
+	if len(statements) == 0 {
+		return Statement{}, nil
+	}
+	sorted := slices.Clone(statements)
+	slices.SortStableFunc(sorted, func(a, b Statement) int { return a.OpeningDate.Compare(b.OpeningDate) })
+
+	merged := Statement{
+		Account:        sorted[0].Account,
+		Currency:       sorted[0].Currency,
+		OpeningDate:    sorted[0].OpeningDate,
+		OpeningBalance: sorted[0].OpeningBalance,
+		ClosingBalance: sorted[len(sorted)-1].ClosingBalance,
+	}
+	kept := map[Entry]int{}
+	// covered is the last booking day of the statements merged so far.
+	var covered time.Time
+	for i, s := range sorted {
+		if s.Account != merged.Account || s.Currency != merged.Currency {
+			return Statement{}, fmt.Errorf("statement %d: cannot merge account %s in %s with account %s in %s", i+1, s.Account, s.Currency, merged.Account, merged.Currency)
+		}
+		overlaps := i > 0 && (!s.OpeningDate.After(covered) || !sameAmount(s.OpeningBalance, sorted[i-1].ClosingBalance))
+		seen := map[Entry]int{}
+		for _, e := range s.Entries {
+			key := entryKey(e)
+			if overlaps && !key.BookingDate.After(covered) {
+				seen[key]++
+				if seen[key] <= kept[key] {
+					continue
+				}
+			}
+			kept[key]++
+			merged.Entries = append(merged.Entries, e)
+		}
+		for _, e := range s.Entries {
+			if key := entryKey(e); key.BookingDate.After(covered) {
+				covered = key.BookingDate
+			}
+		}
+	}
+	sortEntries(merged.Entries)
+	if err := checkBalances(merged); err != nil {
+		return Statement{}, fmt.Errorf("merged statement: %w", err)
+	}
+	return merged, nil
+}
+
+// sameAmount reports whether a and b are the same decimal number, such as
+// "97" and "97.00". Empty amounts are the same as no other.
+func sameAmount(a, b string) bool {
+	x, okX := new(big.Rat).SetString(a)
+	y, okY := new(big.Rat).SetString(b)
+	return okX && okY && x.Cmp(y) == 0
+}
+
+// entryKey returns e with its dates in UTC, so that equal entries are equal
+// keys.
+func entryKey(e Entry) Entry {
+	e.BookingDate, e.ValueDate = e.BookingDate.UTC(), e.ValueDate.UTC()
+	return e
+}
+
+// sortEntries sorts entries by booking date, keeping the order of the bank
+// within a day.
+func sortEntries(entries []Entry) {
+	slices.SortStableFunc(entries, func(a, b Entry) int { return a.BookingDate.Compare(b.BookingDate) })
+}
+
+// ledgerHeader names the columns of a ledger.
+var ledgerHeader = []string{"Booking Date", "Value Date", "Counterparty", "Purpose", "Amount", "Balance"}
+
+// Ledger returns the rows of a ledger sheet of s, ready for
+// [rb.MakeSpreadsheet], [rb.MakeSheet], or [rb.MakeTable]: a header row,
+// a row with the opening balance if s has one, and a row per entry, sorted
+// by booking date. The columns are the booking date, the value date, left
+// empty if the entry has none, the counterparty, the purpose, the amount,
+// and the balance after the entry, which is a formula adding the amount to
+// the balance above, so that
+// corrections made in the sheet carry on. Amounts and balances are
+// currency cells in EUR, USD, and GBP, and float cells in other currencies.
+// Balances are rounded to the minor unit of the currency, such as cents, or
+// to the most decimals an amount has, if that is more.
+//
+// The rows are meant to start at the top left cell of their sheet, which
+// the formulas refer to.
+func Ledger(s Statement) [][]rb.Cell {
+	valueType := "float"
+	switch s.Currency {
+	case "EUR", "USD", "GBP":
+		valueType = "currency-" + strings.ToLower(s.Currency)
+	}
+
+	header := make([]rb.Cell, len(ledgerHeader))
+	for i, name := range ledgerHeader {
+		header[i] = rb.MakeCell(name, "string")
+	}
+	cells := [][]rb.Cell{header}
+	if s.OpeningBalance != "" {
+		cells = append(cells, []rb.Cell{
+			rb.MakeDateCell(s.OpeningDate), {}, {}, rb.MakeCell("Opening balance", "string"), {},
+			rb.MakeCell(s.OpeningBalance, valueType),
+		})
+	}
+
+	scale := balanceScale(s)
+	entries := slices.Clone(s.Entries)
+	sortEntries(entries)
+	for _, e := range entries {
+		row := len(cells) + 1
+		balance := fmt.Sprintf("ROUND(E%d;%d)", row, scale)
+		if row > 2 {
+			balance = fmt.Sprintf("ROUND(F%d+E%d;%d)", row-1, row, scale)
+		}
+		cells = append(cells, []rb.Cell{
+			rb.MakeDateCell(e.BookingDate),
+			dateCell(e.ValueDate),
+			rb.MakeCell(e.Counterparty, "string"),
+			rb.MakeCell(e.Purpose, "string"),
+			rb.MakeCell(e.Amount, valueType),
+			rb.MakeCell(balance, "formula"),
+		})
+	}
+	return cells
+}
+
+// dateCell returns a date cell of t, or an empty cell if t is zero.
+func dateCell(t time.Time) rb.Cell {
+	if t.IsZero() {
+		return rb.Cell{}
+	}
+	return rb.MakeDateCell(t)
+}
+
+// minorUnits holds the ISO 4217 minor units of the currencies that have
+// other than two, as the number of decimals of their amounts.
+var minorUnits = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"BIF": 0, "CLP": 0, "DJF": 0, "GNF": 0, "ISK": 0, "JPY": 0, "KMF": 0, "KRW": 0,
+	"PYG": 0, "RWF": 0, "UGX": 0, "UYI": 0, "VND": 0, "VUV": 0, "XAF": 0, "XOF": 0, "XPF": 0,
+	"CLF": 4, "UYW": 4,
+}
+
+// balanceScale returns the number of decimals the balances of a ledger of s
+// are rounded to: the minor unit of its currency, or the most decimals of
+// its amounts and balances, if that is more.
+func balanceScale(s Statement) int {
+	scale, ok := minorUnits[s.Currency]
+	if !ok && s.Currency != "" {
+		scale = 2
+	}
+	amounts := []string{s.OpeningBalance, s.ClosingBalance}
+	for _, e := range s.Entries {
+		amounts = append(amounts, e.Amount)
+	}
+	for _, amount := range amounts {
+		if _, fraction, ok := strings.Cut(amount, "."); ok {
+			scale = max(scale, len(fraction))
+		}
+	}
+	return scale
+}
+
+// checkBalances reports whether the entries of s do not add up to the
+// difference between its balances, computing with exact decimals.
+func checkBalances(s Statement) error {
+	if s.OpeningBalance == "" || s.ClosingBalance == "" {
+		return nil
+	}
+	sum, ok := new(big.Rat).SetString(s.OpeningBalance)
+	if !ok {
+		return fmt.Errorf("invalid opening balance %q", s.OpeningBalance)
+	}
+	for _, e := range s.Entries {
+		amount, ok := new(big.Rat).SetString(e.Amount)
+		if !ok {
+			return fmt.Errorf("invalid amount %q", e.Amount)
+		}
+		sum.Add(sum, amount)
+	}
+	closing, ok := new(big.Rat).SetString(s.ClosingBalance)
+	if !ok {
+		return fmt.Errorf("invalid closing balance %q", s.ClosingBalance)
+	}
+	if sum.Cmp(closing) != 0 {
+		return fmt.Errorf("closing balance %s does not match the opening balance %s and the entries, which add up to %s", s.ClosingBalance, s.OpeningBalance, sum.FloatString(2))
+	}
+	return nil
+}
+
+// prefixErrors prefixes err, or each of the errors it joins, with prefix.
+func prefixErrors(prefix string, err error) []error {
+	joined, ok := err.(interface{ Unwrap() []error })
+	if !ok {
+		return []error{fmt.Errorf("%s: %w", prefix, err)}
+	}
+	var errs []error
+	for _, err := range joined.Unwrap() {
+		errs = append(errs, fmt.Errorf("%s: %w", prefix, err))
+	}
+	return errs
+}
+
+// errNoStatement is returned for documents holding no statement at all.
+var errNoStatement = errors.New("no statement found")
@@ -0,0 +1,189 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package statement
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	rb "github.com/fwilhe2/rechenbrett"
+)
+
+var (
+	coffee = Entry{date(2025, 3, 3), date(2025, 3, 3), "Cafe am Markt", "Coffee", "-3.20", ""}
+	salary = Entry{date(2025, 3, 1), date(2025, 3, 1), "ACME GmbH", "Salary March", "1500.00", "REF-2"}
+	rent   = Entry{date(2025, 3, 3), date(2025, 3, 1), "Landlord", "Rent March", "-800.00", "REF-3"}
+	books  = Entry{date(2025, 3, 10), date(2025, 3, 10), "Bookshop", "Books", "-35.90", "REF-4"}
+
+	// early and late are statements of overlapping periods, as downloaded on
+	// March 3 and March 10. Both hold the two coffees of March 3.
+	early = Statement{
+		Account: "DE02120300000000202051", Currency: "EUR",
+		OpeningDate: date(2025, 2, 28), OpeningBalance: "100.00", ClosingBalance: "793.60",
+		Entries: []Entry{salary, coffee, rent, coffee},
+	}
+	late = Statement{
+		Account: "DE02120300000000202051", Currency: "EUR",
+		OpeningDate: date(2025, 3, 2), OpeningBalance: "1600.00", ClosingBalance: "757.70",
+		Entries: []Entry{coffee, rent, coffee, books},
+	}
+)
+
+func TestUnitMerge(t *testing.T) {
+	merged, err := Merge(late, early)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if merged.OpeningBalance != "100.00" || merged.ClosingBalance != "757.70" || !merged.OpeningDate.Equal(early.OpeningDate) {
+		t.Errorf("got balances %q on %v and %q", merged.OpeningBalance, merged.OpeningDate, merged.ClosingBalance)
+	}
+	want := []Entry{salary, coffee, rent, coffee, books}
+	if !reflect.DeepEqual(merged.Entries, want) {
+		t.Errorf("got entries %+v, want %+v", merged.Entries, want)
+	}
+	if err := checkBalances(merged); err != nil {
+		t.Errorf("checkBalances: %v", err)
+	}
+
+	other := late
+	other.Account = "DE89370400440532013000"
+	if _, err := Merge(early, other); err == nil || !strings.Contains(err.Error(), "statement 2: cannot merge account DE89370400440532013000") {
+		t.Errorf("got error %v, want one about the account", err)
+	}
+}
+
+func TestUnitMergeConsecutive(t *testing.T) {
+	morning := Entry{date(2025, 3, 3), date(2025, 3, 3), "Cafe am Markt", "Coffee", "-3.00", ""}
+	first := Statement{
+		Account: "DE02120300000000202051", Currency: "EUR",
+		OpeningDate: date(2025, 3, 2), OpeningBalance: "100.00", ClosingBalance: "97.00",
+		Entries: []Entry{morning},
+	}
+	// The second statement opens after the first one's last booking day
+	// and holds an equal coffee the bank dated back.
+	second := first
+	second.OpeningDate, second.OpeningBalance, second.ClosingBalance = date(2025, 3, 4), "97", "94.00"
+
+	merged, err := Merge(second, first)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if want := []Entry{morning, morning}; !reflect.DeepEqual(merged.Entries, want) {
+		t.Errorf("got entries %+v, want both coffees %+v", merged.Entries, want)
+	}
+	if merged.OpeningBalance != "100.00" || merged.ClosingBalance != "94.00" {
+		t.Errorf("got balances %q and %q", merged.OpeningBalance, merged.ClosingBalance)
+	}
+
+	// A statement imported twice is an overlap, even if its entries add up
+	// to zero.
+	refund := Entry{date(2025, 3, 3), date(2025, 3, 3), "Cafe am Markt", "Refund", "5.00", ""}
+	charge := refund
+	charge.Purpose, charge.Amount = "Coffee", "-5.00"
+	even := Statement{
+		Account: "DE02120300000000202051", Currency: "EUR",
+		OpeningDate: date(2025, 3, 2), OpeningBalance: "100.00", ClosingBalance: "100.00",
+		Entries: []Entry{charge, refund},
+	}
+	merged, err = Merge(even, even)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	if want := []Entry{charge, refund}; !reflect.DeepEqual(merged.Entries, want) {
+		t.Errorf("got entries %+v, want each once %+v", merged.Entries, want)
+	}
+
+	// A statement missing in between leaves the merged one short of its
+	// entries.
+	third := second
+	third.OpeningDate, third.OpeningBalance, third.ClosingBalance = date(2025, 3, 5), "90.00", "87.00"
+	third.Entries = []Entry{{date(2025, 3, 5), date(2025, 3, 5), "Cafe am Markt", "Coffee", "-3.00", ""}}
+	if _, err := Merge(first, third); err == nil || !strings.Contains(err.Error(), "merged statement: closing balance 87.00 does not match") {
+		t.Errorf("got error %v, want one about the balances", err)
+	}
+}
+
+func TestUnitLedger(t *testing.T) {
+	cells := Ledger(early)
+	want := [][]rb.Cell{
+		{
+			rb.MakeCell("Booking Date", "string"), rb.MakeCell("Value Date", "string"), rb.MakeCell("Counterparty", "string"),
+			rb.MakeCell("Purpose", "string"), rb.MakeCell("Amount", "string"), rb.MakeCell("Balance", "string"),
+		},
+		{rb.MakeDateCell(date(2025, 2, 28)), {}, {}, rb.MakeCell("Opening balance", "string"), {}, rb.MakeCell("100.00", "currency-eur")},
+		{
+			rb.MakeDateCell(date(2025, 3, 1)), rb.MakeDateCell(date(2025, 3, 1)), rb.MakeCell("ACME GmbH", "string"),
+			rb.MakeCell("Salary March", "string"), rb.MakeCell("1500.00", "currency-eur"), rb.MakeCell("ROUND(F2+E3;2)", "formula"),
+		},
+	}
+	if len(cells) != 6 {
+		t.Fatalf("got %d rows, want 6", len(cells))
+	}
+	if !reflect.DeepEqual(cells[:3], want) {
+		t.Errorf("got rows %+v, want %+v", cells[:3], want)
+	}
+	if got := cells[5][5].Formula; got != "of:=ROUND([.F5]+[.E6];2)" {
+		t.Errorf("got balance formula %q", got)
+	}
+
+	cells = Ledger(Statement{Currency: "CHF", Entries: []Entry{salary}})
+	if got := cells[1][4].ValueType; got != "float" {
+		t.Errorf("got amount of type %q, want float", got)
+	}
+	if got := cells[1][5].Formula; got != "of:=ROUND([.E2];2)" {
+		t.Errorf("got balance formula %q", got)
+	}
+
+	// Balances keep the minor unit of the currency, and the decimals of
+	// amounts that have more.
+	for _, c := range []struct {
+		currency, amount, formula string
+	}{
+		{"KWD", "-1.250", "of:=ROUND([.E2];3)"},
+		{"JPY", "-1500", "of:=ROUND([.E2];0)"},
+		{"EUR", "-0.125", "of:=ROUND([.E2];3)"},
+		{"", "-12", "of:=ROUND([.E2];0)"},
+	} {
+		cells = Ledger(Statement{Currency: c.currency, Entries: []Entry{{BookingDate: date(2025, 3, 1), Amount: c.amount}}})
+		if got := cells[1][5].Formula; got != c.formula {
+			t.Errorf("got balance formula %q in %q, want %q", got, c.currency, c.formula)
+		}
+	}
+}
+
+func TestUnitLedgerReimport(t *testing.T) {
+	merged, err := Merge(early, late)
+	if err != nil {
+		t.Fatalf("Merge: %v", err)
+	}
+	before, after := Ledger(early), Ledger(merged)
+	if !reflect.DeepEqual(after[:len(before)], before) {
+		t.Errorf("the ledger of the longer period does not start with the rows of the shorter one")
+	}
+
+	flat := func(cells [][]rb.Cell) string {
+		t.Helper()
+		spreadsheet, err := rb.MakeSpreadsheetWithName("Ledger", cells)
+		if err != nil {
+			t.Fatalf("MakeSpreadsheetWithName: %v", err)
+		}
+		document, err := rb.MakeFlatOds(spreadsheet)
+		if err != nil {
+			t.Fatalf("MakeFlatOds: %v", err)
+		}
+		return document
+	}
+	// The rows of the shorter period are written as they were, only
+	// followed by the new ones.
+	rows := func(document string) string {
+		start := strings.Index(document, "<table:table-row")
+		end := strings.LastIndex(document, "</table:table-row>")
+		return document[start:end]
+	}
+	if !strings.HasPrefix(rows(flat(after)), rows(flat(before))) {
+		t.Errorf("the rows of the shorter period changed in the flat document")
+	}
+}
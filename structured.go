@@ -0,0 +1,418 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// structuredNode is an Excel structured reference to the cells of a table
+// made by [MakeTable] or [MakeTableSheet], such as Products[Unit Price],
+// Products[@Qty], or Products[[#Totals],[Price]]. It occurs in A1 and R1C1
+// notation only, and is replaced by the cells it denotes once the tables of the
+// workbook are known.
+type structuredNode struct {
+	// text is the spelling of the reference in the formula.
+	text string
+	// table is the name of the table, or empty for the table the formula
+	// is in ("[@Qty]").
+	table string
+	// items are the special items of the reference, in lower case: "#all",
+	// "#data", "#headers", "#totals", and "#this row", which "@" stands for.
+	items []string
+	// first and last are the columns of the reference, empty for all of
+	// them.
+	first, last string
+}
+
+// errStructuredReference reports a formula holding structured references,
+// which cannot be translated before the tables they refer to are known.
+var errStructuredReference = errors.New("formula holds structured references")
+
+// structuredItems are the special items of structured references.
+var structuredItems = []string{"#all", "#data", "#headers", "#totals", "#this row"}
+
+// scanStructuredReference scans the brackets of a structured reference at
+// p.pos, which follow the name of its table beginning at start, or begin the
+// reference if it names no table.
+func (p *formulaParser) scanStructuredReference(table string, start int) token {
+	n := structuredNode{table: table}
+	p.pos++
+	if err := p.scanStructuredSpecifier(&n); err != nil {
+		return token{kind: tokenInvalid, text: string(p.input[start:min(p.pos, len(p.input))]), err: err}
+	}
+	n.text = string(p.input[start:p.pos])
+	return token{kind: tokenStructured, text: n.text, structured: n}
+}
+
+// scanStructuredSpecifier scans what is within the outer brackets of a
+// structured reference, and the closing bracket:
+//
+//	Products[]                      all data rows
+//	Products[Unit Price]            a column
+//	Products[@Qty], [@[Unit Price]] a column of the row of the formula
+//	Products[#Totals]               an item
+//	Products[[#Headers],[#Data],[Qty]:[Price]]
+//	                                items and columns, separated by commas
+//
+// Within column names, an apostrophe escapes the character after it, which
+// Excel requires for brackets, "#", and the apostrophe.
+func (p *formulaParser) scanStructuredSpecifier(n *structuredNode) error {
+	switch {
+	case p.peekIs(0, ']'):
+	case p.peekIs(0, '@'):
+		p.pos++
+		n.items = append(n.items, "#this row")
+		if !p.peekIs(0, ']') {
+			if err := p.scanStructuredColumns(n, p.peekIs(0, '[')); err != nil {
+				return err
+			}
+		}
+	case p.peekIs(0, '#'):
+		if err := p.scanStructuredItem(n); err != nil {
+			return err
+		}
+	case p.peekIs(0, '['):
+		for {
+			p.skipSpaces()
+			switch {
+			case p.peekIs(0, '[') && (p.peekIs(1, '#') || p.peekIs(1, '@')):
+				p.pos++
+				if p.peekIs(0, '@') {
+					p.pos++
+					n.items = append(n.items, "#this row")
+				} else if err := p.scanStructuredItem(n); err != nil {
+					return err
+				}
+				if !p.peekIs(0, ']') {
+					return errors.New("unterminated structured reference")
+				}
+				p.pos++
+			case p.peekIs(0, '['):
+				if err := p.scanStructuredColumns(n, true); err != nil {
+					return err
+				}
+			default:
+				return errors.New("unterminated structured reference")
+			}
+			p.skipSpaces()
+			if !p.peekIs(0, ',') {
+				break
+			}
+			p.pos++
+		}
+	default:
+		if err := p.scanStructuredColumns(n, false); err != nil {
+			return err
+		}
+	}
+	if !p.peekIs(0, ']') {
+		return errors.New("unterminated structured reference")
+	}
+	p.pos++
+	return nil
+}
+
+// scanStructuredItem scans a special item such as "#Totals".
+func (p *formulaParser) scanStructuredItem(n *structuredNode) error {
+	item, ok := p.scanStructuredName()
+	if !ok {
+		return errors.New("unterminated structured reference")
+	}
+	lower := strings.ToLower(strings.TrimSpace(item))
+	if !slices.Contains(structuredItems, lower) {
+		return fmt.Errorf("unknown item %q in structured reference", item)
+	}
+	n.items = append(n.items, lower)
+	return nil
+}
+
+// scanStructuredColumns scans a column, or a range of columns if bracketed
+// is set: "[Qty]" or "[Qty]:[Price]".
+func (p *formulaParser) scanStructuredColumns(n *structuredNode, bracketed bool) error {
+	if n.first != "" {
+		return errors.New("more than one column range in structured reference")
+	}
+	column := func() (string, bool) {
+		if !bracketed {
+			return p.scanStructuredName()
+		}
+		if !p.peekIs(0, '[') {
+			return "", false
+		}
+		p.pos++
+		name, ok := p.scanStructuredName()
+		if !ok || !p.peekIs(0, ']') {
+			return "", false
+		}
+		p.pos++
+		return name, true
+	}
+
+	var ok bool
+	if n.first, ok = column(); !ok {
+		return errors.New("unterminated structured reference")
+	}
+	n.last = n.first
+	if bracketed && p.peekIs(0, ':') {
+		p.pos++
+		if n.last, ok = column(); !ok {
+			return errors.New("unterminated structured reference")
+		}
+	}
+	if n.first == "" || n.last == "" {
+		return errors.New("empty column name in structured reference")
+	}
+	return nil
+}
+
+// scanStructuredName scans a column name or item up to the closing bracket,
+// which it leaves to the caller.
+func (p *formulaParser) scanStructuredName() (string, bool) {
+	var b strings.Builder
+	for ; p.pos < len(p.input); p.pos++ {
+		switch c := p.input[p.pos]; c {
+		case '\'':
+			p.pos++
+			if p.pos == len(p.input) {
+				return "", false
+			}
+			b.WriteRune(p.input[p.pos])
+		case '[':
+			return "", false
+		case ']':
+			return b.String(), true
+		default:
+			b.WriteRune(c)
+		}
+	}
+	return "", false
+}
+
+func (p *formulaParser) skipSpaces() {
+	for p.peekIs(0, ' ') {
+		p.pos++
+	}
+}
+
+// replaceStructured returns node with its structured references replaced by
+// what resolve returns for them.
+func replaceStructured(node formulaNode, resolve func(structuredNode) (formulaNode, error)) (formulaNode, error) {
+	return replaceFormula(node, func(node formulaNode) (formulaNode, bool, error) {
+		n, ok := node.(structuredNode)
+		if !ok {
+			return nil, false, nil
+		}
+		replaced, err := resolve(n)
+		return replaced, true, err
+	})
+}
+
+// hasStructured reports whether node holds a structured reference.
+func hasStructured(node formulaNode) bool {
+	found := false
+	walkFormula(node, func(node formulaNode) bool {
+		if _, ok := node.(structuredNode); ok {
+			found = true
+		}
+		return !found
+	})
+	return found
+}
+
+// structuredTable is a table structured references can refer to: a sheet
+// made by [MakeTableSheet], which holds the table from its first cell on.
+// Rows are 1-based and 0 if the table has none of the kind.
+type structuredTable struct {
+	name  string
+	sheet string
+	// columns holds the name of each column: the text of its header cell,
+	// or "Column<n>", as Excel calls the columns of tables without a
+	// header and those of empty header cells.
+	columns      []string
+	headerRow    int
+	firstDataRow int
+	lastDataRow  int
+	totalsRow    int
+}
+
+// makeStructuredTable describes the table a sheet made by [MakeTableSheet]
+// holds, given its name and layout.
+func makeStructuredTable(name, sheet string, layout tableLayout, opts TableOptions) structuredTable {
+	t := structuredTable{
+		name:         name,
+		sheet:        sheet,
+		firstDataRow: layout.firstDataRow,
+		lastDataRow:  layout.lastDataRow,
+	}
+	if layout.firstDataRow > 1 {
+		t.headerRow = 1
+	}
+	if len(opts.Totals) > 0 {
+		t.totalsRow = layout.lastDataRow + 1
+	}
+	for j := range layout.maxCols {
+		column := ""
+		if t.headerRow > 0 && j < len(layout.cells[0]) {
+			column = cmp.Or(layout.cells[0][j].Text, layout.cells[0][j].Value)
+		}
+		if column == "" {
+			column = fmt.Sprintf("Column%d", j+1)
+		}
+		t.columns = append(t.columns, column)
+	}
+	return t
+}
+
+// column returns the 1-based position of the named column, compared without
+// regard to case as Excel does.
+func (t structuredTable) column(name string) (int, error) {
+	for j, column := range t.columns {
+		if strings.EqualFold(column, name) {
+			return j + 1, nil
+		}
+	}
+	return 0, fmt.Errorf("table %q has no column %q", t.name, name)
+}
+
+// contains reports whether the cell at row and col of sheet is part of the
+// table.
+func (t structuredTable) contains(sheet string, row, col int) bool {
+	last := max(t.lastDataRow, t.totalsRow)
+	return sheet == t.sheet && row >= 1 && row <= last && col >= 1 && col <= len(t.columns)
+}
+
+// rows returns the first and last row the items of a structured reference in
+// a formula on row denote.
+func (t structuredTable) rows(items []string, row int) (first, last int, err error) {
+	slices.Sort(items)
+	items = slices.Compact(items)
+	switch strings.Join(items, ",") {
+	case "", "#data":
+		first, last = t.firstDataRow, t.lastDataRow
+	case "#headers":
+		first, last = t.headerRow, t.headerRow
+	case "#totals":
+		first, last = t.totalsRow, t.totalsRow
+	case "#data,#headers":
+		first, last = t.headerRow, t.lastDataRow
+	case "#data,#totals":
+		first, last = t.firstDataRow, t.totalsRow
+	case "#all":
+		first, last = cmp.Or(t.headerRow, t.firstDataRow), cmp.Or(t.totalsRow, t.lastDataRow)
+	case "#this row":
+		if row < t.firstDataRow || row > t.lastDataRow {
+			return 0, 0, fmt.Errorf("row %d is not a data row of table %q", row, t.name)
+		}
+		first, last = row, row
+	default:
+		return 0, 0, fmt.Errorf("invalid combination of items %s", strings.Join(items, ", "))
+	}
+
+	switch {
+	case first == 0 && slices.Contains(items, "#headers"):
+		return 0, 0, fmt.Errorf("table %q has no header row", t.name)
+	case last == 0 && slices.Contains(items, "#totals"):
+		return 0, 0, fmt.Errorf("table %q has no totals row", t.name)
+	case first > last || first == 0:
+		return 0, 0, fmt.Errorf("table %q has no data rows", t.name)
+	}
+	return first, last, nil
+}
+
+// resolveStructured returns the reference to the cells a structured
+// reference in a formula at row and col of sheet denotes. Ranges of rows are
+// absolute, as they stay with the table wherever the formula is copied; the
+// row of the formula ("@") is relative.
+func (b *workbookBuilder) resolveStructured(n structuredNode, sheet string, row, col int) (formulaNode, error) {
+	var t structuredTable
+	found := false
+	for _, candidate := range b.structuredTables {
+		if n.table == "" && candidate.contains(sheet, row, col) || n.table != "" && strings.EqualFold(candidate.name, n.table) {
+			t, found = candidate, true
+			break
+		}
+	}
+	switch {
+	case !found && n.table == "":
+		return nil, fmt.Errorf("structured reference %s outside a table", n.text)
+	case !found:
+		return nil, fmt.Errorf("undefined table %q", n.table)
+	}
+
+	firstRow, lastRow, err := t.rows(n.items, row)
+	if err != nil {
+		return nil, err
+	}
+	firstCol, lastCol := 1, len(t.columns)
+	if n.first != "" {
+		if firstCol, err = t.column(n.first); err != nil {
+			return nil, err
+		}
+		if lastCol, err = t.column(n.last); err != nil {
+			return nil, err
+		}
+		firstCol, lastCol = min(firstCol, lastCol), max(firstCol, lastCol)
+	}
+
+	prefix := "."
+	if t.sheet != sheet {
+		prefix = "$" + quoteSheetName(t.sheet) + "."
+	}
+	rowMarker := "$"
+	if slices.Contains(n.items, "#this row") {
+		rowMarker = ""
+	}
+	address := fmt.Sprintf("%s$%s%s%d", prefix, columnToLetters(firstCol), rowMarker, firstRow)
+	if firstRow != lastRow || firstCol != lastCol {
+		address += fmt.Sprintf(":.$%s%s%d", columnToLetters(lastCol), rowMarker, lastRow)
+	}
+	ref, err := parseRangeAddress(address)
+	if err != nil {
+		return nil, err
+	}
+	return referenceNode{address: address, ref: ref}, nil
+}
+
+// resolveFormulaRow translates the formulas holding structured references
+// and those written in R1C1 notation of the rowIdx-th row of sheet, now that
+// the tables and the position of the cells are known. It returns the errors
+// of formulas that do not parse and of references that do not resolve.
+func (b *workbookBuilder) resolveFormulaRow(sheet string, rowIdx int, cells []Cell) []error {
+	var errs []error
+	for colIdx := range cells {
+		cell := &cells[colIdx]
+		var node formulaNode
+		var err error
+		switch {
+		case cell.r1c1Source != "":
+			origin := cellAddress{row: rowIdx + 1, col: colIdx + 1}
+			node, err = parseR1C1Formula(strings.TrimPrefix(cell.r1c1Source, "="), origin)
+			if err != nil {
+				err = fmt.Errorf("invalid formula %q: %w", cell.r1c1Source, err)
+			}
+		case cell.structuredSource != "":
+			node, err = parseFormula(strings.TrimPrefix(cell.structuredSource, "="), a1Notation)
+		default:
+			continue
+		}
+		if err == nil {
+			node, err = replaceStructured(node, func(n structuredNode) (formulaNode, error) {
+				return b.resolveStructured(n, sheet, rowIdx+1, colIdx+1)
+			})
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("row %d, column %d: %w", rowIdx+1, colIdx+1, err))
+			continue
+		}
+		cell.Formula = formulaNamespace + "=" + renderFormula(node)
+		cell.structuredSource, cell.r1c1Source = "", ""
+	}
+	return errs
+}
@@ -0,0 +1,158 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// orderCells are the rows of an order table with a calculated column.
+func orderCells() [][]Cell {
+	return [][]Cell{
+		{MakeCell("Product", "string"), MakeCell("Qty", "string"), MakeCell("Unit Price", "string"), MakeCell("Total", "string")},
+		{MakeCell("Pen", "string"), MakeCell("2", "float"), MakeCell("1.5", "float"), MakeCell("[@Qty]*[@[Unit Price]]", "formula")},
+		{MakeCell("Desk", "string"), MakeCell("1", "float"), MakeCell("189", "float"), MakeCell("Products[@Qty]*Products[[#This Row],[Unit Price]]", "formula")},
+	}
+}
+
+func orderTableSheet() Sheet {
+	return MakeTableSheet("Orders", orderCells(), TableOptions{
+		Name:   "Products",
+		Header: true,
+		Totals: []Total{{TotalNone}, {TotalSum}, {TotalNone}, {TotalSum}},
+	})
+}
+
+func TestUnitStructuredReferences(t *testing.T) {
+	formulas := []string{
+		"SUM(Products[Unit Price])",
+		"Products[[#Totals],[Qty]]",
+		"SUM(Products[[Qty]:[Unit Price]])",
+		"COUNTA(Products[#Headers])",
+		"ROWS(Products[#All])",
+		"SUM(products[[#Data], [#Totals], [qty]])",
+		"SUM(Products[])",
+		"Products[@Qty]",
+	}
+	var cells []Cell
+	for _, f := range formulas {
+		cells = append(cells, MakeCell(f, "formula"))
+	}
+	spreadsheet, err := MakeWorkbook(orderTableSheet(), MakeSheet("Summary", [][]Cell{{}, cells}))
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	expected := []string{
+		"of:=SUM([$Orders.$C$2:.$C$3])",
+		"of:=[$Orders.$B$4]",
+		"of:=SUM([$Orders.$B$2:.$C$3])",
+		"of:=COUNTA([$Orders.$A$1:.$D$1])",
+		"of:=ROWS([$Orders.$A$1:.$D$4])",
+		"of:=SUM([$Orders.$B$2:.$B$4])",
+		"of:=SUM([$Orders.$A$2:.$D$3])",
+		"of:=[$Orders.$B2]",
+	}
+	summary := spreadsheet.Tables[1].Rows[1].Cells
+	for i := range expected {
+		assert(t, summary[i].Formula == expected[i], fmt.Sprintf("%s: expected %s, got %s", formulas[i], expected[i], summary[i].Formula))
+	}
+
+	// The calculated column refers to the cells of its own row.
+	orders := spreadsheet.Tables[0].Rows
+	assert(t, orders[1].Cells[3].Formula == "of:=[.$B2]*[.$C2]", "expected same-row references, got "+orders[1].Cells[3].Formula)
+	assert(t, orders[2].Cells[3].Formula == "of:=[.$B3]*[.$C3]", "expected same-row references, got "+orders[2].Cells[3].Formula)
+	assert(t, orders[1].Cells[3].Value == "3", "expected the calculated column to be evaluated, got "+orders[1].Cells[3].Value)
+	assert(t, summary[0].Value == "190.5", "expected the column sum to be evaluated, got "+summary[0].Value)
+
+	// Escaped brackets in column names, and tables without a header.
+	spreadsheet, err = MakeWorkbook(
+		MakeTableSheet("Prices", [][]Cell{{MakeCell("Price [EUR]", "string")}, {MakeCell("2", "float")}}, TableOptions{Header: true}),
+		MakeTableSheet("Raw", [][]Cell{{MakeCell("1", "float"), MakeCell("2", "float")}}, TableOptions{}),
+		MakeSheet("Summary", [][]Cell{{MakeCell("Table1[Price '[EUR']]+SUM(Table2[Column2])", "formula")}}),
+	)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+	formula := spreadsheet.Tables[2].Rows[0].Cells[0].Formula
+	assert(t, formula == "of:=[$Prices.$A$2]+SUM([$Raw.$B$1])", "expected escaped and generated column names to resolve, got "+formula)
+}
+
+func TestUnitStructuredReferenceErrors(t *testing.T) {
+	cases := map[string]string{
+		"Orders[Qty]":                    `row 1, column 1: undefined table "Orders"`,
+		"Products[Price]":                `row 1, column 1: table "Products" has no column "Price"`,
+		"[@Qty]":                         "row 1, column 1: structured reference [@Qty] outside a table",
+		"Products[[#Headers],[#Totals]]": "row 1, column 1: invalid combination of items #headers, #totals",
+	}
+	for formula, expected := range cases {
+		t.Run(formula, func(t *testing.T) {
+			_, err := MakeWorkbook(orderTableSheet(), MakeSheet("Summary", [][]Cell{{MakeCell(formula, "formula")}}))
+			if err == nil || !strings.Contains(err.Error(), `sheet "Summary": `+expected) {
+				t.Errorf("expected an error containing %q, got: %v", expected, err)
+			}
+		})
+	}
+
+	// The header row is not a data row, and the table has no totals row.
+	_, err := MakeTable([][]Cell{
+		{MakeCell("Qty", "string"), MakeCell("[@Qty]", "formula")},
+		{MakeCell("1", "float"), MakeCell("Table1[#Totals]", "formula")},
+	}, TableOptions{Header: true})
+	for _, expected := range []string{
+		`row 1, column 2: row 1 is not a data row of table "Table1"`,
+		`row 2, column 2: table "Table1" has no totals row`,
+	} {
+		assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+	}
+
+	for formula, expected := range map[string]string{
+		"Products[Qty":      "unterminated structured reference",
+		"Products[#Total]":  `unknown item "#Total" in structured reference`,
+		"Products[[A],[B]]": "more than one column range in structured reference",
+	} {
+		_, err := toOpenFormula(formula)
+		assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("%s: expected an error containing %q, got: %v", formula, expected, err))
+	}
+
+	sw, err := NewSheetWriter(io.Discard, "Sheet1")
+	if err != nil {
+		t.Fatalf("NewSheetWriter: %v", err)
+	}
+	err = sw.WriteRow([]Cell{MakeCell("SUM(Products[Qty])", "formula")})
+	assert(t, err != nil && strings.Contains(err.Error(), `undefined table "Products"`), fmt.Sprintf("expected the table to be undefined in a streamed sheet, got: %v", err))
+}
+
+func TestStructuredReferences(t *testing.T) {
+	spreadsheet, err := MakeWorkbook(orderTableSheet(), MakeSheet("Summary", [][]Cell{
+		{MakeCell("SUM(Products[Total])", "formula"), MakeCell("Products[[#Totals],[Qty]]", "formula")},
+	}))
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	// LibreOffice exports the first sheet only: the order table with its
+	// calculated column and totals row.
+	expectedThisCsv := map[string][][]string{
+		"en_US.UTF-8": {
+			{"Product", "Qty", "Unit Price", "Total"},
+			{"Pen", "2.00", "1.50", "3"},
+			{"Desk", "1.00", "189.00", "189"},
+			{"", "3", "", "192"},
+		},
+		"de_DE.UTF-8": {
+			{"Product", "Qty", "Unit Price", "Total"},
+			{"Pen", "2,00", "1,50", "3"},
+			{"Desk", "1,00", "189,00", "189"},
+			{"", "3", "", "192"},
+		},
+	}
+
+	renderAndCompare(t, "structured-references", "ods", spreadsheet, expectedThisCsv)
+	renderAndCompare(t, "structured-references", "fods", spreadsheet, expectedThisCsv)
+}
@@ -0,0 +1,138 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+// MakeFloatCell creates a cell holding the number v, formatted like a
+// "float" cell of [MakeCell]. The value is stored with all its digits. NaN
+// and infinities, which no spreadsheet can hold, are reported by
+// [MakeSpreadsheet].
+func MakeFloatCell(v float64) Cell {
+	return floatCell(v, 64)
+}
+
+// MakeIntCell creates a cell holding the integer v, formatted like a "float"
+// cell of [MakeCell].
+func MakeIntCell(v int64) Cell {
+	return Cell{ValueType: "float", StyleName: "FLOAT_STYLE", Value: strconv.FormatInt(v, 10)}
+}
+
+// MakeDateCell creates a cell holding the calendar date of t in its own
+// location, formatted like a "date" cell of [MakeCell]. The time of day is
+// dropped; see [MakeDateTimeCell] to keep it.
+func MakeDateCell(t time.Time) Cell {
+	return Cell{ValueType: "date", StyleName: "DATE_STYLE", DateValue: t.Format("2006-01-02")}
+}
+
+// MakeDateTimeCell creates a cell holding t, formatted like a "datetime" cell
+// of [MakeCell]. As office:date-value carries no zone, t is stored in UTC,
+// like datetime values given with a zone.
+func MakeDateTimeCell(t time.Time) Cell {
+	return Cell{ValueType: "date", StyleName: "DATETIME_STYLE", DateValue: t.UTC().Format("2006-01-02T15:04:05.999999999")}
+}
+
+// MakeDurationCell creates a cell holding the elapsed time d, formatted like
+// a "duration" cell of [MakeCell], including fractions of a second. Negative
+// durations are reported by [MakeSpreadsheet].
+func MakeDurationCell(d time.Duration) Cell {
+	cell := Cell{ValueType: "time", StyleName: "DURATION_STYLE"}
+	if d < 0 {
+		cell.err = fmt.Errorf("invalid duration %s, expected a positive duration", d)
+		return cell
+	}
+	h, m := d/time.Hour, d%time.Hour/time.Minute
+	s, ns := d%time.Minute/time.Second, d%time.Second
+	cell.TimeValue = fmt.Sprintf("PT%02dH%02dM%02dS", h, m, s)
+	if ns != 0 {
+		fraction := strconv.FormatFloat(ns.Seconds(), 'f', -1, 64)
+		cell.TimeValue = fmt.Sprintf("PT%02dH%02dM%02d%sS", h, m, s, fraction[1:])
+	}
+	return cell
+}
+
+// MakeBoolCell creates a cell holding v, formatted like a "boolean" cell of
+// [MakeCell].
+func MakeBoolCell(v bool) Cell {
+	return Cell{ValueType: "boolean", StyleName: "BOOLEAN_STYLE", BooleanValue: strconv.FormatBool(v)}
+}
+
+// CellValue lists the Go types [CellOf] turns into cells.
+type CellValue interface {
+	float64 | float32 |
+		int | int8 | int16 | int32 | int64 |
+		uint | uint8 | uint16 | uint32 | uint64 |
+		bool | string | time.Time | time.Duration
+}
+
+// CellOf creates a cell holding v with the value type and format that fit
+// its Go type: numbers become "float" cells as by [MakeFloatCell] and
+// [MakeIntCell], booleans "boolean" cells, strings "string" cells,
+// durations "duration" cells, and times "date" cells if they fall on
+// midnight in their location and "datetime" cells otherwise.
+func CellOf[T CellValue](v T) Cell {
+	switch v := any(v).(type) {
+	case float64:
+		return MakeFloatCell(v)
+	case float32:
+		// Formatted with the precision of float32, which would otherwise
+		// gain digits it never had: 0.1 rather than 0.10000000149011612.
+		return floatCell(float64(v), 32)
+	case int:
+		return MakeIntCell(int64(v))
+	case int8:
+		return MakeIntCell(int64(v))
+	case int16:
+		return MakeIntCell(int64(v))
+	case int32:
+		return MakeIntCell(int64(v))
+	case int64:
+		return MakeIntCell(v)
+	case uint:
+		return uintCell(uint64(v))
+	case uint8:
+		return uintCell(uint64(v))
+	case uint16:
+		return uintCell(uint64(v))
+	case uint32:
+		return uintCell(uint64(v))
+	case uint64:
+		return uintCell(v)
+	case bool:
+		return MakeBoolCell(v)
+	case string:
+		return Cell{ValueType: "string", Text: v}
+	case time.Duration:
+		return MakeDurationCell(v)
+	case time.Time:
+		if h, m, s := v.Clock(); h == 0 && m == 0 && s == 0 && v.Nanosecond() == 0 {
+			return MakeDateCell(v)
+		}
+		return MakeDateTimeCell(v)
+	}
+	// Not reached, as the cases cover all types of CellValue.
+	return Cell{err: fmt.Errorf("unsupported cell value of type %T", v)}
+}
+
+// floatCell creates a "float" cell holding v, formatted with the shortest
+// representation that reads back as the same number of the given bit size.
+func floatCell(v float64, bitSize int) Cell {
+	cell := Cell{ValueType: "float", StyleName: "FLOAT_STYLE"}
+	if math.IsNaN(v) || math.IsInf(v, 0) {
+		cell.err = fmt.Errorf("invalid float value %v, expected a finite number", v)
+		return cell
+	}
+	cell.Value = strconv.FormatFloat(v, 'g', -1, bitSize)
+	return cell
+}
+
+func uintCell(v uint64) Cell {
+	return Cell{ValueType: "float", StyleName: "FLOAT_STYLE", Value: strconv.FormatUint(v, 10)}
+}
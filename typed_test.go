@@ -0,0 +1,107 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+// typedCells are cells of every Go type CellOf supports, with values a
+// string round trip would get wrong.
+func typedCells() [][]Cell {
+	berlin := time.FixedZone("CET", 3600)
+	tenth, fifth := 0.1, 0.2
+	return [][]Cell{{
+		CellOf(tenth + fifth),
+		CellOf(float32(0.1)),
+		CellOf(int64(1234567890123)),
+		CellOf(uint8(7)),
+		CellOf(true),
+		CellOf("text"),
+		CellOf(time.Date(2026, 3, 1, 0, 0, 0, 0, berlin)),
+		CellOf(time.Date(2026, 3, 1, 14, 30, 15, 0, berlin)),
+		CellOf(36*time.Hour + 15*time.Minute),
+	}}
+}
+
+func TestUnitTypedCells(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheet(typedCells())
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+
+	cells := spreadsheet.Tables[0].Rows[0].Cells
+	expected := []Cell{
+		{ValueType: "float", StyleName: "FLOAT_STYLE", Value: "0.30000000000000004"},
+		{ValueType: "float", StyleName: "FLOAT_STYLE", Value: "0.1"},
+		{ValueType: "float", StyleName: "FLOAT_STYLE", Value: "1234567890123"},
+		{ValueType: "float", StyleName: "FLOAT_STYLE", Value: "7"},
+		{ValueType: "boolean", StyleName: "BOOLEAN_STYLE", BooleanValue: "true"},
+		{ValueType: "string", Text: "text"},
+		{ValueType: "date", StyleName: "DATE_STYLE", DateValue: "2026-03-01"},
+		{ValueType: "date", StyleName: "DATETIME_STYLE", DateValue: "2026-03-01T13:30:15"},
+		{ValueType: "time", StyleName: "DURATION_STYLE", TimeValue: "PT36H15M00S"},
+	}
+	for i, e := range expected {
+		assert(t, cells[i] == e, fmt.Sprintf("column %d: expected %+v, got %+v", i+1, e, cells[i]))
+	}
+
+	// The constructors agree with their string counterparts.
+	pairs := map[string][2]Cell{
+		"float":    {MakeFloatCell(-42.5), MakeCell("-42.5", "float")},
+		"int":      {MakeIntCell(42), MakeCell("42", "float")},
+		"date":     {MakeDateCell(time.Date(2022, 2, 2, 18, 0, 0, 0, time.UTC)), MakeCell("2022-02-02", "date")},
+		"datetime": {MakeDateTimeCell(time.Date(2026, 3, 1, 14, 30, 0, 0, time.UTC)), MakeCell("2026-03-01T14:30", "datetime")},
+		"duration": {MakeDurationCell(90 * time.Second), MakeCell("0:01:30", "duration")},
+		"bool":     {MakeBoolCell(false), MakeCell("false", "boolean")},
+	}
+	for name, pair := range pairs {
+		assert(t, pair[0] == pair[1], fmt.Sprintf("%s: expected %+v, got %+v", name, pair[1], pair[0]))
+	}
+
+	fraction := MakeDurationCell(time.Hour + 250*time.Millisecond).TimeValue
+	assert(t, fraction == "PT01H00M00.25S", "expected the fraction of a second to be kept, got "+fraction)
+}
+
+func TestUnitTypedCellErrors(t *testing.T) {
+	cases := map[string]Cell{
+		"invalid float value NaN":                     MakeFloatCell(math.NaN()),
+		"invalid float value +Inf":                    CellOf(math.Inf(1)),
+		"invalid duration -1m0s, expected a positive": MakeDurationCell(-time.Minute),
+	}
+	for expected, cell := range cases {
+		t.Run(expected, func(t *testing.T) {
+			_, err := MakeSpreadsheet([][]Cell{{cell}})
+			assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+		})
+	}
+}
+
+func TestTypedCellsMatchOdfSchema(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheet(typedCells())
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	validateAgainstSchema(t, "flat.fods", flatOds)
+}
+
+func TestTypedCells(t *testing.T) {
+	expectedThisCsv := map[string][][]string{
+		"en_US.UTF-8": {{"0.30", "0.10", "1,234,567,890,123.00", "7.00", "TRUE", "text", "2026-03-01", "2026-03-01 13:30:15", "36:15:00"}},
+		"de_DE.UTF-8": {{"0,30", "0,10", "1.234.567.890.123,00", "7,00", "WAHR", "text", "2026-03-01", "2026-03-01 13:30:15", "36:15:00"}},
+	}
+
+	integrationTest(t, "typed-cells", "ods", typedCells(), expectedThisCsv)
+	integrationTest(t, "typed-cells", "fods", typedCells(), expectedThisCsv)
+}
@@ -0,0 +1,308 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"cmp"
+	"encoding"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UnmarshalSheet decodes the rows of the named sheet of spreadsheet into v,
+// which must point to a slice of structs or of pointers to structs. It is
+// the reverse of [MarshalSheet]: the first row of the sheet is the header,
+// whose cells are matched with the headers the "ods" tags of the fields give,
+// and each further row becomes an element of the slice, which is replaced.
+//
+// Cells are decoded by the Go type of their field, from the value they
+// store rather than the text they show: numbers from office:value into
+// integers and floats, dates and times of day from office:date-value into
+// time.Time, times from office:time-value into time.Duration or, as a time
+// of day on January 1 of year 1, time.Time, and
+// office:boolean-value into bool. Strings receive the text of string cells
+// and the stored value of others, such as "2026-03-01" for a date, as do
+// values implementing [encoding.TextUnmarshaler]. Formula cells are decoded
+// from their stored result. Empty cells leave their field at its zero value,
+// and pointer fields nil.
+//
+// Columns without a field are ignored, and so are rows holding nothing but
+// the SUBTOTAL formulas of a totals row, such as [MarshalTable] adds. Fields
+// without a column, invalid tags, and cells whose value does not fit their
+// field are reported as a single joined error, the latter with the sheet,
+// row, and column of the cell.
+func UnmarshalSheet(spreadsheet Spreadsheet, sheet string, v any) error {
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Pointer || rv.IsNil() || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("cannot unmarshal into %T, expected a pointer to a slice of structs", v)
+	}
+	slice := rv.Elem()
+	elementType := slice.Type().Elem()
+	fields, err := sheetFields(elementType)
+	if err != nil {
+		return err
+	}
+
+	var t *table
+	for i := range spreadsheet.Tables {
+		if spreadsheet.Tables[i].Name == sheet {
+			t = &spreadsheet.Tables[i]
+			break
+		}
+	}
+	if t == nil {
+		return fmt.Errorf("no sheet named %q", sheet)
+	}
+
+	var header []Cell
+	if len(t.Rows) > 0 {
+		header = t.Rows[0].Cells
+	}
+	columns := make([]int, len(fields))
+	var errs []error
+	for i, f := range fields {
+		columns[i] = slices.IndexFunc(header, func(c Cell) bool { return c.Text == f.header })
+		if columns[i] < 0 {
+			errs = append(errs, fmt.Errorf("sheet %q: no column %q for field %s", sheet, f.header, f.name))
+		}
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	result := reflect.MakeSlice(slice.Type(), 0, max(len(t.Rows)-1, 0))
+	for r := 1; r < len(t.Rows); r++ {
+		cells := t.Rows[r].Cells
+		if isTotalsRow(cells) {
+			continue
+		}
+		element := reflect.New(elementType).Elem()
+		target := element
+		if elementType.Kind() == reflect.Pointer {
+			element.Set(reflect.New(elementType.Elem()))
+			target = element.Elem()
+		}
+		for i, f := range fields {
+			if columns[i] >= len(cells) || isBlankCell(cells[columns[i]]) {
+				continue
+			}
+			field, err := fieldByIndexAlloc(target, f.index)
+			if err == nil {
+				err = unmarshalCell(cells[columns[i]], field)
+			}
+			if err != nil {
+				errs = append(errs, fmt.Errorf("sheet %q: row %d, column %d: %w", sheet, r+1, columns[i]+1, err))
+			}
+		}
+		result = reflect.Append(result, element)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+	slice.Set(result)
+	return nil
+}
+
+// isTotalsRow reports whether a row holds nothing but SUBTOTAL formulas and
+// empty cells, and at least one of the former.
+func isTotalsRow(cells []Cell) bool {
+	totals := false
+	for _, c := range cells {
+		switch {
+		case strings.HasPrefix(c.Formula, formulaNamespace+"=SUBTOTAL("):
+			totals = true
+		case !isBlankCell(c):
+			return false
+		}
+	}
+	return totals
+}
+
+// isBlankCell reports whether a cell shows nothing, as do empty cells and the
+// styled empty strings filling the rows of tables.
+func isBlankCell(c Cell) bool {
+	return isEmptyCell(c) || c.ValueType == "string" && c.Text == "" && c.Formula == ""
+}
+
+// fieldByIndexAlloc returns the field of v at index, allocating the nil
+// pointers to embedded structs on the way.
+func fieldByIndexAlloc(v reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, fmt.Errorf("cannot set embedded pointer to unexported struct type %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+var (
+	textUnmarshalerType = reflect.TypeFor[encoding.TextUnmarshaler]()
+	// odfDuration matches the durations of office:time-value, as LibreOffice
+	// and this package write them.
+	odfDuration = regexp.MustCompile(`^(-)?P(?:(\d+)D)?T(?:(\d+)H)?(?:(\d+)M)?(?:(\d+(?:\.\d+)?)S)?$`)
+)
+
+// unmarshalCell decodes cell, which is not empty, into v, as described for
+// [UnmarshalSheet].
+func unmarshalCell(cell Cell, v reflect.Value) error {
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := unmarshalCell(cell, p.Elem()); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	if v.Type() != timeType && reflect.PointerTo(v.Type()).Implements(textUnmarshalerType) {
+		return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(storedValue(cell)))
+	}
+	mismatch := fmt.Errorf("cannot unmarshal %s cell into %s", cmp.Or(cell.ValueType, "string"), v.Type())
+
+	switch {
+	case v.Type() == timeType:
+		if cell.ValueType == "time" {
+			// A time of day, as marshaled with the "time" value type.
+			d, err := parseODFDuration(cell.TimeValue)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(time.Time{}.Add(d)))
+			return nil
+		}
+		if cell.ValueType != "date" {
+			return mismatch
+		}
+		t, err := time.Parse("2006-01-02T15:04:05", cell.DateValue)
+		if err != nil {
+			t, err = time.Parse("2006-01-02", cell.DateValue)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid date %q", cell.DateValue)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == durationType:
+		if cell.ValueType != "time" {
+			return mismatch
+		}
+		d, err := parseODFDuration(cell.TimeValue)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(storedValue(cell))
+		return nil
+	case reflect.Bool:
+		if cell.ValueType != "boolean" {
+			return mismatch
+		}
+		v.SetBool(cell.BooleanValue == "true")
+		return nil
+	}
+
+	if !isNumberKind(v.Kind()) {
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	switch cell.ValueType {
+	case "float", "percentage", "currency":
+	default:
+		return mismatch
+	}
+	x, err := strconv.ParseFloat(cell.Value, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", cell.Value)
+	}
+	switch v.Kind() {
+	case reflect.Float32, reflect.Float64:
+		if v.OverflowFloat(x) {
+			return fmt.Errorf("value %s overflows %s", cell.Value, v.Type())
+		}
+		v.SetFloat(x)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if x != math.Trunc(x) {
+			return fmt.Errorf("value %s is not an integer", cell.Value)
+		}
+		if x < math.MinInt64 || x >= math.MaxInt64 || v.OverflowInt(int64(x)) {
+			return fmt.Errorf("value %s overflows %s", cell.Value, v.Type())
+		}
+		v.SetInt(int64(x))
+	default:
+		if x != math.Trunc(x) {
+			return fmt.Errorf("value %s is not an integer", cell.Value)
+		}
+		if x < 0 || x >= math.MaxUint64 || v.OverflowUint(uint64(x)) {
+			return fmt.Errorf("value %s overflows %s", cell.Value, v.Type())
+		}
+		v.SetUint(uint64(x))
+	}
+	return nil
+}
+
+// storedValue returns the value a cell stores, in its ODF form: the text of
+// string cells, and the value attribute of others.
+func storedValue(cell Cell) string {
+	switch cell.ValueType {
+	case "float", "percentage", "currency":
+		return cell.Value
+	case "date":
+		return cell.DateValue
+	case "time":
+		return cell.TimeValue
+	case "boolean":
+		return cell.BooleanValue
+	}
+	return cell.Text
+}
+
+// parseODFDuration parses the xsd:duration of an office:time-value, such as
+// "PT36H15M00S".
+func parseODFDuration(value string) (time.Duration, error) {
+	matches := odfDuration.FindStringSubmatch(value)
+	if matches == nil || value == "PT" {
+		return 0, fmt.Errorf("invalid duration %q", value)
+	}
+	var d time.Duration
+	for i, unit := range []time.Duration{24 * time.Hour, time.Hour, time.Minute} {
+		if matches[i+2] == "" {
+			continue
+		}
+		n, err := strconv.ParseInt(matches[i+2], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d += time.Duration(n) * unit
+	}
+	if matches[5] != "" {
+		seconds, err := strconv.ParseFloat(matches[5], 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid duration %q", value)
+		}
+		d += time.Duration(math.Round(seconds * float64(time.Second)))
+	}
+	if matches[1] != "" {
+		d = -d
+	}
+	return d, nil
+}
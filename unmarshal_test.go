@@ -0,0 +1,133 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// UnmarshalText makes iban round trip through a sheet.
+func (i *iban) UnmarshalText(text []byte) error {
+	*i = iban(strings.ToLower(string(text)))
+	return nil
+}
+
+func TestUnitUnmarshalSheet(t *testing.T) {
+	spreadsheet, err := MarshalTable(transactions(), TableOptions{Name: "Transactions", AutoFilter: true})
+	if err != nil {
+		t.Fatalf("MarshalTable: %v", err)
+	}
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	read, err := ReadFlatOds(strings.NewReader(flatOds))
+	if err != nil {
+		t.Fatalf("ReadFlatOds: %v", err)
+	}
+
+	var got []transaction
+	if err := UnmarshalSheet(read, "Sheet1", &got); err != nil {
+		t.Fatalf("UnmarshalSheet: %v", err)
+	}
+	expected := transactions()
+	for i := range expected {
+		// Neither is written to the sheet.
+		expected[i].Note, expected[i].internal = "", 0
+	}
+	assert(t, reflect.DeepEqual(got, expected), fmt.Sprintf("expected %+v, got %+v", expected, got))
+
+	// Strings hold the stored value of any cell, and pointers to structs are
+	// allocated.
+	var values []*struct {
+		Amount   string `ods:"Amount"`
+		Booked   string
+		Duration string
+		Cleared  string
+	}
+	if err := UnmarshalSheet(read, "Sheet1", &values); err != nil {
+		t.Fatalf("UnmarshalSheet: %v", err)
+	}
+	assert(t, len(values) == 2, fmt.Sprintf("expected two rows, got %d", len(values)))
+	assert(t, *values[1] == struct {
+		Amount   string `ods:"Amount"`
+		Booked   string
+		Duration string
+		Cleared  string
+	}{"300.5", "2026-03-05", "PT01H30M00S", "true"}, fmt.Sprintf("unexpected values %+v", *values[1]))
+
+	// Datetimes, times of day, negative durations, and integers.
+	var times []struct {
+		At      time.Time
+		Opens   time.Time
+		Elapsed time.Duration
+		Count   uint8
+	}
+	spreadsheet, err = MakeSpreadsheet([][]Cell{
+		{MakeCell("At", "string"), MakeCell("Opens", "string"), MakeCell("Elapsed", "string"), MakeCell("Count", "string")},
+		{MakeCell("2026-03-01T14:30:15.5", "datetime"), MakeCell("08:15", "time"), {ValueType: "time", TimeValue: "-P1DT2H"}, MakeCell("255", "float")},
+	})
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+	if err := UnmarshalSheet(spreadsheet, "Sheet1", &times); err != nil {
+		t.Fatalf("UnmarshalSheet: %v", err)
+	}
+	assert(t, times[0].At.Equal(time.Date(2026, 3, 1, 14, 30, 15, 5e8, time.UTC)), "unexpected datetime "+times[0].At.String())
+	assert(t, times[0].Opens.Hour() == 8 && times[0].Opens.Minute() == 15, "unexpected time of day "+times[0].Opens.String())
+	assert(t, times[0].Elapsed == -26*time.Hour, "unexpected duration "+times[0].Elapsed.String())
+	assert(t, times[0].Count == 255, fmt.Sprintf("unexpected count %d", times[0].Count))
+}
+
+func TestUnitUnmarshalSheetErrors(t *testing.T) {
+	spreadsheet, err := MakeSpreadsheetWithName("Data", [][]Cell{
+		{MakeCell("Amount", "string"), MakeCell("Booked", "string"), MakeCell("Count", "string")},
+		{MakeCell("abc", "string"), MakeCell("1.5", "float"), MakeCell("2.5", "float")},
+		{MakeCell("1", "float"), MakeCell("2026-03-01", "date"), MakeCell("300", "float")},
+	})
+	if err != nil {
+		t.Fatalf("MakeSpreadsheet: %v", err)
+	}
+
+	var rows []struct {
+		Amount float64
+		Booked time.Time
+		Count  int8
+	}
+	err = UnmarshalSheet(spreadsheet, "Data", &rows)
+	for _, expected := range []string{
+		`sheet "Data": row 2, column 1: cannot unmarshal string cell into float64`,
+		`sheet "Data": row 2, column 2: cannot unmarshal float cell into time.Time`,
+		`sheet "Data": row 2, column 3: value 2.5 is not an integer`,
+		`sheet "Data": row 3, column 3: value 300 overflows int8`,
+	} {
+		assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+	}
+	assert(t, rows == nil, "expected the slice to be left alone on errors")
+
+	cases := map[string]any{
+		`no sheet named "Other"`:                      &rows,
+		"cannot unmarshal into []struct":              rows,
+		`sheet "Data": no column "Due" for field Due`: &[]struct{ Due time.Time }{},
+		"cannot map string to the columns of a sheet": &[]string{},
+		`field Amount: unknown value type "money"`: &[]struct {
+			Amount float64 `ods:",money"`
+		}{},
+	}
+	for expected, v := range cases {
+		t.Run(expected, func(t *testing.T) {
+			sheet := "Data"
+			if strings.Contains(expected, "Other") {
+				sheet = "Other"
+			}
+			err := UnmarshalSheet(spreadsheet, sheet, v)
+			assert(t, err != nil && strings.Contains(err.Error(), expected), fmt.Sprintf("expected an error containing %q, got: %v", expected, err))
+		})
+	}
+}
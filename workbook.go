@@ -0,0 +1,487 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxSheetNameLength is the longest sheet name Excel accepts. LibreOffice
+// allows longer names, but Excel refuses to open a document carrying one.
+const maxSheetNameLength = 31
+
+// Sheet is one sheet of a workbook built with [MakeWorkbook]. Create sheets
+// with [MakeSheet] or [MakeTableSheet].
+type Sheet struct {
+	name  string
+	cells [][]Cell
+	table *TableOptions
+	names []DefinedName
+}
+
+// MakeSheet creates a sheet named name holding the given rows of cells, laid
+// out as [MakeSpreadsheetWithName] does.
+func MakeSheet(name string, cells [][]Cell) Sheet {
+	return Sheet{name: name, cells: cells}
+}
+
+// MakeTableSheet creates a sheet named name holding the given rows of cells,
+// formatted as an Excel-style table as [MakeTable] does. The database range
+// of the table defaults to "Table<n>" for the n-th table sheet of the
+// workbook.
+func MakeTableSheet(name string, cells [][]Cell, opts TableOptions) Sheet {
+	return Sheet{name: name, cells: cells, table: &opts}
+}
+
+// MakeWorkbook combines sheets into a spreadsheet with one sheet each, in the
+// given order.
+//
+// Sheet names must be unique, compared without regard to case, and legal: not
+// empty, at most 31 characters long, without any of the characters
+// [ ] * ? : / \, and not beginning or ending with an apostrophe. Range names
+// are shared by all sheets and must be unique across the workbook; the column
+// names generated for [TableOptions.StructuredRefs] avoid the ones already in
+// use. Table names must be unique too, compared without regard to case, as
+// structured references in formulas refer to tables by name. Cells styled
+// alike share one generated style, whichever sheet they are on.
+//
+// It reports invalid sheet names, invalid cells, duplicate range names,
+// unresolved formula references, and circular references as a single joined
+// error, with the sheet each error occurred on. Formulas may refer to sheets
+// and range names defined by any sheet of the workbook, and to the names of
+// [Sheet.WithNames] scoped to their own sheet.
+func MakeWorkbook(sheets ...Sheet) (Spreadsheet, error) {
+	if len(sheets) == 0 {
+		return Spreadsheet{}, errors.New("a workbook needs at least one sheet")
+	}
+
+	var errs []error
+	seen := map[string]bool{}
+	for i, sheet := range sheets {
+		if err := validateSheetName(sheet.name); err != nil {
+			errs = append(errs, fmt.Errorf("sheet %d: %w", i+1, err))
+		}
+		key := strings.ToLower(sheet.name)
+		if seen[key] {
+			errs = append(errs, fmt.Errorf("sheet %d: duplicate sheet name %q", i+1, sheet.name))
+		}
+		seen[key] = true
+	}
+	if len(errs) > 0 {
+		return Spreadsheet{}, errors.Join(errs...)
+	}
+
+	b := newWorkbookBuilder(sheets)
+	for _, sheet := range sheets {
+		if err := b.addSheet(sheet); err != nil {
+			errs = append(errs, fmt.Errorf("sheet %q: %w", sheet.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return Spreadsheet{}, errors.Join(errs...)
+	}
+	// References are resolved and checked once all sheets are added, as
+	// formulas may refer to sheets, tables, and range names that follow them.
+	for _, t := range b.tables {
+		if err := b.resolveFormulas(t); err != nil {
+			errs = append(errs, fmt.Errorf("sheet %q: %w", t.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return Spreadsheet{}, errors.Join(errs...)
+	}
+	for _, t := range b.tables {
+		if err := b.checkReferences(t); err != nil {
+			errs = append(errs, fmt.Errorf("sheet %q: %w", t.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return Spreadsheet{}, errors.Join(errs...)
+	}
+	cycles := makeDependencyGraph(b.tables, b.names).cycleErrors()
+	for _, t := range b.tables {
+		if err := cycles[t.Name]; err != nil {
+			errs = append(errs, fmt.Errorf("sheet %q: %w", t.Name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return Spreadsheet{}, errors.Join(errs...)
+	}
+	return b.spreadsheet(), nil
+}
+
+// makeSingleSheet builds a spreadsheet of a single sheet. Unlike
+// [MakeWorkbook], it reports errors without naming the sheet, and does not
+// check the name of the sheet, which callers of [MakeSpreadsheetWithName]
+// were free to choose before workbooks existed.
+func makeSingleSheet(sheet Sheet) (Spreadsheet, error) {
+	b := newWorkbookBuilder([]Sheet{sheet})
+	if err := b.addSheet(sheet); err != nil {
+		return Spreadsheet{}, err
+	}
+	if err := b.resolveFormulas(b.tables[0]); err != nil {
+		return Spreadsheet{}, err
+	}
+	if err := b.checkReferences(b.tables[0]); err != nil {
+		return Spreadsheet{}, err
+	}
+	if err := makeDependencyGraph(b.tables, b.names).cycleErrors()[sheet.name]; err != nil {
+		return Spreadsheet{}, err
+	}
+	return b.spreadsheet(), nil
+}
+
+// validateSheetName checks name against the rules described for
+// [MakeWorkbook].
+func validateSheetName(name string) error {
+	switch {
+	case name == "":
+		return errors.New("sheet name is empty")
+	case utf8.RuneCountInString(name) > maxSheetNameLength:
+		return fmt.Errorf("sheet name %q is longer than %d characters", name, maxSheetNameLength)
+	case strings.ContainsAny(name, `[]*?:/\`):
+		return fmt.Errorf(`sheet name %q contains one of the characters [ ] * ? : / \`, name)
+	case strings.HasPrefix(name, "'") || strings.HasSuffix(name, "'"):
+		return fmt.Errorf("sheet name %q begins or ends with an apostrophe", name)
+	}
+	return nil
+}
+
+// workbookBuilder assembles the sheets of a spreadsheet, sharing the named
+// ranges, database ranges, and generated styles between them.
+type workbookBuilder struct {
+	tables []table
+	// names holds the named ranges and named expressions shared by all
+	// sheets; those scoped to a sheet are kept with its table.
+	names          namedExpressions
+	databaseRanges []databaseRange
+
+	// rangeNames holds the range names defined so far, usedRangeNames all
+	// range names any sheet defines on its cells or with
+	// [Sheet.WithNames], which generated column names have to avoid even
+	// before the sheet defining them is added. sheetRangeNames holds the
+	// names scoped to each sheet.
+	rangeNames      map[string]bool
+	usedRangeNames  map[string]bool
+	sheetRangeNames map[string]map[string]bool
+	// sheetExpressions holds the named expressions each sheet defines,
+	// whose references are checked along with those of its formulas.
+	sheetExpressions map[string][]sheetExpression
+	// sheetNames holds the names of all sheets of the workbook, including
+	// those still to be added.
+	sheetNames    map[string]bool
+	databaseNames map[string]bool
+	tableSheets   int
+	// structuredTables holds the tables of the table sheets, which
+	// structured references in formulas refer to.
+	structuredTables []structuredTable
+
+	customStyleNames map[customStyleKey]string
+	customStyles     []cellStyle
+}
+
+func newWorkbookBuilder(sheets []Sheet) *workbookBuilder {
+	used := map[string]bool{}
+	sheetNames := map[string]bool{}
+	for _, sheet := range sheets {
+		sheetNames[sheet.name] = true
+		for _, n := range sheet.names {
+			if !n.SheetScope {
+				used[n.Name] = true
+			}
+		}
+		for _, r := range sheet.cells {
+			for _, c := range r {
+				if c.rangeName != "" {
+					used[c.rangeName] = true
+				}
+			}
+		}
+	}
+	return &workbookBuilder{
+		names:            namedExpressions{NamedRanges: []namedRange{}},
+		rangeNames:       map[string]bool{},
+		usedRangeNames:   used,
+		sheetRangeNames:  map[string]map[string]bool{},
+		sheetExpressions: map[string][]sheetExpression{},
+		sheetNames:       sheetNames,
+		databaseNames:    map[string]bool{},
+		customStyleNames: map[customStyleKey]string{},
+	}
+}
+
+// spreadsheet evaluates the formulas of the sheets added, generates the
+// styles of styled cells, and returns the resulting spreadsheet. The styles
+// are generated last, as the results of formulas decide about their number
+// format.
+func (b *workbookBuilder) spreadsheet() Spreadsheet {
+	evaluateFormulas(b.tables, b.names)
+	for _, t := range b.tables {
+		for _, r := range t.Rows {
+			b.styleRow(r.Cells)
+		}
+	}
+
+	spreadsheet := Spreadsheet{
+		Tables:           b.tables,
+		NamedExpressions: b.names,
+		customStyles:     b.customStyles,
+	}
+	if len(b.databaseRanges) > 0 {
+		spreadsheet.DatabaseRanges = &databaseRanges{Ranges: b.databaseRanges}
+	}
+	return spreadsheet
+}
+
+// addSheet adds a sheet, formatting it as a table if it was created with
+// [MakeTableSheet], and defines its names.
+func (b *workbookBuilder) addSheet(sheet Sheet) error {
+	if sheet.table == nil {
+		if err := b.addCells(sheet.name, sheet.cells); err != nil {
+			return err
+		}
+		return b.addNames(sheet.name, sheet.names)
+	}
+
+	opts := *sheet.table
+	b.tableSheets++
+	dbName := opts.Name
+	if dbName == "" {
+		dbName = fmt.Sprintf("Table%d", b.tableSheets)
+	}
+
+	layout, err := formatTable(sheet.cells, opts, b.usedRangeNames)
+	if err != nil {
+		return err
+	}
+	// Table names identify tables in structured references as well as
+	// database ranges, which Excel compares without regard to case.
+	key := strings.ToLower(dbName)
+	if b.databaseNames[key] {
+		return fmt.Errorf("duplicate table name %q", dbName)
+	}
+	b.databaseNames[key] = true
+	b.structuredTables = append(b.structuredTables, makeStructuredTable(dbName, sheet.name, layout, opts))
+	if err := b.addCells(sheet.name, layout.cells); err != nil {
+		return err
+	}
+
+	if layout.columnNames != nil && layout.hasBody() {
+		for j := range layout.maxCols {
+			col := columnToLetters(j + 1)
+			base := fmt.Sprintf("$%s.$%s$%d", quoteSheetName(sheet.name), col, layout.firstDataRow)
+			b.rangeNames[layout.columnNames[j]] = true
+			b.names.NamedRanges = append(b.names.NamedRanges, namedRange{
+				Name:             layout.columnNames[j],
+				BaseCellAddress:  base,
+				CellRangeAddress: fmt.Sprintf("%s:.$%s$%d", base, col, layout.lastDataRow),
+			})
+		}
+	}
+
+	if opts.AutoFilter && layout.lastDataRow > 0 {
+		// The filter range covers the header and body but not the totals row,
+		// so filtering and sorting never move the aggregates.
+		b.databaseRanges = append(b.databaseRanges, databaseRange{
+			Name:                 dbName,
+			TargetRangeAddress:   usedRangeAddress(sheet.name, layout.lastDataRow, layout.maxCols),
+			DisplayFilterButtons: "true",
+		})
+	}
+	return b.addNames(sheet.name, sheet.names)
+}
+
+// addCells adds a sheet holding the given rows of cells. It reports all
+// invalid cells and duplicate range names as a single joined error.
+func (b *workbookBuilder) addCells(name string, cells [][]Cell) error {
+	var rows []row
+	var errs []error
+
+	maxCols := 1
+	var matrices matrixCover
+	for rowIdx := 0; rowIdx < len(cells) || !matrices.done(); rowIdx++ {
+		var c []Cell
+		if rowIdx < len(cells) {
+			// The cells are copied, as the builder stores results and
+			// style names in them.
+			c = slices.Clone(cells[rowIdx])
+		}
+		c, coverErrs := matrices.cover(rowIdx, c)
+		errs = append(errs, coverErrs...)
+		rows = append(rows, row{Cells: c})
+		maxCols = max(maxCols, len(c))
+		errs = append(errs, b.addRow(name, rowIdx, c)...)
+	}
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	b.tables = append(b.tables, table{
+		Name:      name,
+		StyleName: tableStyleName,
+		// The ODF schema requires at least one table:table-column before the
+		// table rows.
+		Columns: []tableColumn{{NumberColumnsRepeated: strconv.Itoa(maxCols)}},
+		Rows:    rows,
+	})
+	return nil
+}
+
+// addRow registers the range names of the cells in the rowIdx-th row of the
+// named sheet. It returns the errors of invalid cells and duplicate range
+// names.
+func (b *workbookBuilder) addRow(sheet string, rowIdx int, cells []Cell) []error {
+	var errs []error
+	for colIdx, cc := range cells {
+		if cc.err != nil {
+			errs = append(errs, fmt.Errorf("row %d, column %d: %w", rowIdx+1, colIdx+1, cc.err))
+		}
+		if cc.rangeName != "" {
+			if b.rangeNames[cc.rangeName] {
+				errs = append(errs, fmt.Errorf("row %d, column %d: duplicate range name %q", rowIdx+1, colIdx+1, cc.rangeName))
+			} else {
+				address := fmt.Sprintf("$%s.%s", quoteSheetName(sheet), toA1(rowIdx+1, colIdx+1))
+				b.rangeNames[cc.rangeName] = true
+				b.names.NamedRanges = append(b.names.NamedRanges, namedRange{
+					Name:             cc.rangeName,
+					BaseCellAddress:  address,
+					CellRangeAddress: address,
+				})
+			}
+		}
+	}
+	return errs
+}
+
+// dropNamedRanges takes back the named ranges registered after the first n,
+// those of a row left out.
+func (b *workbookBuilder) dropNamedRanges(n int) {
+	for _, r := range b.names.NamedRanges[n:] {
+		delete(b.rangeNames, r.Name)
+	}
+	b.names.NamedRanges = b.names.NamedRanges[:n]
+}
+
+// resolveFormulas translates the formulas of t that depend on where they
+// are: those holding structured references to tables, and those written in
+// R1C1 notation. It reports syntax errors and references to undefined tables,
+// columns, and rows as a single joined error.
+func (b *workbookBuilder) resolveFormulas(t table) error {
+	var errs []error
+	for rowIdx, r := range t.Rows {
+		errs = append(errs, b.resolveFormulaRow(t.Name, rowIdx, r.Cells)...)
+	}
+	return errors.Join(errs...)
+}
+
+// checkReferences checks that the names and addresses the formulas and named
+// expressions of t refer to resolve: that range names are defined, that
+// referenced sheets exist, and that addresses lie within the rows and columns
+// a sheet can have. Range names are compared without regard to case, as
+// spreadsheet applications do, and formulas passed through with a namespace
+// prefix are not checked. It reports the failing references as a single
+// joined error.
+func (b *workbookBuilder) checkReferences(t table) error {
+	sheets := map[string]bool{}
+	for _, t := range b.tables {
+		sheets[t.Name] = true
+	}
+	// names holds the names defined for the workbook, under "", and for
+	// the sheet, in upper case, as names are compared without regard to
+	// case.
+	names := map[string]map[string]bool{"": {}, t.Name: {}}
+	for name := range b.rangeNames {
+		names[""][strings.ToUpper(name)] = true
+	}
+	for name := range b.sheetRangeNames[t.Name] {
+		names[t.Name][strings.ToUpper(name)] = true
+	}
+
+	var errs []error
+	check := func(formula, scope string, context func(error) error) {
+		expression, ok := strings.CutPrefix(formula, formulaNamespace+"=")
+		if !ok {
+			return
+		}
+		node, err := parseFormula(expression, openFormulaNotation)
+		if err != nil {
+			// Only formulas passed through in OpenFormula notation fail
+			// to parse here; they are left to the consumer.
+			return
+		}
+		walkFormula(node, func(node formulaNode) bool {
+			if err := checkReference(node, names, scope, sheets); err != nil {
+				errs = append(errs, context(err))
+			}
+			return true
+		})
+	}
+	for rowIdx, r := range t.Rows {
+		for colIdx, cc := range r.Cells {
+			if cc.verbatim {
+				// Formulas passed through are left to the consumer.
+				continue
+			}
+			check(cc.Formula, t.Name, func(err error) error {
+				return fmt.Errorf("row %d, column %d: %w", rowIdx+1, colIdx+1, err)
+			})
+		}
+	}
+	for _, ne := range b.sheetExpressions[t.Name] {
+		scope := ""
+		if ne.sheetScope {
+			scope = t.Name
+		}
+		check(ne.Expression, scope, func(err error) error {
+			return fmt.Errorf("name %q: %w", ne.Name, err)
+		})
+	}
+	return errors.Join(errs...)
+}
+
+// checkReference checks a single name or address of a formula on the sheet
+// scope, or of a workbook-wide named expression if scope is empty, as
+// described for checkReferences. Other nodes pass.
+func checkReference(node formulaNode, names map[string]map[string]bool, scope string, sheets map[string]bool) error {
+	switch n := node.(type) {
+	case nameNode:
+		if name := strings.ToUpper(n.name); !names[""][name] && !names[scope][name] {
+			return fmt.Errorf("undefined name %q", n.name)
+		}
+	case referenceNode:
+		for _, addr := range []cellAddress{n.ref.start, n.ref.end} {
+			if addr.sheet != "" && !sheets[addr.sheet] {
+				return fmt.Errorf("reference to missing sheet %q", addr.sheet)
+			}
+			if addr.row > maxRows || addr.col > maxColumns {
+				return fmt.Errorf("address %s is beyond the %d rows and %d columns of a sheet", columnToLetters(addr.col)+strconv.Itoa(addr.row), maxRows, maxColumns)
+			}
+		}
+	}
+	return nil
+}
+
+// styleRow sets the style name of the styled cells in a row, generating a
+// style for each distinct combination of style and number format.
+func (b *workbookBuilder) styleRow(cells []Cell) {
+	for colIdx, cc := range cells {
+		if cc.style == nil {
+			continue
+		}
+		key := customStyleKey{CellStyle: *cc.style, dataStyleName: dataStyleNameFor(cc.StyleName)}
+		styleName, exists := b.customStyleNames[key]
+		if !exists {
+			styleName = fmt.Sprintf("%s%d", customStylePrefix, len(b.customStyleNames)+1)
+			b.customStyleNames[key] = styleName
+			b.customStyles = append(b.customStyles, buildCustomCellStyle(styleName, key.dataStyleName, *cc.style))
+		}
+		cells[colIdx].StyleName = styleName
+	}
+}
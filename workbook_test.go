@@ -0,0 +1,162 @@
+// SPDX-FileCopyrightText: 2025 Florian Wilhelm
+//
+// SPDX-License-Identifier: MIT
+
+package ods
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func workbookSheets() []Sheet {
+	return []Sheet{
+		MakeSheet("Summary", [][]Cell{
+			{MakeStyledCell("Total", "string", CellStyle{Bold: true}), MakeCell("SUBTOTAL(9;Amount)", "formula")},
+		}),
+		MakeTableSheet("Transactions 2026", [][]Cell{
+			{MakeCell("Purpose", "string"), MakeCell("Amount", "string")},
+			{MakeCell("Rent", "string"), MakeCell("-900", "currency")},
+			{MakeCell("Salary", "string"), MakeRangeCell("3000", "currency", "Salary")},
+		}, TableOptions{Header: true, AutoFilter: true, StructuredRefs: true}),
+		MakeTableSheet("Savings", [][]Cell{
+			{MakeCell("Amount", "string")},
+			{MakeStyledCell("100", "currency", CellStyle{Bold: true})},
+		}, TableOptions{Header: true, AutoFilter: true, StructuredRefs: true}),
+	}
+}
+
+func TestUnitWorkbook(t *testing.T) {
+	spreadsheet, err := MakeWorkbook(workbookSheets()...)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	actual, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+
+	for _, name := range []string{"Summary", "Transactions 2026", "Savings"} {
+		assert(t, strings.Contains(actual, fmt.Sprintf(`<table:table table:name="%s"`, name)), fmt.Sprintf("expected a sheet named %s", name))
+	}
+
+	// Names and addresses of sheets with spaces in their name are quoted (the
+	// encoder escapes the quotes in attribute values).
+	assert(t, strings.Contains(actual, `table:cell-range-address="$&#39;Transactions 2026&#39;.$B$2:.$B$3"`), "expected the column range on the quoted sheet name")
+	assert(t, strings.Contains(actual, `table:cell-range-address="$&#39;Transactions 2026&#39;.$B$3"`), "expected the cell range on the quoted sheet name")
+	assert(t, strings.Contains(actual, `table:target-range-address="&#39;Transactions 2026&#39;.A1:&#39;Transactions 2026&#39;.B3"`), "expected the filter range on the quoted sheet name")
+
+	// The second table's column name avoids the first table's.
+	assert(t, strings.Contains(actual, `table:name="Amount_2" table:base-cell-address="$Savings.$A$2"`), "expected a unique column name for the second table")
+	// Each table gets a database range of its own.
+	assert(t, strings.Contains(actual, `table:name="Table1"`) && strings.Contains(actual, `table:name="Table2"`), "expected numbered default table names")
+
+	// The header rows of both tables share one generated style, regardless of
+	// the sheet they are on.
+	assert(t, strings.Count(actual, `style:name="CUSTOM_STYLE_`) == 3, "expected the generated styles to be shared across sheets")
+}
+
+func TestUnitWorkbookInvalid(t *testing.T) {
+	cells := [][]Cell{{MakeCell("a", "string")}}
+
+	cases := map[string]struct {
+		sheets   []Sheet
+		expected string
+	}{
+		"no sheets":        {nil, "at least one sheet"},
+		"empty name":       {[]Sheet{MakeSheet("", cells)}, "empty"},
+		"long name":        {[]Sheet{MakeSheet(strings.Repeat("x", 32), cells)}, "longer than 31"},
+		"illegal char":     {[]Sheet{MakeSheet("Q1/Q2", cells)}, "contains one of the characters"},
+		"apostrophe":       {[]Sheet{MakeSheet("'quoted'", cells)}, "apostrophe"},
+		"duplicate name":   {[]Sheet{MakeSheet("Data", cells), MakeSheet("data", cells)}, `duplicate sheet name "data"`},
+		"duplicate tables": {[]Sheet{MakeTableSheet("A", cells, TableOptions{Name: "T", AutoFilter: true}), MakeTableSheet("B", cells, TableOptions{Name: "T", AutoFilter: true})}, `duplicate table name "T"`},
+		"duplicate range": {[]Sheet{
+			MakeSheet("A", [][]Cell{{MakeRangeCell("1", "float", "Input")}}),
+			MakeSheet("B", [][]Cell{{MakeRangeCell("2", "float", "Input")}}),
+		}, `sheet "B": row 1, column 1: duplicate range name "Input"`},
+		"invalid cell": {[]Sheet{MakeSheet("A", cells), MakeSheet("B", [][]Cell{{MakeCell("x", "float")}})}, `sheet "B": row 1, column 1: invalid float`},
+		"undefined name": {[]Sheet{
+			MakeSheet("A", [][]Cell{{MakeCell("Input*2", "formula")}}),
+			MakeSheet("B", [][]Cell{{MakeRangeCell("2", "float", "Inputs")}}),
+		}, `sheet "A": row 1, column 1: undefined name "Input"`},
+		"missing sheet": {[]Sheet{MakeSheet("A", cells), MakeSheet("B", [][]Cell{{MakeCell("1", "float"), MakeCell("C.A1+A1", "formula")}})}, `sheet "B": row 1, column 2: reference to missing sheet "C"`},
+	}
+
+	for name, c := range cases {
+		t.Run(name, func(t *testing.T) {
+			_, err := MakeWorkbook(c.sheets...)
+			if err == nil || !strings.Contains(err.Error(), c.expected) {
+				t.Errorf("expected an error containing %q, got: %v", c.expected, err)
+			}
+		})
+	}
+}
+
+func TestUnitWorkbookResolvesReferencesAcrossSheets(t *testing.T) {
+	// The first sheet refers to a sheet and a range name defined after it.
+	_, err := MakeWorkbook(
+		MakeSheet("Summary", [][]Cell{{MakeCell("Data.A1+Input", "formula")}}),
+		MakeSheet("Data", [][]Cell{{MakeRangeCell("1", "float", "Input")}}),
+	)
+	if err != nil {
+		t.Errorf("expected the references to resolve, got: %v", err)
+	}
+
+	// Sheet names with spaces are quoted, in either notation.
+	spreadsheet, err := MakeWorkbook(
+		MakeSheet("Summary", [][]Cell{{MakeCell("'Q1 2026'!A1+$'Q1 2026'.A2", "formula"), MakeCell("SUM('Summary:Q1 2026'!A2)", "formula")}}),
+		MakeSheet("Q1 2026", [][]Cell{{MakeCell("1", "float")}, {MakeCell("2", "float")}}),
+	)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+	cells := spreadsheet.Tables[0].Rows[0].Cells
+	assert(t, cells[0].Formula == "of:=['Q1 2026'.A1]+[$'Q1 2026'.A2]" && cells[0].Value == "3", fmt.Sprintf("expected the sum of the quoted sheet, got %q = %q", cells[0].Formula, cells[0].Value))
+	assert(t, cells[1].Formula == "of:=SUM([Summary.A2:'Q1 2026'.A2])", fmt.Sprintf("expected a 3D range, got %q", cells[1].Formula))
+
+	_, err = MakeWorkbook(MakeSheet("Summary", [][]Cell{{MakeCell("SUM('Summary:Q2 2026'!A1)", "formula")}}))
+	if err == nil || !strings.Contains(err.Error(), `reference to missing sheet "Q2 2026"`) {
+		t.Errorf("expected the missing last sheet of the 3D range to be reported, got: %v", err)
+	}
+}
+
+func TestUnitSpreadsheetWithNameKeepsName(t *testing.T) {
+	// Only MakeWorkbook checks sheet names, which callers of
+	// MakeSpreadsheetWithName chose freely before workbooks existed.
+	spreadsheet, err := MakeSpreadsheetWithName("a:b", [][]Cell{{MakeCell("a", "string")}})
+	if err != nil {
+		t.Fatalf("MakeSpreadsheetWithName: %v", err)
+	}
+	assert(t, spreadsheet.Tables[0].Name == "a:b", "expected the sheet name as it was given")
+}
+
+func TestWorkbookMatchesOdfSchema(t *testing.T) {
+	spreadsheet, err := MakeWorkbook(workbookSheets()...)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	flatOds, err := MakeFlatOds(spreadsheet)
+	if err != nil {
+		t.Fatalf("MakeFlatOds: %v", err)
+	}
+	validateAgainstSchema(t, "flat.fods", flatOds)
+}
+
+func TestWorkbook(t *testing.T) {
+	spreadsheet, err := MakeWorkbook(workbookSheets()...)
+	if err != nil {
+		t.Fatalf("MakeWorkbook: %v", err)
+	}
+
+	// LibreOffice converts the first sheet only; its formula sums the Amount
+	// column on the second sheet through the generated named range.
+	expectedThisCsv := make(map[string][][]string)
+	expectedThisCsv["en_US.UTF-8"] = [][]string{{"Total", "2100"}}
+	expectedThisCsv["de_DE.UTF-8"] = [][]string{{"Total", "2100"}}
+
+	renderAndCompare(t, "workbook", "ods", spreadsheet, expectedThisCsv)
+	renderAndCompare(t, "workbook", "fods", spreadsheet, expectedThisCsv)
+}